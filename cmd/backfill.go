@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/eth"
+	"github.com/spf13/cobra"
+)
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Re-ingest the swaps of a campaign pool over a time range",
+	Run: func(cmd *cobra.Command, args []string) {
+		campaignID, _ := cmd.Flags().GetInt("campaign")
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+
+		db := database.InitPostgreSQL()
+		defer db.Close()
+
+		campaign, err := database.GetCampaignByID(campaignID)
+		if err != nil {
+			log.Fatalf("Failed to get campaign %d: %v", campaignID, err)
+		}
+		startTime, endTime := campaign.StartTime, campaign.EndTime
+		if from != "" {
+			if startTime, err = parseTime(from, false); err != nil {
+				log.Fatalf("Invalid --from: %v", err)
+			}
+		}
+		if to != "" {
+			if endTime, err = parseTime(to, true); err != nil {
+				log.Fatalf("Invalid --to: %v", err)
+			}
+		}
+		if startTime > endTime {
+			log.Fatalf("--from must be before --to")
+		}
+
+		eth.NewChains()
+		chain, err := eth.GetChain(campaign.ChainID)
+		if err != nil {
+			log.Fatalf("Failed to get chain of campaign %d: %v", campaignID, err)
+		}
+
+		fmt.Printf("Backfilling campaign %d pool %s on %s from %s to %s\n", campaign.CampaignID, campaign.PoolAddress, chain.Name,
+			time.Unix(startTime, 0).UTC().Format(time.RFC3339), time.Unix(endTime, 0).UTC().Format(time.RFC3339))
+		progress, err := eth.Backfill(chain, campaign.PoolAddress, startTime, endTime, func(p eth.BackfillProgress) {
+			fmt.Printf("Block %d of %d-%d: %d swaps stored, %d already stored\n", p.Block, p.FromBlock, p.ToBlock, p.Stored, p.Skipped)
+		})
+		if errors.Is(err, eth.ErrIngestionLocked) {
+			log.Fatalf("Backfill not started: %v", err)
+		}
+		if err != nil {
+			log.Fatalf("Backfill stopped after block %d: %v", progress.Block, err)
+		}
+		fmt.Printf("Backfill done: %d swaps stored, %d already stored\n", progress.Stored, progress.Skipped)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backfillCmd)
+	backfillCmd.Flags().Int("campaign", 0, "campaign ID")
+	backfillCmd.Flags().String("from", "", "start of the range as a date, RFC3339 time or unix timestamp (default is the campaign start)")
+	backfillCmd.Flags().String("to", "", "end of the range as a date, RFC3339 time or unix timestamp (default is the campaign end)")
+	// nolint
+	backfillCmd.MarkFlagRequired("campaign")
+}
+
+// parseTime accepts a unix timestamp, an RFC3339 time or a UTC date. A date
+// at the end of a range covers the whole day.
+func parseTime(value string, endOfDay bool) (int64, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return unix, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a date, RFC3339 time or unix timestamp", value)
+	}
+	if endOfDay {
+		return t.AddDate(0, 0, 1).Unix() - 1, nil
+	}
+	return t.Unix(), nil
+}
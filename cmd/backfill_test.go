@@ -0,0 +1,31 @@
+package cmd
+
+import "testing"
+
+func Test_parseTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		endOfDay bool
+		want     int64
+		wantErr  bool
+	}{
+		{name: "Unix timestamp", value: "1730419200", want: 1730419200},
+		{name: "RFC3339 time", value: "2024-11-01T08:00:00+08:00", want: 1730419200},
+		{name: "Date", value: "2024-11-01", want: 1730419200},
+		{name: "Date at the end of a range", value: "2024-11-01", endOfDay: true, want: 1730505599},
+		{name: "Invalid", value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTime(tt.value, tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func initAddressLabelTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS address_labels (
+		label_id SERIAL PRIMARY KEY,
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
+		address VARCHAR(100) NOT NULL CHECK (address <> ''),
+		label VARCHAR(50) NOT NULL CHECK (label <> ''),
+		source VARCHAR(20) NOT NULL DEFAULT 'detected',
+		detail TEXT DEFAULT '',
+		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_address_labels_address ON address_labels(chain_id, address, label);`
+
+	_, err := db.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to create address_labels table and indexes: %v", err)
+	}
+	fmt.Println("AddressLabels table and indexes checked/created.")
+}
+
+// CreateAddressLabel labels an address on a chain. Labeling an address again
+// with the same label keeps the first label.
+func CreateAddressLabel(label AddressLabel) error {
+	query := `
+	INSERT INTO address_labels (chain_id, address, label, source, detail, created_at)
+	VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'detected'), $5, $6)
+	ON CONFLICT (chain_id, address, label) DO NOTHING`
+	_, err := db.Exec(query, label.ChainID, label.Address, label.Label, label.Source, label.Detail, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to create address label: %w", err)
+	}
+	return nil
+}
+
+// DeleteAddressLabel removes a label from an address on a chain. It returns
+// sql.ErrNoRows if the address does not carry the label.
+func DeleteAddressLabel(chainID uint64, address, label string) error {
+	query := `DELETE FROM address_labels WHERE chain_id = $1 AND address = $2 AND label = $3`
+	result, err := db.Exec(query, chainID, address, label)
+	if err != nil {
+		return fmt.Errorf("failed to delete address label: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete address label: %w", err)
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAddressLabels returns the labels of a chain, optionally narrowed to one
+// address (address != "").
+func GetAddressLabels(chainID uint64, address string) ([]AddressLabel, error) {
+	query := `
+	SELECT label_id, chain_id, address, label, source, detail, created_at
+	FROM address_labels
+	WHERE chain_id = $1 AND ($2 = '' OR address = $2)
+	ORDER BY label_id`
+	rows, err := db.Query(query, chainID, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get address labels for chain_id %d: %w", chainID, err)
+	}
+	defer rows.Close()
+
+	var labels []AddressLabel
+	for rows.Next() {
+		var label AddressLabel
+		err := rows.Scan(&label.LabelID, &label.ChainID, &label.Address, &label.Label, &label.Source, &label.Detail, &label.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan address label: %w", err)
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
+
+// GetLabeledAddresses returns the addresses of a chain carrying any of the
+// given labels.
+func GetLabeledAddresses(chainID uint64, labels []string) (map[string]bool, error) {
+	query := `SELECT DISTINCT address FROM address_labels WHERE chain_id = $1 AND label = ANY($2)`
+	rows, err := db.Query(query, chainID, pq.Array(labels))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labeled addresses for chain_id %d: %w", chainID, err)
+	}
+	defer rows.Close()
+
+	addresses := make(map[string]bool)
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, fmt.Errorf("failed to scan labeled address: %w", err)
+		}
+		addresses[address] = true
+	}
+	return addresses, rows.Err()
+}
+
+// IsAddressLabeled reports whether the address carries any of the given
+// labels on the chain.
+func IsAddressLabeled(chainID uint64, address string, labels []string) (bool, error) {
+	var labeled bool
+	query := `SELECT EXISTS (SELECT 1 FROM address_labels WHERE chain_id = $1 AND address = $2 AND label = ANY($3))`
+	err := db.QueryRow(query, chainID, address, pq.Array(labels)).Scan(&labeled)
+	if err != nil {
+		return false, fmt.Errorf("failed to check address label: %w", err)
+	}
+	return labeled, nil
+}
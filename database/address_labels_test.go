@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestCreateAddressLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		label   AddressLabel
+		wantErr bool
+	}{
+		{
+			name:    "Success - Create address label",
+			label:   AddressLabel{ChainID: 1, Address: "0xTestCreateAddressLabel", Label: "sandwich", Detail: "front-ran tx 0x1"},
+			wantErr: false,
+		},
+		{
+			name:    "Success - Label the address again",
+			label:   AddressLabel{ChainID: 1, Address: "0xTestCreateAddressLabel", Label: "sandwich", Detail: "front-ran tx 0x2"},
+			wantErr: false,
+		},
+		{
+			name:    "Fail - Empty label",
+			label:   AddressLabel{ChainID: 1, Address: "0xTestCreateAddressLabel"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CreateAddressLabel(tt.label); (err != nil) != tt.wantErr {
+				t.Errorf("CreateAddressLabel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	labels, _ := GetAddressLabels(1, "0xTestCreateAddressLabel")
+	if len(labels) != 1 || labels[0].Detail != "front-ran tx 0x1" || labels[0].Source != "detected" {
+		t.Errorf("GetAddressLabels() = %v, want the first label only", labels)
+	}
+}
+
+func TestGetLabeledAddresses(t *testing.T) {
+	// nolint
+	CreateAddressLabel(AddressLabel{ChainID: 5, Address: "0xbot", Label: "sandwich"})
+	// nolint
+	CreateAddressLabel(AddressLabel{ChainID: 5, Address: "0xsearcher", Label: "mev_bot", Source: "manual"})
+	// nolint
+	CreateAddressLabel(AddressLabel{ChainID: 5, Address: "0xfund", Label: "market_maker", Source: "manual"})
+	// nolint
+	CreateAddressLabel(AddressLabel{ChainID: 6, Address: "0xother", Label: "sandwich"})
+
+	got, err := GetLabeledAddresses(5, []string{"sandwich", "mev_bot"})
+	if err != nil {
+		t.Fatalf("GetLabeledAddresses() error = %v", err)
+	}
+	want := map[string]bool{"0xbot": true, "0xsearcher": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLabeledAddresses() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		want    bool
+	}{
+		{name: "Success - Excluded label", address: "0xsearcher", want: true},
+		{name: "Success - Other label", address: "0xfund", want: false},
+		{name: "Success - Label on another chain", address: "0xother", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsAddressLabeled(5, tt.address, []string{"sandwich", "mev_bot"})
+			if err != nil {
+				t.Errorf("IsAddressLabeled() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("IsAddressLabeled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAddressLabel(t *testing.T) {
+	// nolint
+	CreateAddressLabel(AddressLabel{ChainID: 7, Address: "0xTestDeleteAddressLabel", Label: "sandwich"})
+	// nolint
+	CreateAddressLabel(AddressLabel{ChainID: 7, Address: "0xTestDeleteAddressLabel", Label: "mev_bot"})
+
+	if err := DeleteAddressLabel(7, "0xTestDeleteAddressLabel", "sandwich"); err != nil {
+		t.Fatalf("DeleteAddressLabel() error = %v", err)
+	}
+	if err := DeleteAddressLabel(7, "0xTestDeleteAddressLabel", "sandwich"); err != sql.ErrNoRows {
+		t.Errorf("DeleteAddressLabel() error = %v, want sql.ErrNoRows", err)
+	}
+	labels, _ := GetAddressLabels(7, "0xTestDeleteAddressLabel")
+	if len(labels) != 1 || labels[0].Label != "mev_bot" {
+		t.Errorf("GetAddressLabels() = %v, want the other label only", labels)
+	}
+}
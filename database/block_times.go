@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"log"
+)
+
+func initBlockTimeTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS block_times (
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
+		block_number BIGINT NOT NULL CHECK (block_number >= 0),
+		block_time BIGINT NOT NULL CHECK (block_time >= 0)
+	);
+	ALTER TABLE block_times ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0);
+	ALTER TABLE block_times DROP CONSTRAINT IF EXISTS block_times_pkey;
+	DROP INDEX IF EXISTS idx_block_times_time;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_block_times_chain_block ON block_times(chain_id, block_number);
+	CREATE INDEX IF NOT EXISTS idx_block_times_chain_time ON block_times(chain_id, block_time);`
+
+	_, err := db.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to create block_times table: %v", err)
+	}
+	fmt.Println("BlockTimes table checked/created.")
+}
+
+func GetBlockTime(chainID uint64, blockNumber uint64) (int64, error) {
+	var blockTime int64
+	query := `SELECT block_time FROM block_times WHERE chain_id = $1 AND block_number = $2`
+	err := db.QueryRow(query, chainID, blockNumber).Scan(&blockTime)
+	return blockTime, err
+}
+
+func InsertBlockTime(chainID uint64, blockNumber uint64, blockTime int64) error {
+	query := `INSERT INTO block_times (chain_id, block_number, block_time) VALUES ($1, $2, $3) ON CONFLICT (chain_id, block_number) DO NOTHING`
+	_, err := db.Exec(query, chainID, blockNumber, blockTime)
+	if err != nil {
+		return fmt.Errorf("failed to insert block time: %w", err)
+	}
+	return nil
+}
+
+// GetBlockTimeFloor returns the latest indexed block of the chain mined at or
+// before blockTime.
+func GetBlockTimeFloor(chainID uint64, blockTime int64) (uint64, int64, error) {
+	var number uint64
+	var t int64
+	query := `SELECT block_number, block_time FROM block_times WHERE chain_id = $1 AND block_time <= $2 ORDER BY block_time DESC, block_number DESC LIMIT 1`
+	err := db.QueryRow(query, chainID, blockTime).Scan(&number, &t)
+	return number, t, err
+}
+
+// GetBlockTimeCeiling returns the earliest indexed block of the chain mined
+// after blockTime.
+func GetBlockTimeCeiling(chainID uint64, blockTime int64) (uint64, int64, error) {
+	var number uint64
+	var t int64
+	query := `SELECT block_number, block_time FROM block_times WHERE chain_id = $1 AND block_time > $2 ORDER BY block_time ASC, block_number ASC LIMIT 1`
+	err := db.QueryRow(query, chainID, blockTime).Scan(&number, &t)
+	return number, t, err
+}
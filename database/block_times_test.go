@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestInsertBlockTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockNumber uint64
+		blockTime   int64
+		want        int64
+	}{
+		{
+			name:        "Success - Insert block time",
+			blockNumber: 100,
+			blockTime:   1633072800,
+			want:        1633072800,
+		},
+		{
+			name:        "Success - Existing block time is kept",
+			blockNumber: 100,
+			blockTime:   1633072812,
+			want:        1633072800,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := InsertBlockTime(1, tt.blockNumber, tt.blockTime); err != nil {
+				t.Errorf("InsertBlockTime() error = %v", err)
+				return
+			}
+			got, err := GetBlockTime(1, tt.blockNumber)
+			if err != nil {
+				t.Errorf("GetBlockTime() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetBlockTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBlockTime(t *testing.T) {
+	_, err := GetBlockTime(1, 99999999)
+	if err != sql.ErrNoRows {
+		t.Errorf("GetBlockTime() error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	// nolint
+	InsertBlockTime(1, 300, 3000)
+	_, err = GetBlockTime(42161, 300)
+	if err != sql.ErrNoRows {
+		t.Errorf("GetBlockTime() of another chain error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestGetBlockTimeFloorCeiling(t *testing.T) {
+	// nolint
+	InsertBlockTime(1, 200, 2000)
+	// nolint
+	InsertBlockTime(1, 210, 2120)
+	// nolint
+	InsertBlockTime(10, 5000, 2050)
+
+	number, blockTime, err := GetBlockTimeFloor(1, 2100)
+	if err != nil || number != 200 || blockTime != 2000 {
+		t.Errorf("GetBlockTimeFloor() = %v, %v, %v, want 200, 2000", number, blockTime, err)
+	}
+	number, blockTime, err = GetBlockTimeCeiling(1, 2100)
+	if err != nil || number != 210 || blockTime != 2120 {
+		t.Errorf("GetBlockTimeCeiling() = %v, %v, %v, want 210, 2120", number, blockTime, err)
+	}
+}
@@ -11,14 +11,21 @@ func initCampaignTable() {
 	query := `
 	CREATE TABLE IF NOT EXISTS campaigns (
 		campaign_id SERIAL PRIMARY KEY,
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
 		name VARCHAR(50) NOT NULL,
 		pool_address VARCHAR(100) NOT NULL CHECK (pool_address <> ''),
+		protocol VARCHAR(20) NOT NULL DEFAULT 'uniswap_v2',
+		attribution VARCHAR(20) NOT NULL DEFAULT 'sender',
 		start_time BIGINT NOT NULL CHECK (start_time >= 0),
 		end_time BIGINT NOT NULL CHECK (end_time > start_time),
 		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW()),
 		updated_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
 		);
+	ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS protocol VARCHAR(20) NOT NULL DEFAULT 'uniswap_v2';
+	ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS attribution VARCHAR(20) NOT NULL DEFAULT 'sender';
+	ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0);
 	CREATE INDEX IF NOT EXISTS idx_pool_address ON campaigns(pool_address);
+	CREATE INDEX IF NOT EXISTS idx_campaign_chain_pool ON campaigns(chain_id, pool_address);
 	CREATE INDEX IF NOT EXISTS idx_campaign_time ON campaigns(start_time, end_time);`
 	_, err := db.Exec(query)
 	if err != nil {
@@ -29,8 +36,8 @@ func initCampaignTable() {
 
 func GetCampaignByID(id int) (*Campaign, error) {
 	var campaign Campaign
-	query := `SELECT campaign_id, name, pool_address, start_time, end_time FROM campaigns WHERE campaign_id = $1`
-	err := db.QueryRow(query, id).Scan(&campaign.CampaignID, &campaign.Name, &campaign.PoolAddress, &campaign.StartTime, &campaign.EndTime)
+	query := `SELECT campaign_id, chain_id, name, pool_address, protocol, attribution, start_time, end_time FROM campaigns WHERE campaign_id = $1`
+	err := db.QueryRow(query, id).Scan(&campaign.CampaignID, &campaign.ChainID, &campaign.Name, &campaign.PoolAddress, &campaign.Protocol, &campaign.Attribution, &campaign.StartTime, &campaign.EndTime)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("campaign with ID %d not found", id)
@@ -40,10 +47,11 @@ func GetCampaignByID(id int) (*Campaign, error) {
 	return &campaign, nil
 }
 
-func GetCampaignsByAddress(address string) ([]Campaign, error) {
+// GetCampaignsByAddress returns the campaigns of a pool on the given chain.
+func GetCampaignsByAddress(chainID uint64, address string) ([]Campaign, error) {
 	var campaigns []Campaign
-	query := `SELECT campaign_id, name, pool_address, start_time, end_time FROM campaigns WHERE pool_address = $1`
-	rows, err := db.Query(query, address)
+	query := `SELECT campaign_id, chain_id, name, pool_address, protocol, attribution, start_time, end_time FROM campaigns WHERE chain_id = $1 AND pool_address = $2`
+	rows, err := db.Query(query, chainID, address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query campaigns by address: %w", err)
 	}
@@ -51,7 +59,7 @@ func GetCampaignsByAddress(address string) ([]Campaign, error) {
 
 	for rows.Next() {
 		var campaign Campaign
-		if err := rows.Scan(&campaign.CampaignID, &campaign.Name, &campaign.PoolAddress, &campaign.StartTime, &campaign.EndTime); err != nil {
+		if err := rows.Scan(&campaign.CampaignID, &campaign.ChainID, &campaign.Name, &campaign.PoolAddress, &campaign.Protocol, &campaign.Attribution, &campaign.StartTime, &campaign.EndTime); err != nil {
 			return nil, fmt.Errorf("failed to scan campaign: %w", err)
 		}
 		campaigns = append(campaigns, campaign)
@@ -59,21 +67,23 @@ func GetCampaignsByAddress(address string) ([]Campaign, error) {
 	return campaigns, nil
 }
 
-func CreateCampaign(name, poolAddress string, startTime, endTime int64) (int, error) {
+func CreateCampaign(chainID uint64, name, poolAddress, protocol, attribution string, startTime, endTime int64) (int, error) {
 	var campaignID int
-	query := `INSERT INTO campaigns (name, pool_address, start_time, end_time, created_at) 
-	VALUES ($1, $2, $3, $4, $5) RETURNING campaign_id`
-	err := db.QueryRow(query, name, poolAddress, startTime, endTime, time.Now().Unix()).Scan(&campaignID)
+	query := `INSERT INTO campaigns (chain_id, name, pool_address, protocol, attribution, start_time, end_time, created_at) 
+	VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'uniswap_v2'), COALESCE(NULLIF($5, ''), 'sender'), $6, $7, $8) RETURNING campaign_id`
+	err := db.QueryRow(query, chainID, name, poolAddress, protocol, attribution, startTime, endTime, time.Now().Unix()).Scan(&campaignID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create campaign: %w", err)
 	}
 	return campaignID, nil
 }
 
-func GetActiveCampaignAddresses() ([]string, error) {
+// GetActiveCampaignAddresses returns the pools with a running campaign on the
+// given chain.
+func GetActiveCampaignAddresses(chainID uint64) ([]string, error) {
 	now := time.Now().Unix()
-	query := `SELECT DISTINCT pool_address FROM campaigns WHERE start_time <= $1 AND end_time >= $1`
-	rows, err := db.Query(query, now)
+	query := `SELECT DISTINCT pool_address FROM campaigns WHERE chain_id = $1 AND start_time <= $2 AND end_time >= $2`
+	rows, err := db.Query(query, chainID, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active campaigns: %w", err)
 	}
@@ -8,25 +8,63 @@ import (
 
 func TestCreateCampaign(t *testing.T) {
 	type args struct {
+		chainID     uint64
 		name        string
 		poolAddress string
+		protocol    string
+		attribution string
 		startTime   int64
 		endTime     int64
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name            string
+		args            args
+		wantProtocol    string
+		wantAttribution string
+		wantErr         bool
 	}{
 		{
 			name: "Success - Create campaign",
 			args: args{
+				chainID:     1,
 				name:        "Test Campaign",
 				poolAddress: "0x1234567890abcdef",
+				protocol:    "uniswap_v2",
+				attribution: "sender",
 				startTime:   1633072800,
 				endTime:     1633159200,
 			},
-			wantErr: false,
+			wantProtocol:    "uniswap_v2",
+			wantAttribution: "sender",
+			wantErr:         false,
+		},
+		{
+			name: "Success - Create V3 campaign",
+			args: args{
+				chainID:     42161,
+				name:        "Test V3 Campaign",
+				poolAddress: "TestCreateCampaign V3",
+				protocol:    "uniswap_v3",
+				attribution: "origin",
+				startTime:   1633072800,
+				endTime:     1633159200,
+			},
+			wantProtocol:    "uniswap_v3",
+			wantAttribution: "origin",
+			wantErr:         false,
+		},
+		{
+			name: "Success - Default protocol",
+			args: args{
+				chainID:     1,
+				name:        "Test Default Campaign",
+				poolAddress: "TestCreateCampaign default",
+				startTime:   1633072800,
+				endTime:     1633159200,
+			},
+			wantProtocol:    "uniswap_v2",
+			wantAttribution: "sender",
+			wantErr:         false,
 		},
 		{
 			name: "Fail - Empty pool address",
@@ -61,7 +99,7 @@ func TestCreateCampaign(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotID, err := CreateCampaign(tt.args.name, tt.args.poolAddress, tt.args.startTime, tt.args.endTime)
+			gotID, err := CreateCampaign(tt.args.chainID, tt.args.name, tt.args.poolAddress, tt.args.protocol, tt.args.attribution, tt.args.startTime, tt.args.endTime)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateCampaign() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -72,16 +110,17 @@ func TestCreateCampaign(t *testing.T) {
 			}
 
 			if !tt.wantErr {
-				var name, poolAddress string
+				var chainID uint64
+				var name, poolAddress, protocol, attribution string
 				var startTime, endTime int64
-				err := testDB.QueryRow("SELECT name, pool_address, start_time, end_time FROM campaigns WHERE pool_address = $1", tt.args.poolAddress).
-					Scan(&name, &poolAddress, &startTime, &endTime)
+				err := testDB.QueryRow("SELECT chain_id, name, pool_address, protocol, attribution, start_time, end_time FROM campaigns WHERE pool_address = $1", tt.args.poolAddress).
+					Scan(&chainID, &name, &poolAddress, &protocol, &attribution, &startTime, &endTime)
 				if err != nil {
 					t.Errorf("Failed to retrieve campaign from database: %v", err)
 				}
-				if name != tt.args.name || poolAddress != tt.args.poolAddress || startTime != tt.args.startTime || endTime != tt.args.endTime {
-					t.Errorf("Campaign data mismatch. Got name=%v, poolAddress=%v, startTime=%v, endTime=%v",
-						name, poolAddress, startTime, endTime)
+				if chainID != tt.args.chainID || name != tt.args.name || poolAddress != tt.args.poolAddress || protocol != tt.wantProtocol || attribution != tt.wantAttribution || startTime != tt.args.startTime || endTime != tt.args.endTime {
+					t.Errorf("Campaign data mismatch. Got chainID=%v, name=%v, poolAddress=%v, protocol=%v, attribution=%v, startTime=%v, endTime=%v",
+						chainID, name, poolAddress, protocol, attribution, startTime, endTime)
 				}
 			}
 		})
@@ -89,7 +128,7 @@ func TestCreateCampaign(t *testing.T) {
 }
 
 func TestGetCampaignByID(t *testing.T) {
-	campaignID, _ := CreateCampaign("Test Campaign", "0x1234567890abcdef", 1633072800, 1633159200)
+	campaignID, _ := CreateCampaign(1, "Test Campaign", "0x1234567890abcdef", "uniswap_v2", "sender", 1633072800, 1633159200)
 	type args struct {
 		id int
 	}
@@ -126,8 +165,9 @@ func TestGetCampaignByID(t *testing.T) {
 }
 
 func TestGetCampaignsByAddress(t *testing.T) {
-	campaignID, _ := CreateCampaign("Test Campaign", "TestGetCampaignsByAddress", 1633072800, 1633159200)
+	campaignID, _ := CreateCampaign(1, "Test Campaign", "TestGetCampaignsByAddress", "uniswap_v2", "sender", 1633072800, 1633159200)
 	type args struct {
+		chainID uint64
 		address string
 	}
 	tests := []struct {
@@ -139,11 +179,13 @@ func TestGetCampaignsByAddress(t *testing.T) {
 		{
 			name: "Success - Get campaigns by address",
 			args: args{
+				chainID: 1,
 				address: "TestGetCampaignsByAddress",
 			},
 			want: []Campaign{
 				{
 					CampaignID:  campaignID,
+					ChainID:     1,
 					Name:        "Test Campaign",
 					PoolAddress: "TestGetCampaignsByAddress",
 					StartTime:   1633072800,
@@ -155,24 +197,35 @@ func TestGetCampaignsByAddress(t *testing.T) {
 		{
 			name: "Success - No campaigns found",
 			args: args{
+				chainID: 1,
 				address: "No campaigns found",
 			},
 			want:    []Campaign{},
 			wantErr: false,
 		},
+		{
+			name: "Success - Same address on another chain",
+			args: args{
+				chainID: 10,
+				address: "TestGetCampaignsByAddress",
+			},
+			want:    []Campaign{},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetCampaignsByAddress(tt.args.address)
+			got, err := GetCampaignsByAddress(tt.args.chainID, tt.args.address)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetCampaignsByAddress() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if len(tt.want) == 0 && len(got) == 0 {
+			if len(tt.want) != len(got) {
+				t.Errorf("GetCampaignsByAddress() = %v, want %v", got, tt.want)
 				return
 			}
 			for i := range tt.want {
-				if !reflect.DeepEqual(got[i].PoolAddress, tt.want[i].PoolAddress) {
+				if !reflect.DeepEqual(got[i].PoolAddress, tt.want[i].PoolAddress) || got[i].ChainID != tt.want[i].ChainID {
 					t.Errorf("GetCampaignsByAddress() = %v, want %v", got, tt.want)
 				}
 			}
@@ -182,22 +235,32 @@ func TestGetCampaignsByAddress(t *testing.T) {
 }
 
 func TestGetActiveCampaignAddresses(t *testing.T) {
-	campaignID, _ := CreateCampaign("Test Campaign", "0x1234567890abcdef", time.Now().Unix()-100, time.Now().Unix()+100)
+	campaignID, _ := CreateCampaign(1, "Test Campaign", "0x1234567890abcdef", "uniswap_v2", "sender", time.Now().Unix()-100, time.Now().Unix()+100)
 	campaign, _ := GetCampaignByID(campaignID)
+	// nolint
+	CreateCampaign(8453, "Test Campaign", "TestGetActiveCampaignAddresses base", "uniswap_v2", "sender", time.Now().Unix()-100, time.Now().Unix()+100)
 	tests := []struct {
 		name    string
+		chainID uint64
 		want    []string
 		wantErr bool
 	}{
 		{
 			name:    "Success - Get active campaign addresses",
+			chainID: 1,
 			want:    []string{campaign.PoolAddress},
 			wantErr: false,
 		},
+		{
+			name:    "Success - Get active campaign addresses of another chain",
+			chainID: 8453,
+			want:    []string{"TestGetActiveCampaignAddresses base"},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetActiveCampaignAddresses()
+			got, err := GetActiveCampaignAddresses(tt.chainID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetActiveCampaignAddresses() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,5 +1,11 @@
 package database
 
+import (
+	"encoding/json"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+)
+
 type User struct {
 	UserID    int
 	Address   string
@@ -7,8 +13,11 @@ type User struct {
 }
 type Campaign struct {
 	CampaignID  int
+	ChainID     uint64
 	Name        string
 	PoolAddress string
+	Protocol    string
+	Attribution string
 	StartTime   int64
 	EndTime     int64
 	CreatedAt   int64
@@ -16,17 +25,15 @@ type Campaign struct {
 }
 
 type Task struct {
-	TaskID              int
-	CampaignID          int
-	Type                string
-	Description         string
-	OnboardingReward    float64
-	OnboardingThreshold float64
-	PointsPool          float64
-	StartTime           int64
-	EndTime             int64
-	CreatedAt           int64
-	UpdatedAt           int64
+	TaskID      int
+	CampaignID  int
+	Type        string
+	Description string
+	Config      json.RawMessage
+	StartTime   int64
+	EndTime     int64
+	CreatedAt   int64
+	UpdatedAt   int64
 }
 
 type UserTask struct {
@@ -34,8 +41,8 @@ type UserTask struct {
 	UserID     int
 	TaskID     int
 	Completed  bool
-	Amount     float64
-	Points     float64
+	Amount     decimal.Decimal
+	Points     decimal.Decimal
 	CreatedAt  int64
 	UpdatedAt  int64
 }
@@ -45,17 +52,66 @@ type UserPointsHistory struct {
 	UserID     int
 	TaskID     int
 	CampaignID int
-	Points     float64
+	Points     decimal.Decimal
 	CreatedAt  int64
 }
 
 type UserSwap struct {
-	SwapID          int
-	UserID          int
+	SwapID           int
+	ChainID          uint64
+	UserID           int
+	TransactionHash  string
+	PoolAddress      string
+	SenderAddress    string
+	RecipientAddress string
+	OriginAddress    string
+	AmountUSDC       decimal.Decimal
+	AmountWETH       decimal.Decimal
+	Amount0In        decimal.Decimal
+	Amount1In        decimal.Decimal
+	Amount0Out       decimal.Decimal
+	Amount1Out       decimal.Decimal
+	SwapTime         int64
+	BlockNumber      uint64
+	BlockHash        string
+	LogIndex         uint
+	TxIndex          uint
+	CreatedAt        int64
+}
+
+type Pool struct {
+	ChainID        uint64
+	PoolAddress    string
+	Protocol       string
+	Token0         string
+	Token1         string
+	Token0Symbol   string
+	Token1Symbol   string
+	Token0Decimals int
+	Token1Decimals int
+	CreatedAt      int64
+}
+
+type SwapFlag struct {
+	FlagID          int
+	ChainID         uint64
 	TransactionHash string
-	PoolAddress     string
-	AmountUSDC      float64
-	AmountWETH      float64
-	SwapTime        int64
+	LogIndex        uint
+	CampaignID      int
+	TaskID          int
+	TraderAddress   string
+	Reason          string
+	Detail          string
+	AmountUSD       decimal.Decimal
 	CreatedAt       int64
 }
+
+type AddressLabel struct {
+	LabelID   int
+	ChainID   uint64
+	Address   string
+	Label     string
+	Source    string
+	Detail    string
+	CreatedAt int64
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func initIngestionCheckpointTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS ingestion_checkpoints (
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
+		pool_address VARCHAR(100) NOT NULL CHECK (pool_address <> ''),
+		last_block BIGINT NOT NULL CHECK (last_block >= 0),
+		updated_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
+	);
+	ALTER TABLE ingestion_checkpoints ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0);
+	ALTER TABLE ingestion_checkpoints DROP CONSTRAINT IF EXISTS ingestion_checkpoints_pkey;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_ingestion_checkpoints_chain_pool ON ingestion_checkpoints(chain_id, pool_address);`
+
+	_, err := db.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to create ingestion_checkpoints table: %v", err)
+	}
+	fmt.Println("IngestionCheckpoints table checked/created.")
+}
+
+func GetIngestionCheckpoint(chainID uint64, poolAddress string) (uint64, error) {
+	var lastBlock uint64
+	query := `SELECT last_block FROM ingestion_checkpoints WHERE chain_id = $1 AND pool_address = $2`
+	err := db.QueryRow(query, chainID, poolAddress).Scan(&lastBlock)
+	return lastBlock, err
+}
+
+// UpsertIngestionCheckpoint records lastBlock as fully processed for the pool
+// on the given chain. A checkpoint never moves backwards.
+func UpsertIngestionCheckpoint(chainID uint64, poolAddress string, lastBlock uint64) error {
+	query := `
+	INSERT INTO ingestion_checkpoints (chain_id, pool_address, last_block, updated_at) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (chain_id, pool_address) DO UPDATE
+	SET last_block = GREATEST(ingestion_checkpoints.last_block, EXCLUDED.last_block), updated_at = EXCLUDED.updated_at`
+	_, err := db.Exec(query, chainID, poolAddress, lastBlock, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to upsert ingestion checkpoint: %w", err)
+	}
+	return nil
+}
+
+// TryLockIngestion takes the session advisory lock of a chain's ingestion,
+// keyed by the chain ID, on a connection of its own. ok is false if another
+// session holds it. The lock and its connection are kept until unlock is
+// called.
+func TryLockIngestion(chainID uint64) (unlock func(), ok bool, err error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, int64(chainID)).Scan(&ok)
+	if err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to lock ingestion of chain %d: %w", chainID, err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, int64(chainID))
+		if err != nil {
+			log.Printf("Failed to unlock ingestion of chain %d: %v", chainID, err)
+		}
+		conn.Close()
+	}, true, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestUpsertIngestionCheckpoint(t *testing.T) {
+	poolAddress := "TestUpsertIngestionCheckpoint"
+	tests := []struct {
+		name      string
+		lastBlock uint64
+		want      uint64
+	}{
+		{
+			name:      "Success - Create checkpoint",
+			lastBlock: 100,
+			want:      100,
+		},
+		{
+			name:      "Success - Advance checkpoint",
+			lastBlock: 150,
+			want:      150,
+		},
+		{
+			name:      "Success - Checkpoint does not move backwards",
+			lastBlock: 120,
+			want:      150,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UpsertIngestionCheckpoint(1, poolAddress, tt.lastBlock); err != nil {
+				t.Errorf("UpsertIngestionCheckpoint() error = %v", err)
+				return
+			}
+			got, err := GetIngestionCheckpoint(1, poolAddress)
+			if err != nil {
+				t.Errorf("GetIngestionCheckpoint() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetIngestionCheckpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetIngestionCheckpoint(t *testing.T) {
+	_, err := GetIngestionCheckpoint(1, "TestGetIngestionCheckpoint")
+	if err != sql.ErrNoRows {
+		t.Errorf("GetIngestionCheckpoint() error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	// nolint
+	UpsertIngestionCheckpoint(1, "TestGetIngestionCheckpoint mainnet", 100)
+	_, err = GetIngestionCheckpoint(8453, "TestGetIngestionCheckpoint mainnet")
+	if err != sql.ErrNoRows {
+		t.Errorf("GetIngestionCheckpoint() of another chain error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestTryLockIngestion(t *testing.T) {
+	unlock, ok, err := TryLockIngestion(99991)
+	if err != nil || !ok {
+		t.Errorf("TryLockIngestion() = %v, %v, want the lock", ok, err)
+		return
+	}
+	_, ok, err = TryLockIngestion(99991)
+	if err != nil || ok {
+		t.Errorf("TryLockIngestion() of a locked chain = %v, %v, want false", ok, err)
+	}
+	unlock()
+
+	unlock, ok, err = TryLockIngestion(99991)
+	if err != nil || !ok {
+		t.Errorf("TryLockIngestion() after unlock = %v, %v, want the lock", ok, err)
+		return
+	}
+	unlock()
+}
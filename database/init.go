@@ -13,20 +13,22 @@ import (
 var db *sql.DB
 
 type DBConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
+	Host         string
+	Port         int
+	User         string
+	Password     string
+	DBName       string
+	MaxOpenConns int
 }
 
 func loadConfig() DBConfig {
 	return DBConfig{
-		Host:     viper.GetString("database.host"),
-		Port:     viper.GetInt("database.port"),
-		User:     viper.GetString("database.user"),
-		Password: viper.GetString("database.password"),
-		DBName:   viper.GetString("database.dbname"),
+		Host:         viper.GetString("database.host"),
+		Port:         viper.GetInt("database.port"),
+		User:         viper.GetString("database.user"),
+		Password:     viper.GetString("database.password"),
+		DBName:       viper.GetString("database.dbname"),
+		MaxOpenConns: viper.GetInt("database.max_open_conns"),
 	}
 }
 
@@ -83,6 +85,7 @@ func InitPostgreSQL() *sql.DB {
 	}
 
 	fmt.Printf("Connected to database %s successfully.\n", config.DBName)
+	db.SetMaxOpenConns(config.MaxOpenConns)
 
 	initTable()
 	return db
@@ -95,4 +98,9 @@ func initTable() {
 	initUserTaskTable()
 	initUserPointsHistoryTable()
 	initUserSwapTable()
+	initIngestionCheckpointTable()
+	initBlockTimeTable()
+	initPoolTable()
+	initSwapFlagTable()
+	initAddressLabelTable()
 }
@@ -30,7 +30,7 @@ func TestMain(m *testing.M) {
 }
 
 func cleanupDatabase() {
-	_, err := testDB.Exec("DROP TABLE IF EXISTS user_swaps, user_points_history, user_tasks, tasks, campaigns, users CASCADE")
+	_, err := testDB.Exec("DROP TABLE IF EXISTS address_labels, swap_flags, pools, block_times, ingestion_checkpoints, user_swaps, user_points_history, user_tasks, tasks, campaigns, users CASCADE")
 	if err != nil {
 		log.Printf("Failed to clean up database: %v", err)
 	}
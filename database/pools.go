@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+func initPoolTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS pools (
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
+		pool_address VARCHAR(100) NOT NULL CHECK (pool_address <> ''),
+		protocol VARCHAR(20) NOT NULL DEFAULT 'uniswap_v2',
+		token0 VARCHAR(100) NOT NULL,
+		token1 VARCHAR(100) NOT NULL,
+		token0_symbol VARCHAR(50) DEFAULT '',
+		token1_symbol VARCHAR(50) DEFAULT '',
+		token0_decimals INT NOT NULL CHECK (token0_decimals >= 0),
+		token1_decimals INT NOT NULL CHECK (token1_decimals >= 0),
+		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
+	);
+	ALTER TABLE pools ADD COLUMN IF NOT EXISTS protocol VARCHAR(20) NOT NULL DEFAULT 'uniswap_v2';
+	ALTER TABLE pools ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0);
+	ALTER TABLE pools DROP CONSTRAINT IF EXISTS pools_pkey;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_pools_chain_pool ON pools(chain_id, pool_address);`
+
+	_, err := db.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to create pools table: %v", err)
+	}
+	fmt.Println("Pools table checked/created.")
+}
+
+func CreatePool(pool Pool) error {
+	query := `
+	INSERT INTO pools (chain_id, pool_address, protocol, token0, token1, token0_symbol, token1_symbol, token0_decimals, token1_decimals, created_at)
+	VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'uniswap_v2'), $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (chain_id, pool_address) DO UPDATE
+	SET protocol = EXCLUDED.protocol, token0 = EXCLUDED.token0, token1 = EXCLUDED.token1, token0_symbol = EXCLUDED.token0_symbol, token1_symbol = EXCLUDED.token1_symbol,
+		token0_decimals = EXCLUDED.token0_decimals, token1_decimals = EXCLUDED.token1_decimals`
+	_, err := db.Exec(query, pool.ChainID, pool.PoolAddress, pool.Protocol, pool.Token0, pool.Token1, pool.Token0Symbol, pool.Token1Symbol, pool.Token0Decimals, pool.Token1Decimals, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to create pool: %w", err)
+	}
+	return nil
+}
+
+func GetPoolByAddress(chainID uint64, poolAddress string) (*Pool, error) {
+	var pool Pool
+	query := `SELECT chain_id, pool_address, protocol, token0, token1, token0_symbol, token1_symbol, token0_decimals, token1_decimals, created_at FROM pools WHERE chain_id = $1 AND pool_address = $2`
+	err := db.QueryRow(query, chainID, poolAddress).Scan(&pool.ChainID, &pool.PoolAddress, &pool.Protocol, &pool.Token0, &pool.Token1, &pool.Token0Symbol, &pool.Token1Symbol, &pool.Token0Decimals, &pool.Token1Decimals, &pool.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
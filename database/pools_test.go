@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCreatePool(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    Pool
+		wantErr bool
+	}{
+		{
+			name: "Success - Create pool",
+			pool: Pool{
+				ChainID:        1,
+				PoolAddress:    "TestCreatePool",
+				Token0:         "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+				Token1:         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+				Token0Symbol:   "USDC",
+				Token1Symbol:   "WETH",
+				Token0Decimals: 6,
+				Token1Decimals: 18,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success - Update existing pool",
+			pool: Pool{
+				ChainID:        1,
+				PoolAddress:    "TestCreatePool",
+				Token0:         "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+				Token1:         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+				Token0Symbol:   "USDC",
+				Token1Symbol:   "WETH",
+				Token0Decimals: 6,
+				Token1Decimals: 18,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success - Same pool address on another chain",
+			pool: Pool{
+				ChainID:        42161,
+				PoolAddress:    "TestCreatePool",
+				Token0:         "0xaf88d065e77c8cC2239327C5EDb3A432268e5831",
+				Token1:         "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1",
+				Token0Symbol:   "USDC",
+				Token1Symbol:   "WETH",
+				Token0Decimals: 6,
+				Token1Decimals: 18,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fail - Empty pool address",
+			pool: Pool{
+				ChainID:        1,
+				Token0Decimals: 6,
+				Token1Decimals: 18,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CreatePool(tt.pool); (err != nil) != tt.wantErr {
+				t.Errorf("CreatePool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetPoolByAddress(t *testing.T) {
+	// nolint
+	CreatePool(Pool{ChainID: 1, PoolAddress: "TestGetPoolByAddress", Protocol: "uniswap_v3", Token0: "0x1", Token1: "0x2", Token0Decimals: 6, Token1Decimals: 18})
+
+	pool, err := GetPoolByAddress(1, "TestGetPoolByAddress")
+	if err != nil {
+		t.Errorf("GetPoolByAddress() error = %v", err)
+		return
+	}
+	if pool.ChainID != 1 || pool.Protocol != "uniswap_v3" || pool.Token0 != "0x1" || pool.Token1 != "0x2" || pool.Token0Decimals != 6 || pool.Token1Decimals != 18 {
+		t.Errorf("GetPoolByAddress() = %v", pool)
+	}
+
+	_, err = GetPoolByAddress(1, "TestGetPoolByAddress not found")
+	if err != sql.ErrNoRows {
+		t.Errorf("GetPoolByAddress() error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	_, err = GetPoolByAddress(10, "TestGetPoolByAddress")
+	if err != sql.ErrNoRows {
+		t.Errorf("GetPoolByAddress() error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+func initSwapFlagTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS swap_flags (
+		flag_id SERIAL PRIMARY KEY,
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
+		transaction_hash VARCHAR(100) NOT NULL,
+		log_index INT NOT NULL,
+		campaign_id INT REFERENCES campaigns(campaign_id) ON DELETE CASCADE,
+		task_id INT REFERENCES tasks(task_id) ON DELETE CASCADE,
+		trader_address VARCHAR(100) NOT NULL,
+		reason VARCHAR(50) NOT NULL,
+		detail TEXT DEFAULT '',
+		amount_usd NUMERIC DEFAULT 0,
+		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_swap_flags_swap ON swap_flags(task_id, chain_id, transaction_hash, log_index, reason);
+	CREATE INDEX IF NOT EXISTS idx_swap_flags_campaign ON swap_flags(campaign_id);`
+
+	_, err := db.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to create swap_flags table and indexes: %v", err)
+	}
+	fmt.Println("SwapFlags table and indexes checked/created.")
+}
+
+// CreateSwapFlag records why a swap was discounted when its task was settled.
+// Flagging the same swap twice for the same task and reason is a no-op.
+func CreateSwapFlag(flag SwapFlag) error {
+	query := `
+	INSERT INTO swap_flags (chain_id, transaction_hash, log_index, campaign_id, task_id, trader_address, reason, detail, amount_usd, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (task_id, chain_id, transaction_hash, log_index, reason) DO NOTHING`
+	_, err := db.Exec(query, flag.ChainID, flag.TransactionHash, flag.LogIndex, flag.CampaignID, flag.TaskID, flag.TraderAddress, flag.Reason, flag.Detail, flag.AmountUSD, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to create swap flag: %w", err)
+	}
+	return nil
+}
+
+// GetSwapFlags returns the flags of a campaign, optionally narrowed to one task
+// (taskID > 0) or one trader (traderAddress != "").
+func GetSwapFlags(campaignID, taskID int, traderAddress string) ([]SwapFlag, error) {
+	query := `
+	SELECT flag_id, chain_id, transaction_hash, log_index, campaign_id, task_id, trader_address, reason, detail, amount_usd, created_at
+	FROM swap_flags
+	WHERE campaign_id = $1 AND ($2 = 0 OR task_id = $2) AND ($3 = '' OR trader_address = $3)
+	ORDER BY task_id, flag_id`
+	rows, err := db.Query(query, campaignID, taskID, traderAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get swap flags for campaign_id %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	var flags []SwapFlag
+	for rows.Next() {
+		var flag SwapFlag
+		err := rows.Scan(&flag.FlagID, &flag.ChainID, &flag.TransactionHash, &flag.LogIndex, &flag.CampaignID, &flag.TaskID, &flag.TraderAddress, &flag.Reason, &flag.Detail, &flag.AmountUSD, &flag.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan swap flag: %w", err)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+)
+
+func TestCreateSwapFlag(t *testing.T) {
+	campaignID, _ := CreateCampaign(1, "TestCreateSwapFlag", "TestCreateSwapFlag", "uniswap_v2", "sender", 0, 1)
+	taskID, _ := CreateTask(campaignID, "share_pool", "TestCreateSwapFlag", json.RawMessage(`{"pointsPool":"100"}`), 0, 1)
+	flag := SwapFlag{ChainID: 1, TransactionHash: "0xflag", LogIndex: 1, CampaignID: campaignID, TaskID: taskID, TraderAddress: "0xbot", Reason: "round_trip", AmountUSD: decimal.NewFromInt(10)}
+	invalidTask := flag
+	invalidTask.TaskID = -1
+	tests := []struct {
+		name    string
+		flag    SwapFlag
+		wantErr bool
+	}{
+		{
+			name:    "Success - Create swap flag",
+			flag:    flag,
+			wantErr: false,
+		},
+		{
+			name:    "Success - Flag the same swap again",
+			flag:    flag,
+			wantErr: false,
+		},
+		{
+			name:    "Fail - Unknown task",
+			flag:    invalidTask,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CreateSwapFlag(tt.flag); (err != nil) != tt.wantErr {
+				t.Errorf("CreateSwapFlag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSwapFlags(t *testing.T) {
+	campaignID, _ := CreateCampaign(1, "TestGetSwapFlags", "TestGetSwapFlags", "uniswap_v2", "sender", 0, 2)
+	task1, _ := CreateTask(campaignID, "share_pool", "Round 1", json.RawMessage(`{"pointsPool":"100"}`), 0, 1)
+	task2, _ := CreateTask(campaignID, "share_pool", "Round 2", json.RawMessage(`{"pointsPool":"100"}`), 1, 2)
+	// nolint
+	CreateSwapFlag(SwapFlag{ChainID: 1, TransactionHash: "0x1", LogIndex: 0, CampaignID: campaignID, TaskID: task1, TraderAddress: "0xbot", Reason: "round_trip", Detail: "sold back", AmountUSD: decimal.NewFromInt(10)})
+	// nolint
+	CreateSwapFlag(SwapFlag{ChainID: 1, TransactionHash: "0x1", LogIndex: 0, CampaignID: campaignID, TaskID: task1, TraderAddress: "0xbot", Reason: "net_zero", AmountUSD: decimal.NewFromInt(10)})
+	// nolint
+	CreateSwapFlag(SwapFlag{ChainID: 1, TransactionHash: "0x2", LogIndex: 3, CampaignID: campaignID, TaskID: task2, TraderAddress: "0xother", Reason: "self_funded_loop", AmountUSD: decimal.NewFromInt(5)})
+	type args struct {
+		taskID        int
+		traderAddress string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Success - All flags of the campaign",
+			args: args{},
+			want: []string{"round_trip", "net_zero", "self_funded_loop"},
+		},
+		{
+			name: "Success - Flags of one task",
+			args: args{taskID: task2},
+			want: []string{"self_funded_loop"},
+		},
+		{
+			name: "Success - Flags of one trader",
+			args: args{traderAddress: "0xbot"},
+			want: []string{"round_trip", "net_zero"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetSwapFlags(campaignID, tt.args.taskID, tt.args.traderAddress)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetSwapFlags() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var reasons []string
+			for _, flag := range got {
+				reasons = append(reasons, flag.Reason)
+			}
+			if len(reasons) != len(tt.want) {
+				t.Fatalf("GetSwapFlags() = %v, want %v", reasons, tt.want)
+			}
+			for i := range reasons {
+				if reasons[i] != tt.want[i] {
+					t.Errorf("GetSwapFlags() = %v, want %v", reasons, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -15,14 +16,22 @@ func initTaskTable() {
 		campaign_id INT REFERENCES campaigns(campaign_id) ON DELETE CASCADE,
 		type VARCHAR(50) NOT NULL CHECK (type <> ''),
 		description TEXT,
-		onboarding_reward FLOAT DEFAULT 0 CHECK (onboarding_reward >= 0),
-    	onboarding_threshold FLOAT DEFAULT 0 CHECK (onboarding_threshold >= 0),
-		points_pool FLOAT DEFAULT 0 CHECK (points_pool >= 0),
+		onboarding_reward NUMERIC(38, 6) DEFAULT 0 CHECK (onboarding_reward >= 0),
+    	onboarding_threshold NUMERIC DEFAULT 0 CHECK (onboarding_threshold >= 0),
+		points_pool NUMERIC(38, 6) DEFAULT 0 CHECK (points_pool >= 0),
 		start_time BIGINT NOT NULL CHECK (start_time >= 0),
 		end_time BIGINT NOT NULL CHECK (end_time > start_time),
 		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW()),
 		updated_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
 		);
+	ALTER TABLE tasks ALTER COLUMN onboarding_reward TYPE NUMERIC(38, 6);
+	ALTER TABLE tasks ALTER COLUMN onboarding_threshold TYPE NUMERIC;
+	ALTER TABLE tasks ALTER COLUMN points_pool TYPE NUMERIC(38, 6);
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS config JSONB NOT NULL DEFAULT '{}';
+	UPDATE tasks SET config = jsonb_build_object('reward', onboarding_reward::TEXT, 'threshold', onboarding_threshold::TEXT)
+	WHERE type = 'onboarding' AND config = '{}';
+	UPDATE tasks SET config = jsonb_build_object('pointsPool', points_pool::TEXT)
+	WHERE type = 'share_pool' AND config = '{}';
 	CREATE INDEX IF NOT EXISTS idx_campaign_id ON tasks(campaign_id);
 	CREATE INDEX IF NOT EXISTS idx_task_type ON tasks(type);
 	CREATE INDEX IF NOT EXISTS idx_task_time ON tasks(start_time, end_time);`
@@ -34,50 +43,50 @@ func initTaskTable() {
 	fmt.Println("Tasks table and indexes checked/created.")
 }
 
-func CreateTask(campaignID int, taskType, description string, onboardingReward float64, onboardingThreshold float64, pointsPool float64, startTime, endTime int64) (int, error) {
+// CreateTask stores a task of the given type. Its config is a JSON object
+// decoded by the task type, which validates it before the task is created.
+func CreateTask(campaignID int, taskType, description string, config json.RawMessage, startTime, endTime int64) (int, error) {
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
 	var taskID int
-	query := `INSERT INTO tasks (campaign_id, type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time, created_at) 
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING task_id`
-	err := db.QueryRow(query, campaignID, taskType, description, onboardingReward, onboardingThreshold, pointsPool, startTime, endTime, time.Now().Unix()).Scan(&taskID)
+	query := `INSERT INTO tasks (campaign_id, type, description, config, start_time, end_time, created_at) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING task_id`
+	err := db.QueryRow(query, campaignID, taskType, description, string(config), startTime, endTime, time.Now().Unix()).Scan(&taskID)
 	return taskID, err
 }
 
-func CreateOnboardingTask(campaignID int, description string, onboardingReward float64, onboardingThreshold float64, startTime, endTime int64) (int, error) {
-	return CreateTask(campaignID, "onboarding", description, onboardingReward, onboardingThreshold, 0, startTime, endTime)
-}
-
-func CreateSharePoolTask(campaignID int, description string, pointsPool float64, startTime, endTime int64) (int, error) {
-	return CreateTask(campaignID, "share_pool", description, 0, 0, pointsPool, startTime, endTime)
-}
-
 func GetTasksByTaskIDs(taskIDs []int) ([]Task, error) {
-	query := `SELECT task_id, campaign_id, type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time FROM tasks WHERE task_id = ANY($1)`
+	query := `SELECT task_id, campaign_id, type, description, config, start_time, end_time FROM tasks WHERE task_id = ANY($1)`
 	return queryTasks(query, pq.Array(taskIDs))
 }
 
 func GetTasksByCampaignID(campaignID int) ([]Task, error) {
-	query := `SELECT task_id, campaign_id, type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time FROM tasks WHERE campaign_id = $1`
+	query := `SELECT task_id, campaign_id, type, description, config, start_time, end_time FROM tasks WHERE campaign_id = $1 ORDER BY task_id`
 	return queryTasks(query, campaignID)
 }
 
 func GetActiveTasksByCampaignID(campaignID int, timestamp int64) ([]Task, error) {
-	query := `SELECT task_id, campaign_id, type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time 
+	query := `SELECT task_id, campaign_id, type, description, config, start_time, end_time 
 	FROM tasks WHERE campaign_id = $1 AND end_time > $2 AND start_time < $2`
 	return queryTasks(query, campaignID, timestamp)
 }
 
-func GetOnboardingTaskByCampaignID(campaignID int) (*Task, error) {
+// GetTaskByCampaignIDAndType returns the first task of the given type of a
+// campaign.
+func GetTaskByCampaignIDAndType(campaignID int, taskType string) (*Task, error) {
 	var task Task
-	query := `SELECT task_id, campaign_id, type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time 
-	FROM tasks WHERE campaign_id = $1 AND type = 'onboarding'`
-	err := db.QueryRow(query, campaignID).Scan(&task.TaskID, &task.CampaignID, &task.Type, &task.Description, &task.OnboardingReward, &task.OnboardingThreshold, &task.PointsPool, &task.StartTime, &task.EndTime)
+	query := `SELECT task_id, campaign_id, type, description, config, start_time, end_time 
+	FROM tasks WHERE campaign_id = $1 AND type = $2 ORDER BY task_id LIMIT 1`
+	err := db.QueryRow(query, campaignID, taskType).Scan(&task.TaskID, &task.CampaignID, &task.Type, &task.Description, (*[]byte)(&task.Config), &task.StartTime, &task.EndTime)
 	return &task, err
-
 }
 
-func GetExpiredSharePoolTasks(now int64, lastCheckTime int64) ([]Task, error) {
-	query := `SELECT task_id, campaign_id, type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time 
-	FROM tasks WHERE end_time < $1 AND end_time > $2 AND type = 'share_pool'`
+// GetExpiredTasks returns the tasks of every type that ended after
+// lastCheckTime and before now, to be settled.
+func GetExpiredTasks(now int64, lastCheckTime int64) ([]Task, error) {
+	query := `SELECT task_id, campaign_id, type, description, config, start_time, end_time 
+	FROM tasks WHERE end_time < $1 AND end_time > $2 ORDER BY end_time, task_id`
 	return queryTasks(query, now, lastCheckTime)
 }
 
@@ -91,7 +100,7 @@ func queryTasks(query string, args ...interface{}) ([]Task, error) {
 
 	for rows.Next() {
 		var task Task
-		err = rows.Scan(&task.TaskID, &task.CampaignID, &task.Type, &task.Description, &task.OnboardingReward, &task.OnboardingThreshold, &task.PointsPool, &task.StartTime, &task.EndTime)
+		err = rows.Scan(&task.TaskID, &task.CampaignID, &task.Type, &task.Description, (*[]byte)(&task.Config), &task.StartTime, &task.EndTime)
 		if err != nil {
 			return nil, err
 		}
@@ -1,176 +1,135 @@
 package database
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
 )
 
 func TestCreateTask(t *testing.T) {
-	campaignID, _ := CreateCampaign("Test Campaign", "0x1234567890abcdef", 1633072800, 1633159200)
+	campaignID, _ := CreateCampaign(1, "Test Campaign", "0x1234567890abcdef", "uniswap_v2", "sender", 1633072800, 1633159200)
 	type args struct {
-		campaignID          int
-		taskType            string
-		description         string
-		onboardingReward    float64
-		onboardingThreshold float64
-		pointsPool          float64
-		startTime           int64
-		endTime             int64
+		campaignID  int
+		taskType    string
+		description string
+		config      json.RawMessage
+		startTime   int64
+		endTime     int64
 	}
 	tests := []struct {
-		name    string
-		args    args
-		want    int
-		wantErr bool
+		name       string
+		args       args
+		wantConfig string
+		wantErr    bool
 	}{
 		{
 			name: "Success - Create task",
 			args: args{
-				campaignID:          campaignID,
-				taskType:            "create test",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				pointsPool:          0,
-				startTime:           123,
-				endTime:             234,
-			},
-			want:    1,
-			wantErr: false,
-		},
-		{
-			name: "Fail - Empty task type",
-			args: args{
-				campaignID:          campaignID,
-				taskType:            "",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				pointsPool:          0,
-				startTime:           0,
-				endTime:             0,
+				campaignID:  campaignID,
+				taskType:    "onboarding",
+				description: "test",
+				config:      json.RawMessage(`{"reward":"100","threshold":"1000"}`),
+				startTime:   123,
+				endTime:     234,
 			},
-			want:    0,
-			wantErr: true,
+			wantConfig: `{"reward": "100", "threshold": "1000"}`,
+			wantErr:    false,
 		},
 		{
-			name: "Fail - Invalid campaign ID",
+			name: "Success - Create task without config",
 			args: args{
-				campaignID:          -1,
-				taskType:            "test",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				pointsPool:          0,
-				startTime:           0,
-				endTime:             0,
+				campaignID:  campaignID,
+				taskType:    "create test",
+				description: "test",
+				startTime:   123,
+				endTime:     234,
 			},
-			want:    0,
-			wantErr: true,
+			wantConfig: `{}`,
+			wantErr:    false,
 		},
 		{
-			name: "Fail - Invalid start time",
+			name: "Fail - Empty task type",
 			args: args{
-				campaignID:          campaignID,
-				taskType:            "test",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				pointsPool:          0,
-				startTime:           -1,
-				endTime:             0,
+				campaignID:  campaignID,
+				taskType:    "",
+				description: "test",
+				startTime:   0,
+				endTime:     0,
 			},
-			want:    0,
 			wantErr: true,
 		},
 		{
-			name: "Fail - Invalid end time",
+			name: "Fail - Invalid campaign ID",
 			args: args{
-				campaignID:          campaignID,
-				taskType:            "test",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				pointsPool:          0,
-				startTime:           0,
-				endTime:             0,
+				campaignID:  -1,
+				taskType:    "test",
+				description: "test",
+				startTime:   0,
+				endTime:     0,
 			},
-			want:    0,
 			wantErr: true,
 		},
 		{
-			name: "Fail - Invalid onboarding reward",
+			name: "Fail - Invalid start time",
 			args: args{
-				campaignID:          campaignID,
-				taskType:            "test",
-				description:         "test",
-				onboardingReward:    -1,
-				onboardingThreshold: 0,
-				pointsPool:          0,
-				startTime:           0,
-				endTime:             0,
+				campaignID:  campaignID,
+				taskType:    "test",
+				description: "test",
+				startTime:   -1,
+				endTime:     0,
 			},
-			want:    0,
 			wantErr: true,
 		},
 		{
-			name: "Fail - Invalid onboarding threshold",
+			name: "Fail - Invalid end time",
 			args: args{
-				campaignID:          campaignID,
-				taskType:            "test",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: -1,
-				pointsPool:          0,
-				startTime:           0,
-				endTime:             0,
+				campaignID:  campaignID,
+				taskType:    "test",
+				description: "test",
+				startTime:   0,
+				endTime:     0,
 			},
-			want:    0,
 			wantErr: true,
 		},
 		{
-			name: "Fail - Invalid points pool",
+			name: "Fail - Invalid config",
 			args: args{
-				campaignID:          campaignID,
-				taskType:            "test",
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				pointsPool:          -1,
-				startTime:           0,
-				endTime:             0,
+				campaignID:  campaignID,
+				taskType:    "test",
+				description: "test",
+				config:      json.RawMessage(`{"reward":`),
+				startTime:   123,
+				endTime:     234,
 			},
-			want:    0,
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CreateTask(tt.args.campaignID, tt.args.taskType, tt.args.description, tt.args.onboardingReward, tt.args.onboardingThreshold, tt.args.pointsPool, tt.args.startTime, tt.args.endTime)
+			got, err := CreateTask(tt.args.campaignID, tt.args.taskType, tt.args.description, tt.args.config, tt.args.startTime, tt.args.endTime)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateTask() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
-				var taskType, description string
-				var onboardingReward, onboardingThreshold, pointsPool float64
-				var startTime, endTime int64
-				query := `SELECT type, description, onboarding_reward, onboarding_threshold, points_pool, start_time, end_time FROM tasks WHERE task_id = $1`
-				err := db.QueryRow(query, got).Scan(&taskType, &description, &onboardingReward, &onboardingThreshold, &pointsPool, &startTime, &endTime)
-				if err != nil {
+				tasks, err := GetTasksByTaskIDs([]int{got})
+				if err != nil || len(tasks) != 1 {
 					t.Errorf("CreateTask() error = %v", err)
 					return
 				}
+				if tasks[0].Type != tt.args.taskType || string(tasks[0].Config) != tt.wantConfig {
+					t.Errorf("CreateTask() = %v, want type %s and config %s", tasks[0], tt.args.taskType, tt.wantConfig)
+				}
 			}
 		})
 	}
 }
 
 func TestGetTasksByTaskIDs(t *testing.T) {
-	campaignID, _ := CreateCampaign("test", "test", 0, 0)
-	task1, _ := CreateTask(campaignID, "test", "test", 0, 0, 0, 0, 0)
-	task2, _ := CreateTask(campaignID, "test", "test", 0, 0, 0, 0, 0)
+	campaignID, _ := CreateCampaign(1, "test", "test", "uniswap_v2", "sender", 0, 0)
+	task1, _ := CreateTask(campaignID, "test", "test", nil, 0, 0)
+	task2, _ := CreateTask(campaignID, "test", "test", nil, 0, 0)
 	type args struct {
 		taskIDs []int
 	}
@@ -212,98 +171,10 @@ func TestGetTasksByTaskIDs(t *testing.T) {
 	}
 }
 
-func TestCreateOnboardingTask(t *testing.T) {
-	campaignID, _ := CreateCampaign("test", "test", 0, 1)
-	type args struct {
-		campaignID          int
-		description         string
-		onboardingReward    float64
-		onboardingThreshold float64
-		startTime           int64
-		endTime             int64
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr bool
-	}{
-		{
-			name: "Success - Create onboarding task",
-			args: args{
-				campaignID:          campaignID,
-				description:         "test",
-				onboardingReward:    0,
-				onboardingThreshold: 0,
-				startTime:           123,
-				endTime:             456,
-			},
-			want:    "onboarding",
-			wantErr: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := CreateOnboardingTask(tt.args.campaignID, tt.args.description, tt.args.onboardingReward, tt.args.onboardingThreshold, tt.args.startTime, tt.args.endTime)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("CreateOnboardingTask() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			tasks, _ := GetTasksByTaskIDs([]int{got})
-			if tasks[0].Type != tt.want {
-				t.Errorf("CreateOnboardingTask() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestCreateSharePoolTask(t *testing.T) {
-	campaignID, _ := CreateCampaign("test", "test", 0, 1)
-	type args struct {
-		campaignID  int
-		description string
-		pointsPool  float64
-		startTime   int64
-		endTime     int64
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr bool
-	}{
-		{
-			name: "Success - Create share pool task",
-			args: args{
-				campaignID:  campaignID,
-				description: "test",
-				pointsPool:  0,
-				startTime:   123,
-				endTime:     456,
-			},
-			want:    "share_pool",
-			wantErr: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := CreateSharePoolTask(tt.args.campaignID, tt.args.description, tt.args.pointsPool, tt.args.startTime, tt.args.endTime)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("CreateSharePoolTask() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			tasks, _ := GetTasksByTaskIDs([]int{got})
-			if tasks[0].Type != tt.want {
-				t.Errorf("CreateSharePoolTask() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestGetTasksByCampaignID(t *testing.T) {
-	campaignID, _ := CreateCampaign("test", "TestGetTasksByCampaignID", 0, 1)
+	campaignID, _ := CreateCampaign(1, "test", "TestGetTasksByCampaignID", "uniswap_v2", "sender", 0, 1)
 	campaign, _ := GetCampaignByID(campaignID)
-	taskID, _ := CreateOnboardingTask(campaign.CampaignID, "test", 0, 0, 123, 456)
+	taskID, _ := CreateTask(campaign.CampaignID, "onboarding", "test", json.RawMessage(`{"reward":"0","threshold":"0"}`), 123, 456)
 	type args struct {
 		campaignID int
 	}
@@ -347,9 +218,9 @@ func TestGetTasksByCampaignID(t *testing.T) {
 }
 
 func TestGetActiveTasksByCampaignID(t *testing.T) {
-	campaignID, _ := CreateCampaign("test", "TestGetActiveTasksByCampaignID", 456, 789)
+	campaignID, _ := CreateCampaign(1, "test", "TestGetActiveTasksByCampaignID", "uniswap_v2", "sender", 456, 789)
 	campaign, _ := GetCampaignByID(campaignID)
-	taskID, _ := CreateOnboardingTask(campaign.CampaignID, "test", 0, 0, 456, 789)
+	taskID, _ := CreateTask(campaign.CampaignID, "onboarding", "test", json.RawMessage(`{"reward":"0","threshold":"0"}`), 456, 789)
 	type args struct {
 		campaignID int
 		timestamp  int64
@@ -395,12 +266,13 @@ func TestGetActiveTasksByCampaignID(t *testing.T) {
 	}
 }
 
-func TestGetOnboardingTaskByCampaignID(t *testing.T) {
-	campaignID, _ := CreateCampaign("test", "TestGetOnboardingTaskByCampaignID", 456, 789)
+func TestGetTaskByCampaignIDAndType(t *testing.T) {
+	campaignID, _ := CreateCampaign(1, "test", "TestGetTaskByCampaignIDAndType", "uniswap_v2", "sender", 456, 789)
 	campaign, _ := GetCampaignByID(campaignID)
-	taskID, _ := CreateOnboardingTask(campaign.CampaignID, "test", 0, 0, 456, 789)
+	taskID, _ := CreateTask(campaign.CampaignID, "onboarding", "test", json.RawMessage(`{"reward":"0","threshold":"0"}`), 456, 789)
 	type args struct {
 		campaignID int
+		taskType   string
 	}
 	tests := []struct {
 		name    string
@@ -412,21 +284,33 @@ func TestGetOnboardingTaskByCampaignID(t *testing.T) {
 			name: "Success - Get onboarding task by campaign ID",
 			args: args{
 				campaignID: campaign.CampaignID,
+				taskType:   "onboarding",
 			},
 			want: &Task{
 				TaskID:      taskID,
 				CampaignID:  campaign.CampaignID,
 				Type:        "onboarding",
 				Description: "test",
+				Config:      json.RawMessage(`{"reward": "0", "threshold": "0"}`),
 				StartTime:   456,
 				EndTime:     789,
 			},
 			wantErr: false,
 		},
 		{
-			name: "Fail - Get onboarding task by campaign ID",
+			name: "Fail - No task of the type",
+			args: args{
+				campaignID: campaign.CampaignID,
+				taskType:   "share_pool",
+			},
+			want:    &Task{},
+			wantErr: true,
+		},
+		{
+			name: "Fail - Unknown campaign ID",
 			args: args{
 				campaignID: -1,
+				taskType:   "onboarding",
 			},
 			want:    &Task{},
 			wantErr: true,
@@ -434,23 +318,23 @@ func TestGetOnboardingTaskByCampaignID(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetOnboardingTaskByCampaignID(tt.args.campaignID)
+			got, err := GetTaskByCampaignIDAndType(tt.args.campaignID, tt.args.taskType)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetOnboardingTaskByCampaignID() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetTaskByCampaignIDAndType() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetOnboardingTaskByCampaignID() = %v, want %v", got, tt.want)
+				t.Errorf("GetTaskByCampaignIDAndType() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestGetExpiredSharePoolTasks(t *testing.T) {
+func TestGetExpiredTasks(t *testing.T) {
 	now := time.Now().Unix()
-	campaignID, _ := CreateCampaign("test", "TestGetExpiredSharePoolTasks", now-1000, now-500)
+	campaignID, _ := CreateCampaign(1, "test", "TestGetExpiredTasks", "uniswap_v2", "sender", now-1000, now-500)
 	campaign, _ := GetCampaignByID(campaignID)
-	taskID, _ := CreateSharePoolTask(campaign.CampaignID, "test", 0, now-1000, now-500)
+	taskID, _ := CreateTask(campaign.CampaignID, "share_pool", "test", json.RawMessage(`{"pointsPool":"0"}`), now-1000, now-500)
 	type args struct {
 		now           int64
 		lastCheckTime int64
@@ -462,7 +346,7 @@ func TestGetExpiredSharePoolTasks(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "Success - Get expired share pool tasks",
+			name: "Success - Get expired tasks",
 			args: args{
 				now:           now,
 				lastCheckTime: now - 2000,
@@ -473,6 +357,7 @@ func TestGetExpiredSharePoolTasks(t *testing.T) {
 					CampaignID:  campaign.CampaignID,
 					Type:        "share_pool",
 					Description: "test",
+					Config:      json.RawMessage(`{"pointsPool": "0"}`),
 					StartTime:   now - 1000,
 					EndTime:     now - 500,
 				},
@@ -480,7 +365,7 @@ func TestGetExpiredSharePoolTasks(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "Fail - No expired share pool tasks",
+			name: "Fail - No expired tasks",
 			args: args{
 				now:           now,
 				lastCheckTime: now,
@@ -491,16 +376,16 @@ func TestGetExpiredSharePoolTasks(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetExpiredSharePoolTasks(tt.args.now, tt.args.lastCheckTime)
+			got, err := GetExpiredTasks(tt.args.now, tt.args.lastCheckTime)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetExpiredSharePoolTasks() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetExpiredTasks() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if len(got) == 0 && len(tt.want) == 0 {
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetExpiredSharePoolTasks() = %v, want %v", got, tt.want)
+				t.Errorf("GetExpiredTasks() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
 )
 
 func initUserPointsHistoryTable() {
@@ -13,9 +15,10 @@ func initUserPointsHistoryTable() {
 		user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
 		task_id INT REFERENCES tasks(task_id) ON DELETE CASCADE,
 		campaign_id INT REFERENCES campaigns(campaign_id) ON DELETE CASCADE,
-		points FLOAT NOT NULL CHECK (points >= 0),
+		points NUMERIC(38, 6) NOT NULL CHECK (points >= 0),
 		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
 		);
+	ALTER TABLE user_points_history ALTER COLUMN points TYPE NUMERIC(38, 6);
 	CREATE INDEX IF NOT EXISTS idx_user_id ON user_points_history(user_id);
 	CREATE INDEX IF NOT EXISTS idx_task_id ON user_points_history(task_id);
 	CREATE INDEX IF NOT EXISTS idx_campaign_id ON user_points_history(campaign_id);`
@@ -27,7 +30,7 @@ func initUserPointsHistoryTable() {
 	fmt.Println("UserPointsHistory table and indexes checked/created.")
 }
 
-func CreateUserPointsHistory(userID, taskID, campaignID int, points float64) error {
+func CreateUserPointsHistory(userID, taskID, campaignID int, points decimal.Decimal) error {
 	query := `INSERT INTO user_points_history (user_id, task_id, campaign_id, points, created_at) VALUES ($1, $2, $3, $4, $5)`
 	_, err := db.Exec(query, userID, taskID, campaignID, points, time.Now().Unix())
 	if err != nil {
@@ -36,6 +39,28 @@ func CreateUserPointsHistory(userID, taskID, campaignID int, points float64) err
 	return nil
 }
 
+func DeleteUserPointsHistory(userID, taskID int) error {
+	query := `DELETE FROM user_points_history WHERE user_id = $1 AND task_id = $2`
+	_, err := db.Exec(query, userID, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user_points_history: %w", err)
+	}
+	return nil
+}
+
+// DeleteLatestUserPointsHistory deletes the latest entry of a task paying
+// the given points, revoking a single payout of a task that pays several.
+func DeleteLatestUserPointsHistory(userID, taskID int, points decimal.Decimal) error {
+	query := `DELETE FROM user_points_history WHERE history_id = (
+		SELECT history_id FROM user_points_history WHERE user_id = $1 AND task_id = $2 AND points = $3
+		ORDER BY history_id DESC LIMIT 1)`
+	_, err := db.Exec(query, userID, taskID, points)
+	if err != nil {
+		return fmt.Errorf("failed to delete user_points_history: %w", err)
+	}
+	return nil
+}
+
 func GetUserPointsHistoryByUserID(userID int) ([]UserPointsHistory, error) {
 	query := `SELECT history_id, user_id, task_id, campaign_id, points, created_at FROM user_points_history WHERE user_id = $1`
 	rows, err := db.Query(query, userID)
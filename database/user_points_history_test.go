@@ -1,19 +1,22 @@
 package database
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
 )
 
 func TestCreateUserPointsHistory(t *testing.T) {
 	userID, _ := CreateUser("TestCreateUserPointsHistory")
-	campaignID, _ := CreateCampaign("TestCreateUserPointsHistory", "TestCreateUserPointsHistory", 0, 1)
-	taskID, _ := CreateOnboardingTask(campaignID, "TestCreateUserPointsHistory", 0, 1, 0, 1)
+	campaignID, _ := CreateCampaign(1, "TestCreateUserPointsHistory", "TestCreateUserPointsHistory", "uniswap_v2", "sender", 0, 1)
+	taskID, _ := CreateTask(campaignID, "onboarding", "TestCreateUserPointsHistory", json.RawMessage(`{"reward":"0","threshold":"1"}`), 0, 1)
 	type args struct {
 		userID     int
 		taskID     int
 		campaignID int
-		points     float64
+		points     decimal.Decimal
 	}
 	tests := []struct {
 		name    string
@@ -27,7 +30,7 @@ func TestCreateUserPointsHistory(t *testing.T) {
 				userID:     userID,
 				taskID:     taskID,
 				campaignID: campaignID,
-				points:     0,
+				points:     decimal.NewFromInt(0),
 			},
 			wantErr: false,
 		},
@@ -37,7 +40,7 @@ func TestCreateUserPointsHistory(t *testing.T) {
 				userID:     -1,
 				taskID:     taskID,
 				campaignID: campaignID,
-				points:     0,
+				points:     decimal.NewFromInt(0),
 			},
 			wantErr: true,
 		},
@@ -53,10 +56,10 @@ func TestCreateUserPointsHistory(t *testing.T) {
 
 func TestGetUserPointsHistoryByUserID(t *testing.T) {
 	userID, _ := CreateUser("TestGetUserPointsHistoryByUserID")
-	CampaignID, _ := CreateCampaign("TestGetUserPointsHistoryByUserID", "TestGetUserPointsHistoryByUserID", 0, 1)
-	TaskID, _ := CreateOnboardingTask(CampaignID, "TestGetUserPointsHistoryByUserID", 0, 1, 0, 1)
+	CampaignID, _ := CreateCampaign(1, "TestGetUserPointsHistoryByUserID", "TestGetUserPointsHistoryByUserID", "uniswap_v2", "sender", 0, 1)
+	TaskID, _ := CreateTask(CampaignID, "onboarding", "TestGetUserPointsHistoryByUserID", json.RawMessage(`{"reward":"0","threshold":"1"}`), 0, 1)
 	// nolint
-	CreateUserPointsHistory(userID, TaskID, CampaignID, 0)
+	CreateUserPointsHistory(userID, TaskID, CampaignID, decimal.Zero())
 	type args struct {
 		userID int
 	}
@@ -77,7 +80,7 @@ func TestGetUserPointsHistoryByUserID(t *testing.T) {
 					UserID:     userID,
 					TaskID:     TaskID,
 					CampaignID: CampaignID,
-					Points:     0,
+					Points:     decimal.Zero(),
 				},
 			},
 			wantErr: false,
@@ -106,3 +109,24 @@ func TestGetUserPointsHistoryByUserID(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteLatestUserPointsHistory(t *testing.T) {
+	userID, _ := CreateUser("TestDeleteLatestUserPointsHistory")
+	campaignID, _ := CreateCampaign(1, "TestDeleteLatestUserPointsHistory", "TestDeleteLatestUserPointsHistory", "uniswap_v2", "sender", 0, 1)
+	taskID, _ := CreateTask(campaignID, "milestone", "TestDeleteLatestUserPointsHistory", json.RawMessage(`{"tiers":[{"volume":"1000","points":"50"},{"volume":"10000","points":"300"}]}`), 0, 1)
+	// nolint
+	CreateUserPointsHistory(userID, taskID, campaignID, decimal.NewFromInt(50))
+	// nolint
+	CreateUserPointsHistory(userID, taskID, campaignID, decimal.NewFromInt(300))
+
+	if err := DeleteLatestUserPointsHistory(userID, taskID, decimal.NewFromInt(300)); err != nil {
+		t.Fatalf("DeleteLatestUserPointsHistory() error = %v", err)
+	}
+	got, err := GetUserPointsHistoryByUserID(userID)
+	if err != nil {
+		t.Fatalf("GetUserPointsHistoryByUserID() error = %v", err)
+	}
+	if len(got) != 1 || !got[0].Points.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("DeleteLatestUserPointsHistory() left %v, want the 50 points payout", got)
+	}
+}
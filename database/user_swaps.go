@@ -10,17 +10,51 @@ func initUserSwapTable() {
 	query := `
 	CREATE TABLE IF NOT EXISTS user_swaps (
 		swap_id SERIAL PRIMARY KEY,
+		chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0),
 		user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
 		transaction_hash VARCHAR(100) NOT NULL,
 		pool_address VARCHAR(100) NOT NULL,
-		amount_usdc FLOAT DEFAULT 0,
-		amount_weth FLOAT DEFAULT 0,
+		sender_address VARCHAR(100) DEFAULT '',
+		recipient_address VARCHAR(100) DEFAULT '',
+		origin_address VARCHAR(100) DEFAULT '',
+		amount_usdc NUMERIC DEFAULT 0,
+		amount_weth NUMERIC DEFAULT 0,
+		amount0_in NUMERIC(78, 0) DEFAULT 0,
+		amount1_in NUMERIC(78, 0) DEFAULT 0,
+		amount0_out NUMERIC(78, 0) DEFAULT 0,
+		amount1_out NUMERIC(78, 0) DEFAULT 0,
 		swap_time BIGINT NOT NULL,
+		block_number BIGINT DEFAULT 0,
+		block_hash VARCHAR(100) DEFAULT '',
+		log_index INT DEFAULT 0,
+		tx_index INT DEFAULT 0,
+		accrued BOOLEAN NOT NULL DEFAULT TRUE,
 		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
 	);
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS block_number BIGINT DEFAULT 0;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS block_hash VARCHAR(100) DEFAULT '';
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS log_index INT DEFAULT 0;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS amount0_in NUMERIC(78, 0) DEFAULT 0;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS amount1_in NUMERIC(78, 0) DEFAULT 0;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS amount0_out NUMERIC(78, 0) DEFAULT 0;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS amount1_out NUMERIC(78, 0) DEFAULT 0;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS sender_address VARCHAR(100) DEFAULT '';
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS recipient_address VARCHAR(100) DEFAULT '';
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS origin_address VARCHAR(100) DEFAULT '';
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1 CHECK (chain_id > 0);
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS accrued BOOLEAN NOT NULL DEFAULT TRUE;
+	ALTER TABLE user_swaps ADD COLUMN IF NOT EXISTS tx_index INT DEFAULT 0;
+	ALTER TABLE user_swaps ALTER COLUMN amount_usdc TYPE NUMERIC;
+	ALTER TABLE user_swaps ALTER COLUMN amount_weth TYPE NUMERIC;
 	CREATE INDEX IF NOT EXISTS idx_user_id ON user_swaps(user_id);
 	CREATE INDEX IF NOT EXISTS idx_pool_address ON user_swaps(pool_address);
-	CREATE INDEX IF NOT EXISTS idx_transaction_hash ON user_swaps(transaction_hash);`
+	CREATE INDEX IF NOT EXISTS idx_transaction_hash ON user_swaps(transaction_hash);
+	CREATE INDEX IF NOT EXISTS idx_user_swaps_block_hash ON user_swaps(block_hash);
+	DELETE FROM user_swaps a USING user_swaps b
+	WHERE a.chain_id = b.chain_id AND a.transaction_hash = b.transaction_hash AND a.log_index = b.log_index
+	AND a.block_hash <> '' AND b.block_hash <> '' AND a.swap_id > b.swap_id
+	AND NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_user_swaps_log');
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_user_swaps_log ON user_swaps(chain_id, transaction_hash, log_index) WHERE block_hash <> '';`
 
 	_, err := db.Exec(query)
 	if err != nil {
@@ -29,13 +63,129 @@ func initUserSwapTable() {
 	fmt.Println("UserSwaps table and indexes checked/created.")
 }
 
-func InsertSwapEvent(userID int, poolAddress string, usdc float64, swapTime int64, txHash string) error {
+// InsertSwapEvent stores a swap with its USD volume, its WETH amount, the raw
+// on-chain amounts of both pool tokens and every address it can be
+// attributed to, along with the index of its transaction in the block. A
+// swap is identified by its chain, transaction hash and log index, and
+// inserted reports false if it was already stored. New swaps are not accrued
+// until MarkSwapAccrued is called once their tasks are updated; swaps stored
+// before the accrued column existed count as accrued.
+func InsertSwapEvent(swap UserSwap) (bool, error) {
 	query := `
-	INSERT INTO user_swaps (user_id, pool_address, amount_usdc, swap_time, transaction_hash, created_at)
-	VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := db.Exec(query, userID, poolAddress, usdc, swapTime, txHash, time.Now().Unix())
+	INSERT INTO user_swaps (chain_id, user_id, pool_address, sender_address, recipient_address, origin_address, amount_usdc, amount_weth, amount0_in, amount1_in, amount0_out, amount1_out, swap_time, transaction_hash, block_number, block_hash, log_index, tx_index, accrued, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, FALSE, $19)
+	ON CONFLICT (chain_id, transaction_hash, log_index) WHERE block_hash <> '' DO NOTHING`
+	result, err := db.Exec(query, swap.ChainID, swap.UserID, swap.PoolAddress, swap.SenderAddress, swap.RecipientAddress, swap.OriginAddress, swap.AmountUSDC, swap.AmountWETH, swap.Amount0In, swap.Amount1In, swap.Amount0Out, swap.Amount1Out, swap.SwapTime, swap.TransactionHash, swap.BlockNumber, swap.BlockHash, swap.LogIndex, swap.TxIndex, time.Now().Unix())
 	if err != nil {
-		return fmt.Errorf("failed to insert swap event: %w", err)
+		return false, fmt.Errorf("failed to insert swap event: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert swap event: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// MarkSwapAccrued records that the tasks of a stored swap were updated.
+func MarkSwapAccrued(chainID uint64, txHash string, logIndex uint) error {
+	query := `UPDATE user_swaps SET accrued = TRUE WHERE chain_id = $1 AND transaction_hash = $2 AND log_index = $3 AND block_hash <> ''`
+	_, err := db.Exec(query, chainID, txHash, logIndex)
+	if err != nil {
+		return fmt.Errorf("failed to mark swap event accrued: %w", err)
 	}
 	return nil
 }
+
+// IsSwapAccrued reports whether the tasks of a stored swap were updated. A
+// stored swap that is not accrued was left by a run that stopped between
+// storing and crediting it.
+func IsSwapAccrued(chainID uint64, txHash string, logIndex uint) (bool, error) {
+	var accrued bool
+	query := `SELECT accrued FROM user_swaps WHERE chain_id = $1 AND transaction_hash = $2 AND log_index = $3 AND block_hash <> ''`
+	err := db.QueryRow(query, chainID, txHash, logIndex).Scan(&accrued)
+	if err != nil {
+		return false, fmt.Errorf("failed to get swap event: %w", err)
+	}
+	return accrued, nil
+}
+
+// GetSwapEvent returns the swap emitted by the given log of the given block.
+func GetSwapEvent(chainID uint64, txHash string, logIndex uint, blockHash string) (UserSwap, error) {
+	var swap UserSwap
+	query := `
+	SELECT swap_id, chain_id, user_id, transaction_hash, pool_address, sender_address, recipient_address, origin_address, amount_usdc, swap_time, block_number, block_hash, log_index
+	FROM user_swaps WHERE chain_id = $1 AND transaction_hash = $2 AND log_index = $3 AND block_hash = $4`
+	err := db.QueryRow(query, chainID, txHash, logIndex, blockHash).Scan(&swap.SwapID, &swap.ChainID, &swap.UserID, &swap.TransactionHash, &swap.PoolAddress, &swap.SenderAddress, &swap.RecipientAddress, &swap.OriginAddress, &swap.AmountUSDC, &swap.SwapTime, &swap.BlockNumber, &swap.BlockHash, &swap.LogIndex)
+	if err != nil {
+		return UserSwap{}, err
+	}
+	return swap, nil
+}
+
+// DeleteSwapEvent removes the swap emitted by the given log of a reorged block
+// on the given chain and returns the deleted row so its task accruals can be
+// reverted.
+func DeleteSwapEvent(chainID uint64, txHash string, logIndex uint, blockHash string) (UserSwap, error) {
+	var swap UserSwap
+	query := `
+	DELETE FROM user_swaps WHERE chain_id = $1 AND transaction_hash = $2 AND log_index = $3 AND block_hash = $4
+	RETURNING swap_id, chain_id, user_id, transaction_hash, pool_address, sender_address, recipient_address, origin_address, amount_usdc, swap_time, block_number, block_hash, log_index`
+	err := db.QueryRow(query, chainID, txHash, logIndex, blockHash).Scan(&swap.SwapID, &swap.ChainID, &swap.UserID, &swap.TransactionHash, &swap.PoolAddress, &swap.SenderAddress, &swap.RecipientAddress, &swap.OriginAddress, &swap.AmountUSDC, &swap.SwapTime, &swap.BlockNumber, &swap.BlockHash, &swap.LogIndex)
+	if err != nil {
+		return UserSwap{}, err
+	}
+	return swap, nil
+}
+
+// GetSwapEventsByPool returns the swaps of a pool on the given chain made
+// strictly between startTime and endTime, the window in which the listener
+// credits them to a share pool task, in the order they were emitted.
+func GetSwapEventsByPool(chainID uint64, poolAddress string, startTime, endTime int64) ([]UserSwap, error) {
+	query := `
+	SELECT swap_id, chain_id, user_id, transaction_hash, pool_address, sender_address, recipient_address, origin_address, amount_usdc, amount_weth, amount0_in, amount1_in, amount0_out, amount1_out, swap_time, block_number, block_hash, log_index, tx_index, created_at
+	FROM user_swaps WHERE chain_id = $1 AND pool_address = $2 AND swap_time > $3 AND swap_time < $4
+	ORDER BY block_number, log_index`
+	swaps, err := queryUserSwaps(query, chainID, poolAddress, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query swap events of pool %s: %w", poolAddress, err)
+	}
+	return swaps, nil
+}
+
+// GetSwapEventsByAddress returns the swaps of a pool made strictly between
+// startTime and endTime in which the address is the sender, the recipient or
+// the origin, in the order they were emitted.
+func GetSwapEventsByAddress(chainID uint64, poolAddress, address string, startTime, endTime int64) ([]UserSwap, error) {
+	query := `
+	SELECT swap_id, chain_id, user_id, transaction_hash, pool_address, sender_address, recipient_address, origin_address, amount_usdc, amount_weth, amount0_in, amount1_in, amount0_out, amount1_out, swap_time, block_number, block_hash, log_index, tx_index, created_at
+	FROM user_swaps WHERE chain_id = $1 AND pool_address = $2 AND swap_time > $3 AND swap_time < $4
+	AND (sender_address = $5 OR recipient_address = $5 OR origin_address = $5)
+	ORDER BY block_number, log_index`
+	swaps, err := queryUserSwaps(query, chainID, poolAddress, startTime, endTime, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query swap events of %s: %w", address, err)
+	}
+	return swaps, nil
+}
+
+func queryUserSwaps(query string, args ...interface{}) ([]UserSwap, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var swaps []UserSwap
+	for rows.Next() {
+		var swap UserSwap
+		err := rows.Scan(&swap.SwapID, &swap.ChainID, &swap.UserID, &swap.TransactionHash, &swap.PoolAddress, &swap.SenderAddress, &swap.RecipientAddress, &swap.OriginAddress, &swap.AmountUSDC, &swap.AmountWETH, &swap.Amount0In, &swap.Amount1In, &swap.Amount0Out, &swap.Amount1Out, &swap.SwapTime, &swap.BlockNumber, &swap.BlockHash, &swap.LogIndex, &swap.TxIndex, &swap.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan swap event: %w", err)
+		}
+		swaps = append(swaps, swap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+	return swaps, nil
+}
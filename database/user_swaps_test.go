@@ -1,50 +1,228 @@
 package database
 
-import "testing"
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+)
 
 func TestInsertSwapEvent(t *testing.T) {
 	userID, _ := CreateUser("TestInsertSwapEvent")
+	swap := UserSwap{
+		ChainID:         1,
+		UserID:          userID,
+		PoolAddress:     "0x123",
+		AmountUSDC:      decimal.RequireFromString("1234567.123456"),
+		AmountWETH:      decimal.RequireFromString("500"),
+		Amount0In:       decimal.RequireFromString("1234567123456"),
+		Amount1Out:      decimal.RequireFromString("500000000000000000000"),
+		SwapTime:        123,
+		TransactionHash: "0x123",
+		BlockNumber:     1,
+		BlockHash:       "0xabc",
+		LogIndex:        0,
+	}
+	otherLog := swap
+	otherLog.LogIndex = 1
+	otherChain := swap
+	otherChain.ChainID = 10
+	invalidUser := swap
+	invalidUser.UserID = -1
+	invalidUser.LogIndex = 2
+	tests := []struct {
+		name    string
+		swap    UserSwap
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "Success - Insert swap event",
+			swap:    swap,
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name:    "Success - Duplicate delivery is skipped",
+			swap:    swap,
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:    "Success - Second swap of the transaction",
+			swap:    otherLog,
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name:    "Success - Same log on another chain",
+			swap:    otherChain,
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name:    "Fail - Insert swap event",
+			swap:    invalidUser,
+			want:    false,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InsertSwapEvent(tt.swap)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InsertSwapEvent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("InsertSwapEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkSwapAccrued(t *testing.T) {
+	userID, _ := CreateUser("TestMarkSwapAccrued")
+	// nolint
+	InsertSwapEvent(UserSwap{ChainID: 1, UserID: userID, PoolAddress: "TestMarkSwapAccrued", SwapTime: 123, TransactionHash: "0xaccrued", BlockNumber: 1, BlockHash: "0xabc", LogIndex: 0})
+
+	accrued, err := IsSwapAccrued(1, "0xaccrued", 0)
+	if err != nil || accrued {
+		t.Errorf("IsSwapAccrued() of a new swap = %v, %v, want false", accrued, err)
+	}
+	if err := MarkSwapAccrued(1, "0xaccrued", 0); err != nil {
+		t.Errorf("MarkSwapAccrued() error = %v", err)
+	}
+	accrued, err = IsSwapAccrued(1, "0xaccrued", 0)
+	if err != nil || !accrued {
+		t.Errorf("IsSwapAccrued() after MarkSwapAccrued() = %v, %v, want true", accrued, err)
+	}
+	if _, err := IsSwapAccrued(1, "0xaccrued", 1); err == nil {
+		t.Errorf("IsSwapAccrued() of a missing swap should fail")
+	}
+}
+
+func TestGetSwapEvent(t *testing.T) {
+	userID, _ := CreateUser("TestGetSwapEvent")
+	// nolint
+	InsertSwapEvent(UserSwap{ChainID: 10, UserID: userID, PoolAddress: "TestGetSwapEvent", SenderAddress: "0xrouter", AmountUSDC: decimal.NewFromInt(10), SwapTime: 123, TransactionHash: "0xget", BlockNumber: 1, BlockHash: "0xreorged", LogIndex: 2})
+
+	got, err := GetSwapEvent(10, "0xget", 2, "0xreorged")
+	if err != nil || got.UserID != userID || got.SenderAddress != "0xrouter" || !got.AmountUSDC.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("GetSwapEvent() = %v, %v, want the stored swap", got, err)
+	}
+	if _, err := GetSwapEvent(10, "0xget", 2, "0xcanonical"); err != sql.ErrNoRows {
+		t.Errorf("GetSwapEvent() of another block error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestDeleteSwapEvent(t *testing.T) {
+	userID, _ := CreateUser("TestDeleteSwapEvent")
+	// nolint
+	InsertSwapEvent(UserSwap{ChainID: 10, UserID: userID, PoolAddress: "TestDeleteSwapEvent", SenderAddress: "0xrouter", OriginAddress: "0xtrader", AmountUSDC: decimal.NewFromInt(10), SwapTime: 123, TransactionHash: "0xdelete", BlockNumber: 1, BlockHash: "0xreorged", LogIndex: 2})
 	type args struct {
-		userID      int
-		poolAddress string
-		usdc        float64
-		swapTime    int64
-		txHash      string
+		chainID   uint64
+		txHash    string
+		logIndex  uint
+		blockHash string
 	}
 	tests := []struct {
 		name    string
 		args    args
-		wantErr bool
+		want    decimal.Decimal
+		wantErr error
 	}{
-		// TODO: Add test cases.
 		{
-			name: "Success - Insert swap event",
+			name: "Fail - Swap on another chain",
 			args: args{
-				userID:      userID,
-				poolAddress: "0x123",
-				usdc:        0,
-				swapTime:    123,
-				txHash:      "0x123",
+				chainID:   1,
+				txHash:    "0xdelete",
+				logIndex:  2,
+				blockHash: "0xreorged",
 			},
-			wantErr: false,
+			wantErr: sql.ErrNoRows,
 		},
 		{
-			name: "Fail - Insert swap event",
+			name: "Success - Delete reorged swap",
 			args: args{
-				userID:      -1,
-				poolAddress: "0x123",
-				usdc:        0,
-				swapTime:    123,
-				txHash:      "0x123",
+				chainID:   10,
+				txHash:    "0xdelete",
+				logIndex:  2,
+				blockHash: "0xreorged",
 			},
-			wantErr: true,
+			want:    decimal.NewFromInt(10),
+			wantErr: nil,
+		},
+		{
+			name: "Fail - Swap already deleted",
+			args: args{
+				chainID:   10,
+				txHash:    "0xdelete",
+				logIndex:  2,
+				blockHash: "0xreorged",
+			},
+			wantErr: sql.ErrNoRows,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := InsertSwapEvent(tt.args.userID, tt.args.poolAddress, tt.args.usdc, tt.args.swapTime, tt.args.txHash); (err != nil) != tt.wantErr {
-				t.Errorf("InsertSwapEvent() error = %v, wantErr %v", err, tt.wantErr)
+			got, err := DeleteSwapEvent(tt.args.chainID, tt.args.txHash, tt.args.logIndex, tt.args.blockHash)
+			if err != tt.wantErr {
+				t.Errorf("DeleteSwapEvent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && (got.ChainID != tt.args.chainID || got.UserID != userID || got.OriginAddress != "0xtrader" || !got.AmountUSDC.Equal(tt.want)) {
+				t.Errorf("DeleteSwapEvent() = %v, want user %v amount %v", got, userID, tt.want)
 			}
 		})
 	}
 }
+
+func TestGetSwapEventsByPool(t *testing.T) {
+	userID, _ := CreateUser("TestGetSwapEventsByPool")
+	pool := "TestGetSwapEventsByPool"
+	for i, swapTime := range []int64{100, 150, 120, 200} {
+		// nolint
+		InsertSwapEvent(UserSwap{ChainID: 1, UserID: userID, PoolAddress: pool, SenderAddress: "0xtrader", AmountUSDC: decimal.NewFromInt(int64(i + 1)), Amount0In: decimal.NewFromInt(1000), SwapTime: swapTime, TransactionHash: "0xwindow", BlockNumber: uint64(swapTime), BlockHash: "0xabc", LogIndex: uint(i), TxIndex: uint(i + 4)})
+	}
+	// nolint
+	InsertSwapEvent(UserSwap{ChainID: 10, UserID: userID, PoolAddress: pool, SwapTime: 150, TransactionHash: "0xwindow", BlockHash: "0xabc", LogIndex: 9})
+
+	swaps, err := GetSwapEventsByPool(1, pool, 100, 200)
+	if err != nil {
+		t.Fatalf("GetSwapEventsByPool() error = %v", err)
+	}
+	if len(swaps) != 2 || swaps[0].SwapTime != 120 || swaps[1].SwapTime != 150 {
+		t.Fatalf("GetSwapEventsByPool() = %v, want the swaps at 120 and 150", swaps)
+	}
+	if !swaps[0].AmountUSDC.Equal(decimal.NewFromInt(3)) || !swaps[0].Amount0In.Equal(decimal.NewFromInt(1000)) || swaps[0].SenderAddress != "0xtrader" || swaps[0].LogIndex != 2 || swaps[0].TxIndex != 6 {
+		t.Errorf("GetSwapEventsByPool() = %v, want the stored fields", swaps[0])
+	}
+}
+
+func TestGetSwapEventsByAddress(t *testing.T) {
+	userID, _ := CreateUser("TestGetSwapEventsByAddress")
+	pool := "TestGetSwapEventsByAddress"
+	swaps := []UserSwap{
+		{SenderAddress: "0xtrader", SwapTime: 100},
+		{SenderAddress: "0xtrader", SwapTime: 101},
+		{SenderAddress: "0xrouter", RecipientAddress: "0xtrader", SwapTime: 150},
+		{SenderAddress: "0xrouter", OriginAddress: "0xtrader", SwapTime: 199},
+		{SenderAddress: "0xother", SwapTime: 150},
+		{SenderAddress: "0xtrader", SwapTime: 200},
+	}
+	for i, swap := range swaps {
+		swap.ChainID, swap.UserID, swap.PoolAddress = 1, userID, pool
+		swap.TransactionHash, swap.BlockNumber, swap.BlockHash, swap.LogIndex = "0xaddress", uint64(i), "0xabc", uint(i)
+		// nolint
+		InsertSwapEvent(swap)
+	}
+
+	got, err := GetSwapEventsByAddress(1, pool, "0xtrader", 100, 200)
+	if err != nil {
+		t.Fatalf("GetSwapEventsByAddress() error = %v", err)
+	}
+	if len(got) != 3 || got[0].SwapTime != 101 || got[1].SwapTime != 150 || got[2].SwapTime != 199 {
+		t.Errorf("GetSwapEventsByAddress() = %v, want the swaps at 101, 150 and 199", got)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/lib/pq"
 )
 
 func initUserTaskTable() {
@@ -14,11 +17,13 @@ func initUserTaskTable() {
 		user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
 		task_id INT REFERENCES tasks(task_id) ON DELETE CASCADE,
 		completed BOOLEAN DEFAULT FALSE,
-		amount FLOAT DEFAULT 0 CHECK (amount >= 0),
-		points FLOAT DEFAULT 0 CHECK (points >= 0),
+		amount NUMERIC DEFAULT 0 CHECK (amount >= 0),
+		points NUMERIC(38, 6) DEFAULT 0 CHECK (points >= 0),
 		created_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW()),
 		updated_at BIGINT DEFAULT EXTRACT(EPOCH FROM NOW())
 	);
+	ALTER TABLE user_tasks ALTER COLUMN amount TYPE NUMERIC;
+	ALTER TABLE user_tasks ALTER COLUMN points TYPE NUMERIC(38, 6);
 	CREATE INDEX IF NOT EXISTS idx_user_id_task_id ON user_tasks(user_id, task_id);`
 
 	_, err := db.Exec(query)
@@ -28,7 +33,7 @@ func initUserTaskTable() {
 	fmt.Println("UserTasks table and indexes checked/created.")
 }
 
-func CreateUserTask(userID, taskID int, completed bool, amount, points float64) (int, error) {
+func CreateUserTask(userID, taskID int, completed bool, amount, points decimal.Decimal) (int, error) {
 	var userTaskID int
 	query := `INSERT INTO user_tasks (user_id, task_id, completed, amount, points, created_at) 
 	VALUES ($1, $2, $3, $4, $5, $6) RETURNING user_task_id`
@@ -77,11 +82,11 @@ func GetUserTasksByUserIDTaskIDs(userID int, taskIDs []int) ([]UserTask, error)
 func GetOrCreateOnboardingUserTask(userID, taskID int) (UserTask, error) {
 	userTask, err := GetUserTaskByUserIDTaskID(userID, taskID)
 	if err == sql.ErrNoRows {
-		userTaskID, err := CreateUserTask(userID, taskID, false, 0, 0)
+		userTaskID, err := CreateUserTask(userID, taskID, false, decimal.Zero(), decimal.Zero())
 		if err != nil {
 			return UserTask{}, fmt.Errorf("failed to create onboarding user task: %w", err)
 		}
-		return UserTask{UserTaskID: userTaskID, UserID: userID, TaskID: taskID, Completed: false, Amount: 0, Points: 0}, nil
+		return UserTask{UserTaskID: userTaskID, UserID: userID, TaskID: taskID, Completed: false}, nil
 	} else if err != nil {
 		return UserTask{}, fmt.Errorf("failed to get user task: %w", err)
 	}
@@ -98,20 +103,47 @@ func GetUserTaskByUserIDTaskID(userID, taskID int) (UserTask, error) {
 	return userTask, nil
 }
 
-func UpdateUserTask(userTaskID int, completed bool, amount, points float64) error {
+func UpdateUserTask(userTaskID int, completed bool, amount, points decimal.Decimal) error {
 	query := `UPDATE user_tasks SET completed = $2, amount = $3, points = $4, updated_at = $5 WHERE user_task_id = $1`
 	_, err := db.Exec(query, userTaskID, completed, amount, points, time.Now().Unix())
 	return err
 }
 
-func IncreaseUserTaskAmount(taskID int, userID int, amount float64) error {
+func IncreaseUserTaskAmount(taskID int, userID int, amount decimal.Decimal) error {
 	query := `UPDATE user_tasks SET amount = amount + $3, updated_at = $4 WHERE task_id = $1 AND user_id = $2`
 	_, err := db.Exec(query, taskID, userID, amount, time.Now().Unix())
 	return err
 }
 
-func UpdateUserTaskByUserIDTaskID(userID, taskID int, completed bool, amount, points float64) error {
+func DecreaseUserTaskAmount(taskID int, userID int, amount decimal.Decimal) error {
+	query := `UPDATE user_tasks SET amount = GREATEST(amount - $3, 0), updated_at = $4 WHERE task_id = $1 AND user_id = $2 AND completed = FALSE`
+	_, err := db.Exec(query, taskID, userID, amount, time.Now().Unix())
+	return err
+}
+
+func UpdateUserTaskByUserIDTaskID(userID, taskID int, completed bool, amount, points decimal.Decimal) error {
 	query := `UPDATE user_tasks SET completed = $3, amount = $4, points = $5, updated_at = $6 WHERE user_id = $1 AND task_id = $2`
 	_, err := db.Exec(query, userID, taskID, completed, amount, points, time.Now().Unix())
 	return err
 }
+
+// RevokeUserTaskRewards takes back the points a user earned from the tasks of
+// the given types in the campaigns of a chain: their points history entries
+// are deleted and the user tasks are left uncompleted without points, keeping
+// their amount.
+func RevokeUserTaskRewards(userID int, chainID uint64, taskTypes []string) error {
+	query := `
+	WITH revoked AS (
+		SELECT t.task_id FROM tasks t JOIN campaigns c ON c.campaign_id = t.campaign_id
+		WHERE c.chain_id = $2 AND t.type = ANY($3)
+	), history AS (
+		DELETE FROM user_points_history WHERE user_id = $1 AND task_id IN (SELECT task_id FROM revoked)
+	)
+	UPDATE user_tasks SET completed = FALSE, points = 0, updated_at = $4
+	WHERE user_id = $1 AND task_id IN (SELECT task_id FROM revoked)`
+	_, err := db.Exec(query, userID, chainID, pq.Array(taskTypes), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to revoke user task rewards: %w", err)
+	}
+	return nil
+}
@@ -1,20 +1,23 @@
 package database
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
 )
 
 func TestCreateUserTask(t *testing.T) {
 	userID, _ := CreateUser("TestCreateUserTask")
-	campaignID, _ := CreateCampaign("TestCreateUserTask", "TestCreateUserTask", 0, 1)
-	taskID, _ := CreateOnboardingTask(campaignID, "TestCreateUserTask", 0, 1, 0, 1)
+	campaignID, _ := CreateCampaign(1, "TestCreateUserTask", "TestCreateUserTask", "uniswap_v2", "sender", 0, 1)
+	taskID, _ := CreateTask(campaignID, "onboarding", "TestCreateUserTask", json.RawMessage(`{"reward":"0","threshold":"1"}`), 0, 1)
 	type args struct {
 		userID    int
 		taskID    int
 		completed bool
-		amount    float64
-		points    float64
+		amount    decimal.Decimal
+		points    decimal.Decimal
 	}
 	tests := []struct {
 		name    string
@@ -27,8 +30,8 @@ func TestCreateUserTask(t *testing.T) {
 				userID:    userID,
 				taskID:    taskID,
 				completed: false,
-				amount:    0,
-				points:    0,
+				amount:    decimal.NewFromInt(0),
+				points:    decimal.NewFromInt(0),
 			},
 			wantErr: false,
 		},
@@ -38,8 +41,8 @@ func TestCreateUserTask(t *testing.T) {
 				userID:    -1,
 				taskID:    taskID,
 				completed: false,
-				amount:    0,
-				points:    0,
+				amount:    decimal.NewFromInt(0),
+				points:    decimal.NewFromInt(0),
 			},
 			wantErr: true,
 		},
@@ -57,9 +60,9 @@ func TestCreateUserTask(t *testing.T) {
 
 func TestGetUserTasksByUserID(t *testing.T) {
 	userID, _ := CreateUser("TestGetUserTasksByUserID")
-	campaignID, _ := CreateCampaign("TestGetUserTasksByUserID", "TestGetUserTasksByUserID", 0, 1)
-	taskID, _ := CreateOnboardingTask(campaignID, "TestGetUserTasksByUserID", 0, 1, 0, 1)
-	userTaskID, _ := CreateUserTask(userID, taskID, false, 0, 0)
+	campaignID, _ := CreateCampaign(1, "TestGetUserTasksByUserID", "TestGetUserTasksByUserID", "uniswap_v2", "sender", 0, 1)
+	taskID, _ := CreateTask(campaignID, "onboarding", "TestGetUserTasksByUserID", json.RawMessage(`{"reward":"0","threshold":"1"}`), 0, 1)
+	userTaskID, _ := CreateUserTask(userID, taskID, false, decimal.Zero(), decimal.Zero())
 	type args struct {
 		userID int
 	}
@@ -80,8 +83,8 @@ func TestGetUserTasksByUserID(t *testing.T) {
 					UserID:     userID,
 					TaskID:     taskID,
 					Completed:  false,
-					Amount:     0,
-					Points:     0,
+					Amount:     decimal.Zero(),
+					Points:     decimal.Zero(),
 				},
 			},
 			wantErr: false,
@@ -111,3 +114,46 @@ func TestGetUserTasksByUserID(t *testing.T) {
 		})
 	}
 }
+
+func TestRevokeUserTaskRewards(t *testing.T) {
+	userID, _ := CreateUser("TestRevokeUserTaskRewards")
+	campaignID, _ := CreateCampaign(1, "TestRevokeUserTaskRewards", "TestRevokeUserTaskRewards", "uniswap_v2", "sender", 0, 1)
+	otherCampaignID, _ := CreateCampaign(2, "TestRevokeUserTaskRewards", "TestRevokeUserTaskRewards", "uniswap_v2", "sender", 0, 1)
+	onboardingID, _ := CreateTask(campaignID, "onboarding", "TestRevokeUserTaskRewards", json.RawMessage(`{"reward":"100","threshold":"1000"}`), 0, 1)
+	sharePoolID, _ := CreateTask(campaignID, "share_pool", "TestRevokeUserTaskRewards", json.RawMessage(`{"pointsPool":"10000"}`), 0, 1)
+	otherChainID, _ := CreateTask(otherCampaignID, "onboarding", "TestRevokeUserTaskRewards", json.RawMessage(`{"reward":"100","threshold":"1000"}`), 0, 1)
+	for _, taskID := range []int{onboardingID, sharePoolID, otherChainID} {
+		// nolint
+		CreateUserTask(userID, taskID, true, decimal.NewFromInt(2000), decimal.NewFromInt(100))
+	}
+	// nolint
+	CreateUserPointsHistory(userID, onboardingID, campaignID, decimal.NewFromInt(100))
+	// nolint
+	CreateUserPointsHistory(userID, sharePoolID, campaignID, decimal.NewFromInt(100))
+	// nolint
+	CreateUserPointsHistory(userID, otherChainID, otherCampaignID, decimal.NewFromInt(100))
+
+	if err := RevokeUserTaskRewards(userID, 1, []string{"onboarding"}); err != nil {
+		t.Fatalf("RevokeUserTaskRewards() error = %v", err)
+	}
+
+	want := map[int]bool{onboardingID: false, sharePoolID: true, otherChainID: true}
+	for taskID, kept := range want {
+		userTask, err := GetUserTaskByUserIDTaskID(userID, taskID)
+		if err != nil {
+			t.Fatalf("GetUserTaskByUserIDTaskID() error = %v", err)
+		}
+		if userTask.Completed != kept || (userTask.Points.Sign() > 0) != kept || userTask.Amount.String() != "2000" {
+			t.Errorf("user task of task %d = %+v, kept = %v", taskID, userTask, kept)
+		}
+	}
+	history, _ := GetUserPointsHistoryByUserID(userID)
+	if len(history) != 2 {
+		t.Errorf("GetUserPointsHistoryByUserID() = %v, want the share pool and other chain entries", history)
+	}
+	for _, entry := range history {
+		if entry.TaskID == onboardingID {
+			t.Errorf("GetUserPointsHistoryByUserID() kept %+v", entry)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package decimal
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxScale bounds the digits kept for quotients without a finite decimal
+// expansion, such as dividing by three.
+const maxScale = 18
+
+const maxExponent = 100
+
+var (
+	one = big.NewInt(1)
+	ten = big.NewInt(10)
+)
+
+// Decimal is an exact decimal number. It is kept in canonical string form so
+// equal values compare equal, and the zero value is 0.
+type Decimal struct {
+	value string
+}
+
+func Zero() Decimal {
+	return Decimal{}
+}
+
+func NewFromInt(value int64) Decimal {
+	return fromRat(new(big.Rat).SetInt64(value))
+}
+
+// NewFromBigInt returns value * 10^-scale, e.g. a raw token amount and its
+// decimals. A nil value is zero.
+func NewFromBigInt(value *big.Int, scale int) Decimal {
+	if value == nil {
+		return Decimal{}
+	}
+	return fromRat(new(big.Rat).SetFrac(value, pow10(scale)))
+}
+
+func NewFromString(value string) (Decimal, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") || !validExponent(value) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", value)
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", value)
+	}
+	return fromRat(r), nil
+}
+
+// validExponent rejects exponents large enough to make parsing expensive.
+func validExponent(value string) bool {
+	i := strings.IndexAny(value, "eE")
+	if i < 0 {
+		return true
+	}
+	exp, err := strconv.Atoi(value[i+1:])
+	return err == nil && exp >= -maxExponent && exp <= maxExponent
+}
+
+func RequireFromString(value string) Decimal {
+	d, err := NewFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func pow10(scale int) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(scale)), nil)
+}
+
+// fromRat rounds r down to maxScale digits when it has no finite decimal
+// expansion and returns its canonical form.
+func fromRat(r *big.Rat) Decimal {
+	if r.Sign() == 0 {
+		return Decimal{}
+	}
+	scale := scaleOf(r.Denom())
+	if scale > maxScale {
+		r = floorRat(r, maxScale)
+		scale = scaleOf(r.Denom())
+	}
+	s := r.FloatString(scale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return Decimal{value: s}
+}
+
+func floorRat(r *big.Rat, scale int) *big.Rat {
+	unit := pow10(scale)
+	scaled := new(big.Int).Mul(r.Num(), unit)
+	floored := new(big.Int).Div(scaled, r.Denom())
+	return new(big.Rat).SetFrac(floored, unit)
+}
+
+// scaleOf returns the digits needed to print 1/denom exactly, or more than
+// maxScale when denom has prime factors other than 2 and 5.
+func scaleOf(denom *big.Int) int {
+	rest := new(big.Int).Set(denom)
+	twos, fives := 0, 0
+	mod := new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(rest, big.NewInt(2), mod)
+		if m.Sign() != 0 {
+			break
+		}
+		rest, twos = q, twos+1
+	}
+	for {
+		q, m := new(big.Int).QuoRem(rest, big.NewInt(5), mod)
+		if m.Sign() != 0 {
+			break
+		}
+		rest, fives = q, fives+1
+	}
+	if rest.Cmp(one) != 0 {
+		return maxScale + 1
+	}
+	return max(twos, fives)
+}
+
+func (d Decimal) rat() *big.Rat {
+	r := new(big.Rat)
+	if d.value != "" {
+		r.SetString(d.value)
+	}
+	return r
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return fromRat(new(big.Rat).Add(d.rat(), other.rat()))
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return fromRat(new(big.Rat).Sub(d.rat(), other.rat()))
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return fromRat(new(big.Rat).Mul(d.rat(), other.rat()))
+}
+
+// Div returns d / other, rounded down to maxScale digits when the quotient
+// has no finite decimal expansion, or zero when other is zero.
+func (d Decimal) Div(other Decimal) Decimal {
+	if other.Sign() == 0 {
+		return Decimal{}
+	}
+	return fromRat(new(big.Rat).Quo(d.rat(), other.rat()))
+}
+
+func (d Decimal) Neg() Decimal {
+	return fromRat(new(big.Rat).Neg(d.rat()))
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	return d.rat().Cmp(other.rat())
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.value == "":
+		return 0
+	case strings.HasPrefix(d.value, "-"):
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func Max(a, b Decimal) Decimal {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// Floor rounds d down to the given number of fractional digits.
+func (d Decimal) Floor(scale int) Decimal {
+	return fromRat(floorRat(d.rat(), scale))
+}
+
+// Units returns d * 10^scale rounded down, e.g. the number of 1e-6 steps.
+func (d Decimal) Units(scale int) *big.Int {
+	r := d.rat()
+	scaled := new(big.Int).Mul(r.Num(), pow10(scale))
+	return scaled.Div(scaled, r.Denom())
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+func (d Decimal) String() string {
+	if d.value == "" {
+		return "0"
+	}
+	return d.value
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	value := string(data)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	parsed, err := NewFromString(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	case int64:
+		*d = NewFromInt(v)
+		return nil
+	case float64:
+		return d.scanString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", value)
+	}
+}
+
+func (d *Decimal) scanString(value string) error {
+	parsed, err := NewFromString(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
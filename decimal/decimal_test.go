@@ -0,0 +1,124 @@
+package decimal
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Integer", value: "1000", want: "1000"},
+		{name: "Fraction", value: "1678.021288", want: "1678.021288"},
+		{name: "Trailing zeros", value: "12.500000", want: "12.5"},
+		{name: "Zero", value: "0.000000", want: "0"},
+		{name: "Negative", value: "-0.25", want: "-0.25"},
+		{name: "Exponent", value: "1.5e3", want: "1500"},
+		{name: "Large", value: "123456789012345678901234567890.123456789", want: "123456789012345678901234567890.123456789"},
+		{name: "Invalid", value: "abc", wantErr: true},
+		{name: "Ratio", value: "1/3", wantErr: true},
+		{name: "Huge exponent", value: "1e1000000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFromString(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := RequireFromString("0.1")
+	b := RequireFromString("0.2")
+	assert.Equal(t, RequireFromString("0.3"), a.Add(b))
+	assert.Equal(t, RequireFromString("-0.1"), a.Sub(b))
+	assert.Equal(t, RequireFromString("0.02"), a.Mul(b))
+	assert.Equal(t, RequireFromString("0.5"), a.Div(b))
+	assert.Equal(t, RequireFromString("0.333333333333333333"), NewFromInt(1).Div(NewFromInt(3)))
+	assert.Equal(t, Zero(), a.Div(Zero()))
+	assert.Equal(t, Zero(), a.Sub(a))
+	assert.Equal(t, 1, b.Cmp(a))
+	assert.Equal(t, b, Max(a, b))
+}
+
+func TestFloor(t *testing.T) {
+	tests := []struct {
+		value string
+		scale int
+		want  string
+	}{
+		{value: "1.23456789", scale: 6, want: "1.234567"},
+		{value: "1.2", scale: 6, want: "1.2"},
+		{value: "-1.23456789", scale: 6, want: "-1.234568"},
+		{value: "999.9999999", scale: 0, want: "999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.want, RequireFromString(tt.value).Floor(tt.scale).String())
+		})
+	}
+}
+
+func TestNewFromBigInt(t *testing.T) {
+	amount, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.Equal(t, "123456789012.34567890123456789", NewFromBigInt(amount, 18).String())
+	assert.Equal(t, "400", NewFromBigInt(big.NewInt(400000000), 6).String())
+	assert.Equal(t, big.NewInt(1234567), RequireFromString("1.2345678").Units(6))
+}
+
+func TestJSON(t *testing.T) {
+	var req struct {
+		Number Decimal `json:"number"`
+		String Decimal `json:"string"`
+		Null   Decimal `json:"null"`
+	}
+	err := json.Unmarshal([]byte(`{"number": 1000.123456, "string": "0.000001", "null": null}`), &req)
+	assert.NoError(t, err)
+	assert.Equal(t, "1000.123456", req.Number.String())
+	assert.Equal(t, "0.000001", req.String.String())
+	assert.True(t, req.Null.IsZero())
+
+	data, err := json.Marshal(req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"number": 1000.123456, "string": 0.000001, "null": 0}`, string(data))
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    Decimal
+		wantErr bool
+	}{
+		{name: "Numeric", value: []byte("1678.021288"), want: RequireFromString("1678.021288")},
+		{name: "String", value: "0.000000", want: Zero()},
+		{name: "Int", value: int64(5), want: NewFromInt(5)},
+		{name: "Float", value: 0.1, want: RequireFromString("0.1")},
+		{name: "Null", value: nil, want: Zero()},
+		{name: "Invalid", value: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Decimal
+			err := got.Scan(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -6,49 +6,67 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// Watched pool addresses are kept per chain, since the same address can be a
+// different contract on every chain.
 var (
-	addresses     = make(map[common.Address]struct{})
-	addressesLock sync.Mutex
-	notifyChannel = make(chan struct{}, 1)
+	addresses      = make(map[uint64]map[common.Address]struct{})
+	addressesLock  sync.Mutex
+	notifyChannels = make(map[uint64]chan struct{})
 )
 
-func AddAddresses(addrs []string) {
+func AddAddresses(chainID uint64, addrs []string) {
 	addressesLock.Lock()
 	defer addressesLock.Unlock()
 
+	if addresses[chainID] == nil {
+		addresses[chainID] = make(map[common.Address]struct{})
+	}
 	for _, addr := range addrs {
-		addresses[common.HexToAddress(addr)] = struct{}{}
+		addresses[chainID][common.HexToAddress(addr)] = struct{}{}
 	}
-	notifyChange()
+	notifyChange(chainID)
 }
 
-func RemoveAddresses(addrs []string) {
+func RemoveAddresses(chainID uint64, addrs []string) {
 	addressesLock.Lock()
 	defer addressesLock.Unlock()
 
 	for _, addr := range addrs {
-		delete(addresses, common.HexToAddress(addr))
+		delete(addresses[chainID], common.HexToAddress(addr))
 	}
-	notifyChange()
+	notifyChange(chainID)
 }
 
-func GetAddresses() []common.Address {
+func GetAddresses(chainID uint64) []common.Address {
 	addressesLock.Lock()
 	defer addressesLock.Unlock()
 	var addrList []common.Address
-	for addr := range addresses {
+	for addr := range addresses[chainID] {
 		addrList = append(addrList, addr)
 	}
 	return addrList
 }
 
-func notifyChange() {
+// notifyChannel returns the channel of the chain, creating it on first use.
+// The caller must hold addressesLock.
+func notifyChannel(chainID uint64) chan struct{} {
+	ch, ok := notifyChannels[chainID]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		notifyChannels[chainID] = ch
+	}
+	return ch
+}
+
+func notifyChange(chainID uint64) {
 	select {
-	case notifyChannel <- struct{}{}:
+	case notifyChannel(chainID) <- struct{}{}:
 	default:
 	}
 }
 
-func GetNotifyChannel() <-chan struct{} {
-	return notifyChannel
+func GetNotifyChannel(chainID uint64) <-chan struct{} {
+	addressesLock.Lock()
+	defer addressesLock.Unlock()
+	return notifyChannel(chainID)
 }
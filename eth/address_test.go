@@ -18,9 +18,9 @@ func TestAddAddresses(t *testing.T) {
 		common.HexToAddress("0x789"),
 	}
 
-	AddAddresses(addressesToAdd)
+	AddAddresses(MainnetChainID, addressesToAdd)
 
-	result := GetAddresses()
+	result := GetAddresses(MainnetChainID)
 
 	assert.ElementsMatch(t, result, expected, "Added addresses do not match expected addresses")
 }
@@ -31,19 +31,30 @@ func TestRemoveAddresses(t *testing.T) {
 	addressesToAdd := []string{"0x123", "0x456", "0x789"}
 	addressesToRemove := []string{"0x456"}
 
-	AddAddresses(addressesToAdd)
-	RemoveAddresses(addressesToRemove)
+	AddAddresses(MainnetChainID, addressesToAdd)
+	RemoveAddresses(MainnetChainID, addressesToRemove)
 
 	expected := []common.Address{
 		common.HexToAddress("0x123"),
 		common.HexToAddress("0x789"),
 	}
 
-	result := GetAddresses()
+	result := GetAddresses(MainnetChainID)
 
 	assert.ElementsMatch(t, result, expected, "Addresses after removal do not match expected addresses")
 }
 
+func TestAddressesPerChain(t *testing.T) {
+	resetAddresses()
+
+	AddAddresses(MainnetChainID, []string{"0x123", "0x456"})
+	AddAddresses(42161, []string{"0x123"})
+	RemoveAddresses(42161, []string{"0x123"})
+
+	assert.ElementsMatch(t, []common.Address{common.HexToAddress("0x123"), common.HexToAddress("0x456")}, GetAddresses(MainnetChainID))
+	assert.Empty(t, GetAddresses(42161))
+}
+
 func TestNotifyChannel(t *testing.T) {
 	resetAddresses()
 
@@ -51,25 +62,29 @@ func TestNotifyChannel(t *testing.T) {
 	done := make(chan struct{})
 
 	go func() {
-		<-GetNotifyChannel()
+		<-GetNotifyChannel(MainnetChainID)
 		done <- struct{}{}
 	}()
 
-	AddAddresses(addressesToAdd)
+	AddAddresses(MainnetChainID, addressesToAdd)
 
 	select {
 	case <-done:
 	case <-time.After(1 * time.Second):
 		t.Error("Expected notification, but channel was not notified")
 	}
+
+	AddAddresses(10, addressesToAdd)
+	select {
+	case <-GetNotifyChannel(MainnetChainID):
+		t.Error("Change on another chain should not notify mainnet")
+	default:
+	}
 }
 
 func resetAddresses() {
 	addressesLock.Lock()
 	defer addressesLock.Unlock()
-	addresses = make(map[common.Address]struct{})
-	select {
-	case <-notifyChannel:
-	default:
-	}
+	addresses = make(map[uint64]map[common.Address]struct{})
+	notifyChannels = make(map[uint64]chan struct{})
 }
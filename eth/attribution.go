@@ -0,0 +1,128 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Attribution modes decide which address of a swap is credited as the trader.
+// The Swap sender is usually a router, so origin credits the account that
+// signed the transaction instead.
+const (
+	AttributionSender    = "sender"
+	AttributionRecipient = "recipient"
+	AttributionOrigin    = "origin"
+)
+
+var ErrUnknownAttribution = errors.New("unknown attribution mode")
+
+// NormalizeAttribution returns the attribution mode for a campaign,
+// defaulting to the Swap sender when none is given.
+func NormalizeAttribution(attribution string) (string, error) {
+	attribution = strings.ToLower(strings.TrimSpace(attribution))
+	switch attribution {
+	case "":
+		return AttributionSender, nil
+	case AttributionSender, AttributionRecipient, AttributionOrigin:
+		return attribution, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownAttribution, attribution)
+	}
+}
+
+// traderAddress picks the credited address out of the addresses of a swap.
+// It is empty when the origin was never resolved.
+func traderAddress(attribution, sender, recipient, origin string) string {
+	switch attribution {
+	case AttributionRecipient:
+		return recipient
+	case AttributionOrigin:
+		return origin
+	default:
+		return sender
+	}
+}
+
+// swapUser returns the address stored as the user of a swap: the trader
+// credited by the first campaign of its pool, or the Swap sender if the pool
+// has no campaign. Campaigns of one pool with different attribution modes
+// each credit their own trader, whatever the stored user.
+func swapUser(campaigns []database.Campaign, sender, recipient, origin string) string {
+	if len(campaigns) == 0 {
+		return sender
+	}
+	return traderAddress(campaigns[0].Attribution, sender, recipient, origin)
+}
+
+func needsOrigin(campaigns []database.Campaign) bool {
+	for _, campaign := range campaigns {
+		if campaign.Attribution == AttributionOrigin {
+			return true
+		}
+	}
+	return false
+}
+
+// AttributeSwaps sets the Trader of every swap according to the attribution
+// mode, looking up the transaction origin when needed.
+func AttributeSwaps(client ChainClient, swaps []SwapInfo, attribution string) error {
+	if attribution == AttributionOrigin {
+		err := resolveOrigins(client, swaps)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range swaps {
+		swaps[i].Trader = traderAddress(attribution, swaps[i].Sender, swaps[i].Recipient, swaps[i].Origin)
+	}
+	return nil
+}
+
+// resolveOrigins looks up the transaction origin of the swaps without one.
+func resolveOrigins(client ChainClient, swaps []SwapInfo) error {
+	origins := make(map[string]string)
+	for i := range swaps {
+		swap := &swaps[i]
+		if swap.Origin != "" {
+			continue
+		}
+		origin, ok := origins[swap.TxHash]
+		if !ok {
+			address, err := transactionOrigin(client, *swap)
+			if err != nil {
+				return err
+			}
+			origin = address.Hex()
+			origins[swap.TxHash] = origin
+		}
+		swap.Origin = origin
+	}
+	return nil
+}
+
+// transactionOrigin returns the account that signed the transaction of a swap.
+// The sender reported by the node is looked up by the block and index of the
+// transaction; when that fails, for instance for a swap stored before its
+// transaction index was, the sender is recovered from the signature.
+func transactionOrigin(client ChainClient, swap SwapInfo) (common.Address, error) {
+	ctx := context.Background()
+	tx, _, err := client.TransactionByHash(ctx, common.HexToHash(swap.TxHash))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get transaction %s: %w", swap.TxHash, err)
+	}
+	sender, err := client.TransactionSender(ctx, tx, common.HexToHash(swap.BlockHash), swap.TxIndex)
+	if err == nil {
+		return sender, nil
+	}
+	sender, signerErr := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if signerErr != nil {
+		return common.Address{}, fmt.Errorf("failed to get sender of transaction %s: %w", swap.TxHash, err)
+	}
+	return sender, nil
+}
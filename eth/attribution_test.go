@@ -0,0 +1,150 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAttribution(t *testing.T) {
+	tests := []struct {
+		name        string
+		attribution string
+		want        string
+		wantErr     bool
+	}{
+		{name: "Default", attribution: "", want: AttributionSender},
+		{name: "Recipient", attribution: "recipient", want: AttributionRecipient},
+		{name: "Origin", attribution: " Origin ", want: AttributionOrigin},
+		{name: "Unknown", attribution: "router", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeAttribution(tt.attribution)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownAttribution)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAttributeSwaps(t *testing.T) {
+	router := "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"
+	recipient := "0x19D1B048c8CDb4Cc280676627CE8c05756C5519e"
+	trader := common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD")
+	txHash := "0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70"
+
+	newSwaps := func() []SwapInfo {
+		return []SwapInfo{
+			{Sender: router, Recipient: recipient, TxHash: txHash, LogIndex: 0},
+			{Sender: router, Recipient: recipient, TxHash: txHash, LogIndex: 1},
+		}
+	}
+
+	lookups := 0
+	client := &mockClient{
+		transactionByHash: func(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+			lookups++
+			if hash.Hex() != txHash {
+				return nil, false, errors.New("not found")
+			}
+			return types.NewTx(&types.LegacyTx{}), false, nil
+		},
+		transactionSender: func(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+			return trader, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		attribution string
+		want        string
+		wantLookups int
+	}{
+		{name: "Sender", attribution: AttributionSender, want: router},
+		{name: "Recipient", attribution: AttributionRecipient, want: recipient},
+		{name: "Origin", attribution: AttributionOrigin, want: trader.Hex(), wantLookups: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups = 0
+			swaps := newSwaps()
+			err := AttributeSwaps(client, swaps, tt.attribution)
+			assert.NoError(t, err)
+			for _, swap := range swaps {
+				assert.Equal(t, tt.want, swap.Trader)
+			}
+			assert.Equal(t, tt.wantLookups, lookups)
+		})
+	}
+
+	swaps := []SwapInfo{{Sender: router, TxHash: "0x01"}}
+	err := AttributeSwaps(client, swaps, AttributionOrigin)
+	assert.Error(t, err)
+}
+
+func Test_transactionOrigin(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	signed, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{ChainID: big.NewInt(1)})
+	assert.NoError(t, err)
+	reported := common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD")
+
+	tests := []struct {
+		name      string
+		tx        *types.Transaction
+		senderErr error
+		want      common.Address
+		wantErr   bool
+	}{
+		{name: "Reported by the node", tx: signed, want: reported},
+		{name: "Recovered from the signature", tx: signed, senderErr: errors.New("wrong inclusion block/index"), want: signer},
+		{name: "Unsigned", tx: types.NewTx(&types.LegacyTx{}), senderErr: errors.New("wrong inclusion block/index"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var index uint
+			client := &mockClient{
+				transactionByHash: func(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+					return tt.tx, false, nil
+				},
+				transactionSender: func(ctx context.Context, tx *types.Transaction, block common.Hash, i uint) (common.Address, error) {
+					index = i
+					return reported, tt.senderErr
+				},
+			}
+			got, err := transactionOrigin(client, SwapInfo{TxHash: "0x01", TxIndex: 7})
+			assert.Equal(t, uint(7), index)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_traderAddress(t *testing.T) {
+	assert.Equal(t, "s", traderAddress(AttributionSender, "s", "r", "o"))
+	assert.Equal(t, "r", traderAddress(AttributionRecipient, "s", "r", "o"))
+	assert.Equal(t, "o", traderAddress(AttributionOrigin, "s", "r", "o"))
+	assert.Equal(t, "", traderAddress(AttributionOrigin, "s", "r", ""))
+}
+
+func Test_swapUser(t *testing.T) {
+	campaigns := []database.Campaign{{Attribution: AttributionOrigin}, {Attribution: AttributionSender}}
+	assert.Equal(t, "o", swapUser(campaigns, "s", "r", "o"))
+	assert.Equal(t, "s", swapUser(nil, "s", "r", "o"))
+}
@@ -0,0 +1,100 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrIngestionLocked is returned by Backfill while a server runs a listener
+// of the chain.
+var ErrIngestionLocked = errors.New("ingestion of the chain is locked by a running server, stop it before backfilling")
+
+// BackfillProgress reports how far a backfill got. Blocks from FromBlock to
+// Block have been processed.
+type BackfillProgress struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Block     uint64
+	Stored    int
+	Skipped   int
+}
+
+// Backfill re-ingests the swaps of a pool between two times through the same
+// parse and persist path as the listener, and updates the tasks of every
+// campaign on the pool synchronously. Swaps that are already stored are
+// skipped, so a range can be backfilled any number of times, and a swap that
+// cannot be stored stops the backfill. Blocks without enough confirmations
+// are left to the listener. report, if not nil, is called after every chunk.
+//
+// Tasks are updated outside the pipeline of a running listener: a swap stored
+// by one and not yet marked accrued would be credited by the other too, and
+// the swaps of a user would no longer be credited in order. Backfill
+// therefore takes the ingestion lock of the chain and fails with
+// ErrIngestionLocked while a listener holds it. A listener keeps the lock for
+// as long as its server runs, so the server must be stopped before
+// backfilling.
+func Backfill(chain *Chain, poolAddress string, startTime, endTime int64, report func(BackfillProgress)) (BackfillProgress, error) {
+	unlock, ok, err := database.TryLockIngestion(chain.ID)
+	if err != nil {
+		return BackfillProgress{}, err
+	}
+	if !ok {
+		return BackfillProgress{}, fmt.Errorf("%w: %s", ErrIngestionLocked, chain.Name)
+	}
+	defer unlock()
+
+	header, err := chain.Client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return BackfillProgress{}, fmt.Errorf("failed to get latest header: %w", err)
+	}
+	head := safeBlock(header.Number.Uint64(), chain.Confirmations)
+
+	startBlock, err := timeToBlockNumber(chain, startTime, 0, head)
+	if err != nil {
+		return BackfillProgress{}, fmt.Errorf("failed to find start block: %w", err)
+	}
+	endBlock, err := timeToBlockNumber(chain, endTime, startBlock, head)
+	if err != nil {
+		return BackfillProgress{}, fmt.Errorf("failed to find end block: %w", err)
+	}
+
+	progress := BackfillProgress{FromBlock: startBlock + 1, ToBlock: endBlock, Block: startBlock}
+	err = filterSwapLogs(chain, common.HexToAddress(poolAddress), startBlock+1, endBlock, func(logs []types.Log, end uint64) error {
+		swapInfos, err := ParseSwapEvents(chain, logs)
+		if err != nil {
+			return fmt.Errorf("failed to parse Swap events: %w", err)
+		}
+
+		for _, swapInfo := range swapInfos {
+			campaigns, accrue, err := insertSwapEvent(chain, &swapInfo)
+			if err != nil {
+				return err
+			}
+			if !accrue {
+				progress.Skipped++
+				continue
+			}
+			err = updateTask(campaigns, swapInfo.Sender, swapInfo.Recipient, swapInfo.Origin, swapInfo.USD, swapInfo.Timestamp)
+			if err != nil {
+				return err
+			}
+			err = database.MarkSwapAccrued(chain.ID, swapInfo.TxHash, swapInfo.LogIndex)
+			if err != nil {
+				return err
+			}
+			progress.Stored++
+		}
+
+		progress.Block = end
+		if report != nil {
+			report(progress)
+		}
+		return nil
+	})
+	return progress, err
+}
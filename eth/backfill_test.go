@@ -0,0 +1,88 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfill(t *testing.T) {
+	usdcPool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	setPoolCache(database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    usdcPool.Hex(),
+		Protocol:       ProtocolUniswapV2,
+		Token0:         "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		Token1:         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+		Token0Decimals: 6,
+		Token1Decimals: 18,
+	})
+	swapLog := types.Log{
+		Address: usdcPool,
+		Topics: []common.Hash{
+			common.HexToHash(swapEventTopicHash),
+			common.HexToHash("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+			common.HexToHash("0x19D1B048c8CDb4Cc280676627CE8c05756C5519e"),
+		},
+		Data:        common.FromHex("0x0000000000000000000000000000000000000000000000000000000017d784000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000001c8d2577d58988e"),
+		BlockNumber: 150,
+		TxHash:      common.HexToHash("0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70"),
+	}
+
+	// Blocks are 12 seconds apart, starting at 1000.
+	resetBlockTimes()
+	var queries [][2]uint64
+	client := &mockClient{
+		headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+			n := uint64(5000)
+			if number != nil {
+				n = number.Uint64()
+			}
+			return &types.Header{Number: new(big.Int).SetUint64(n), Time: 1000 + 12*n}, nil
+		},
+		filterLogs: func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+			queries = append(queries, [2]uint64{query.FromBlock.Uint64(), query.ToBlock.Uint64()})
+			if query.FromBlock.Uint64() <= swapLog.BlockNumber && swapLog.BlockNumber <= query.ToBlock.Uint64() {
+				return []types.Log{swapLog}, nil
+			}
+			return nil, nil
+		},
+	}
+	chain := testChain(client)
+	chain.Confirmations = 10
+
+	locked := false
+	unlocked := false
+	patches := gomonkey.ApplyFunc(database.TryLockIngestion, func(chainID uint64) (func(), bool, error) {
+		if locked {
+			return nil, false, nil
+		}
+		return func() { unlocked = true }, true, nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(insertSwapEvent, func(chain *Chain, swapInfo *SwapInfo) ([]database.Campaign, bool, error) {
+		return nil, false, nil
+	})
+
+	var reports []BackfillProgress
+	progress, err := Backfill(chain, usdcPool.Hex(), 1000+12*100, 1000+12*9000, func(p BackfillProgress) {
+		reports = append(reports, p)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]uint64{{101, 2100}, {2101, 4100}, {4101, 4990}}, queries, "range should stop at the last confirmed block")
+	assert.Equal(t, BackfillProgress{FromBlock: 101, ToBlock: 4990, Block: 4990, Skipped: 1}, progress)
+	assert.Len(t, reports, 3)
+	assert.Equal(t, uint64(2100), reports[0].Block)
+	assert.True(t, unlocked)
+
+	locked = true
+	_, err = Backfill(chain, usdcPool.Hex(), 1000+12*100, 1000+12*9000, nil)
+	assert.ErrorIs(t, err, ErrIngestionLocked)
+}
@@ -0,0 +1,172 @@
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultBlockIndexSize = 100000
+	headPollInterval      = 12 * time.Second
+	headPollRounds        = 10
+)
+
+var (
+	blockIndexes     = make(map[uint64]*blockTimeIndex)
+	blockIndexesLock sync.Mutex
+)
+
+type blockPoint struct {
+	Number uint64
+	Time   int64
+}
+
+// blockTimeIndex keeps known (block, timestamp) pairs ordered by block number
+// so timestamp lookups can start from the closest known points. When
+// persistence is enabled the block_times table is consulted as well.
+type blockTimeIndex struct {
+	lock      sync.RWMutex
+	chainID   uint64
+	points    []blockPoint
+	maxPoints int
+	persist   bool
+}
+
+func newBlockTimeIndex(chainID uint64, maxPoints int, persist bool) *blockTimeIndex {
+	if maxPoints <= 1 {
+		maxPoints = defaultBlockIndexSize
+	}
+	return &blockTimeIndex{chainID: chainID, maxPoints: maxPoints, persist: persist}
+}
+
+// getBlockTimeIndex returns the index of the chain, creating it on first use.
+func getBlockTimeIndex(chainID uint64) *blockTimeIndex {
+	blockIndexesLock.Lock()
+	defer blockIndexesLock.Unlock()
+	idx, ok := blockIndexes[chainID]
+	if !ok {
+		idx = newBlockTimeIndex(chainID, viper.GetInt("eth.block_index_size"), viper.GetBool("eth.block_time_persist"))
+		blockIndexes[chainID] = idx
+	}
+	return idx
+}
+
+func (idx *blockTimeIndex) record(point blockPoint) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	i := sort.Search(len(idx.points), func(i int) bool { return idx.points[i].Number >= point.Number })
+	if i < len(idx.points) && idx.points[i].Number == point.Number {
+		idx.points[i] = point
+		return
+	}
+	idx.points = append(idx.points, blockPoint{})
+	copy(idx.points[i+1:], idx.points[i:])
+	idx.points[i] = point
+
+	if len(idx.points) > idx.maxPoints {
+		idx.thin()
+	}
+}
+
+// thin halves the density of the older half of the index, keeping recent
+// blocks dense and old ranges sparse but still usable as search bounds.
+func (idx *blockTimeIndex) thin() {
+	half := len(idx.points) / 2
+	thinned := make([]blockPoint, 0, idx.maxPoints)
+	for i := 0; i < half; i += 2 {
+		thinned = append(thinned, idx.points[i])
+	}
+	idx.points = append(thinned, idx.points[half:]...)
+}
+
+// bounds returns the closest known points at or before and after targetTime.
+func (idx *blockTimeIndex) bounds(targetTime int64) (floor blockPoint, hasFloor bool, ceiling blockPoint, hasCeiling bool) {
+	idx.lock.RLock()
+	i := sort.Search(len(idx.points), func(i int) bool { return idx.points[i].Time > targetTime })
+	if i > 0 {
+		floor, hasFloor = idx.points[i-1], true
+	}
+	if i < len(idx.points) {
+		ceiling, hasCeiling = idx.points[i], true
+	}
+	idx.lock.RUnlock()
+
+	if !idx.persist {
+		return
+	}
+	number, t, err := database.GetBlockTimeFloor(idx.chainID, targetTime)
+	if err == nil && (!hasFloor || number > floor.Number) {
+		floor, hasFloor = blockPoint{Number: number, Time: t}, true
+	} else if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to query block time floor: %v", err)
+	}
+	number, t, err = database.GetBlockTimeCeiling(idx.chainID, targetTime)
+	if err == nil && (!hasCeiling || number < ceiling.Number) {
+		ceiling, hasCeiling = blockPoint{Number: number, Time: t}, true
+	} else if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to query block time ceiling: %v", err)
+	}
+	return
+}
+
+func rememberBlockTime(chainID uint64, number uint64, blockTime int64) {
+	getBlockTimeCache(chainID).add(number, blockTime)
+	getBlockTimeIndex(chainID).record(blockPoint{Number: number, Time: blockTime})
+}
+
+// IndexBlockTimes records the timestamp of every new head of the chain. It
+// subscribes to new heads when the transport supports it and polls the latest
+// header otherwise.
+func IndexBlockTimes(chain *Chain) {
+	for {
+		headers := make(chan *types.Header)
+		sub, err := chain.Client.SubscribeNewHead(context.Background(), headers)
+		if err != nil {
+			log.Printf("Failed to subscribe to new heads of %s, polling instead: %v", chain.Name, err)
+			pollHeads(chain)
+			continue
+		}
+
+	loop:
+		for {
+			select {
+			case err := <-sub.Err():
+				if err != nil {
+					log.Printf("New head subscription error on %s: %v", chain.Name, err)
+				}
+				break loop
+			case header := <-headers:
+				rememberBlockTime(chain.ID, header.Number.Uint64(), int64(header.Time))
+			}
+		}
+		sub.Unsubscribe()
+		time.Sleep(resubscribeDelay)
+	}
+}
+
+// pollHeads polls the latest header for a while before the caller retries
+// the subscription.
+func pollHeads(chain *Chain) {
+	var last uint64
+	ticker := time.NewTicker(headPollInterval)
+	defer ticker.Stop()
+	for i := 0; i < headPollRounds; i++ {
+		header, err := chain.Client.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			log.Printf("Failed to poll latest header of %s: %v", chain.Name, err)
+		} else if header.Number.Uint64() != last {
+			last = header.Number.Uint64()
+			rememberBlockTime(chain.ID, last, int64(header.Time))
+		}
+		<-ticker.C
+	}
+}
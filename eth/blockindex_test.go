@@ -0,0 +1,75 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockTimeIndexBounds(t *testing.T) {
+	idx := newBlockTimeIndex(MainnetChainID, 10, false)
+	idx.record(blockPoint{Number: 30, Time: 3000})
+	idx.record(blockPoint{Number: 10, Time: 1000})
+	idx.record(blockPoint{Number: 20, Time: 2000})
+
+	floor, hasFloor, ceiling, hasCeiling := idx.bounds(2500)
+	assert.True(t, hasFloor)
+	assert.True(t, hasCeiling)
+	assert.Equal(t, blockPoint{Number: 20, Time: 2000}, floor)
+	assert.Equal(t, blockPoint{Number: 30, Time: 3000}, ceiling)
+
+	_, hasFloor, ceiling, _ = idx.bounds(500)
+	assert.False(t, hasFloor)
+	assert.Equal(t, uint64(10), ceiling.Number)
+
+	floor, _, _, hasCeiling = idx.bounds(3000)
+	assert.False(t, hasCeiling)
+	assert.Equal(t, uint64(30), floor.Number)
+}
+
+func TestBlockTimeIndexThin(t *testing.T) {
+	idx := newBlockTimeIndex(MainnetChainID, 8, false)
+	for i := uint64(1); i <= 9; i++ {
+		idx.record(blockPoint{Number: i, Time: int64(i) * 12})
+	}
+
+	assert.LessOrEqual(t, len(idx.points), 8)
+	assert.Equal(t, uint64(1), idx.points[0].Number)
+	assert.Equal(t, uint64(9), idx.points[len(idx.points)-1].Number)
+	for i := 1; i < len(idx.points); i++ {
+		assert.Less(t, idx.points[i-1].Number, idx.points[i].Number)
+	}
+}
+
+func Test_timeToBlockNumberUsesIndex(t *testing.T) {
+	resetBlockTimes()
+
+	// 12 second blocks starting at block 1000.
+	blockTimeOf := func(number uint64) int64 { return 1633072800 + int64(number-1000)*12 }
+	var fetched []uint64
+	client := &mockClient{
+		headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+			if number == nil {
+				number = big.NewInt(2000)
+			}
+			fetched = append(fetched, number.Uint64())
+			return &types.Header{Number: number, Time: uint64(blockTimeOf(number.Uint64()))}, nil
+		},
+	}
+	rememberBlockTime(MainnetChainID, 1400, blockTimeOf(1400))
+	rememberBlockTime(MainnetChainID, 1600, blockTimeOf(1600))
+
+	block, err := timeToBlockNumber(testChain(client), blockTimeOf(1500)+5, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1500), block)
+	assert.LessOrEqual(t, len(fetched), 4, "interpolation between indexed points should need few lookups")
+
+	fetched = nil
+	block, err = timeToBlockNumber(testChain(client), blockTimeOf(1500)+5, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1500), block)
+	assert.Equal(t, []uint64{2000}, fetched, "repeated lookups should be answered from the index")
+}
@@ -0,0 +1,108 @@
+package eth
+
+import (
+	"container/list"
+	"database/sql"
+	"log"
+	"sync"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/spf13/viper"
+)
+
+const defaultBlockTimeCacheSize = 100000
+
+var (
+	blockTimes     = make(map[uint64]*blockTimeCache)
+	blockTimesLock sync.Mutex
+)
+
+type blockTimeEntry struct {
+	blockNumber uint64
+	blockTime   int64
+}
+
+// blockTimeCache is a bounded LRU of block timestamps, optionally backed by
+// the block_times table so lookups survive restarts.
+type blockTimeCache struct {
+	lock    sync.Mutex
+	chainID uint64
+	size    int
+	persist bool
+	entries map[uint64]*list.Element
+	order   *list.List
+}
+
+func newBlockTimeCache(chainID uint64, size int, persist bool) *blockTimeCache {
+	if size <= 0 {
+		size = defaultBlockTimeCacheSize
+	}
+	return &blockTimeCache{
+		chainID: chainID,
+		size:    size,
+		persist: persist,
+		entries: make(map[uint64]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// getBlockTimeCache returns the cache of the chain, creating it on first use.
+func getBlockTimeCache(chainID uint64) *blockTimeCache {
+	blockTimesLock.Lock()
+	defer blockTimesLock.Unlock()
+	cache, ok := blockTimes[chainID]
+	if !ok {
+		cache = newBlockTimeCache(chainID, viper.GetInt("eth.block_time_cache_size"), viper.GetBool("eth.block_time_persist"))
+		blockTimes[chainID] = cache
+	}
+	return cache
+}
+
+func (c *blockTimeCache) get(blockNumber uint64) (int64, bool) {
+	c.lock.Lock()
+	if elem, ok := c.entries[blockNumber]; ok {
+		c.order.MoveToFront(elem)
+		c.lock.Unlock()
+		return elem.Value.(blockTimeEntry).blockTime, true
+	}
+	c.lock.Unlock()
+
+	if !c.persist {
+		return 0, false
+	}
+	blockTime, err := database.GetBlockTime(c.chainID, blockNumber)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to get block time %d: %v", blockNumber, err)
+		}
+		return 0, false
+	}
+	c.remember(blockNumber, blockTime)
+	return blockTime, true
+}
+
+func (c *blockTimeCache) add(blockNumber uint64, blockTime int64) {
+	c.remember(blockNumber, blockTime)
+	if c.persist {
+		err := database.InsertBlockTime(c.chainID, blockNumber, blockTime)
+		if err != nil {
+			log.Printf("Failed to persist block time %d: %v", blockNumber, err)
+		}
+	}
+}
+
+func (c *blockTimeCache) remember(blockNumber uint64, blockTime int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[blockNumber]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[blockNumber] = c.order.PushFront(blockTimeEntry{blockNumber: blockNumber, blockTime: blockTime})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(blockTimeEntry).blockNumber)
+	}
+}
@@ -0,0 +1,58 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockTimeCacheEviction(t *testing.T) {
+	cache := newBlockTimeCache(MainnetChainID, 2, false)
+	cache.add(1, 100)
+	cache.add(2, 200)
+
+	_, ok := cache.get(1)
+	assert.True(t, ok)
+
+	cache.add(3, 300)
+
+	_, ok = cache.get(2)
+	assert.False(t, ok, "least recently used block should be evicted")
+	blockTime, ok := cache.get(1)
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), blockTime)
+	blockTime, ok = cache.get(3)
+	assert.True(t, ok)
+	assert.Equal(t, int64(300), blockTime)
+}
+
+func TestGetBlockTimeUsesCache(t *testing.T) {
+	resetBlockTimes()
+
+	calls := 0
+	client := &mockClient{
+		headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+			calls++
+			return &types.Header{Number: number, Time: 1633072800}, nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		blockTime, err := getBlockTime(testChain(client), 100)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1633072800), blockTime)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func resetBlockTimes() {
+	blockTimesLock.Lock()
+	blockTimes = make(map[uint64]*blockTimeCache)
+	blockTimesLock.Unlock()
+	blockIndexesLock.Lock()
+	blockIndexes = make(map[uint64]*blockTimeIndex)
+	blockIndexesLock.Unlock()
+}
@@ -0,0 +1,179 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const MainnetChainID uint64 = 1
+
+// Ingestion modes of a chain. subscribe follows logs over an eth_subscribe
+// subscription, poll asks for new blocks and their logs every poll interval,
+// for endpoints without websocket support.
+const (
+	IngestionSubscribe = "subscribe"
+	IngestionPoll      = "poll"
+)
+
+const defaultPollInterval = 12 * time.Second
+
+var ErrUnknownChain = errors.New("chain is not configured")
+
+// Chain is a network campaigns can run on. Each chain has its own client,
+// confirmation depth, quote tokens and ingestion mode.
+type Chain struct {
+	ID            uint64
+	Name          string
+	Client        ChainClient
+	Confirmations uint64
+	QuoteTokens   []string
+	WrappedNative string
+	Ingestion     string
+	PollInterval  time.Duration
+}
+
+type ChainConfig struct {
+	ID            uint64         `mapstructure:"id"`
+	Name          string         `mapstructure:"name"`
+	URL           string         `mapstructure:"url"`
+	Transport     string         `mapstructure:"transport"`
+	Endpoints     []ClientConfig `mapstructure:"endpoints"`
+	Confirmations uint64         `mapstructure:"confirmations"`
+	QuoteTokens   []string       `mapstructure:"quote_tokens"`
+	WrappedNative string         `mapstructure:"wrapped_native"`
+	Ingestion     string         `mapstructure:"ingestion"`
+	PollInterval  time.Duration  `mapstructure:"poll_interval"`
+}
+
+var (
+	chains     = make(map[uint64]*Chain)
+	chainsLock sync.RWMutex
+)
+
+// endpoints returns the rpc endpoints of the chain, either the endpoints list
+// or the single url.
+func (c ChainConfig) endpoints() []ClientConfig {
+	if len(c.Endpoints) > 0 {
+		return c.Endpoints
+	}
+	if c.URL == "" {
+		return nil
+	}
+	return []ClientConfig{{URL: c.URL, Transport: c.Transport}}
+}
+
+// loadChainConfigs reads the chains list. Without one, the eth section is
+// used as the only chain, Ethereum mainnet.
+func loadChainConfigs() []ChainConfig {
+	var configs []ChainConfig
+	err := viper.UnmarshalKey("chains", &configs)
+	if err != nil {
+		log.Printf("Failed to parse chains: %v", err)
+	}
+	if len(configs) > 0 {
+		return configs
+	}
+
+	endpoints := loadClientConfigs()
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return []ChainConfig{{
+		ID:            MainnetChainID,
+		Name:          "mainnet",
+		Endpoints:     endpoints,
+		Confirmations: viper.GetUint64("eth.confirmations"),
+		QuoteTokens:   viper.GetStringSlice("eth.quote_tokens"),
+		Ingestion:     viper.GetString("eth.ingestion"),
+		PollInterval:  viper.GetDuration("eth.poll_interval"),
+	}}
+}
+
+// NewChains dials every configured chain and registers it.
+func NewChains() []*Chain {
+	configs := loadChainConfigs()
+	if len(configs) == 0 {
+		log.Fatal("/config/config.toml chains, eth url, eth endpoints or infura api key is required")
+	}
+
+	var dialed []*Chain
+	for _, config := range configs {
+		if config.ID == 0 {
+			log.Fatalf("Chain %q has no id", config.Name)
+		}
+		if _, err := GetChain(config.ID); err == nil {
+			log.Fatalf("Chain %d is configured twice", config.ID)
+		}
+		name := config.Name
+		if name == "" {
+			name = fmt.Sprintf("chain-%d", config.ID)
+		}
+		endpoints := config.endpoints()
+		if len(endpoints) == 0 {
+			log.Fatalf("Chain %s has no url or endpoints", name)
+		}
+		ingestion := config.Ingestion
+		if ingestion == "" {
+			ingestion = IngestionSubscribe
+		}
+		if ingestion != IngestionSubscribe && ingestion != IngestionPoll {
+			log.Fatalf("Chain %s has unknown ingestion %q", name, ingestion)
+		}
+		pollInterval := config.PollInterval
+		if pollInterval <= 0 {
+			pollInterval = defaultPollInterval
+		}
+		client, err := newClient(endpoints)
+		if err != nil {
+			log.Fatalf("Failed to connect to chain %s: %v", name, err)
+		}
+
+		chain := &Chain{
+			ID:            config.ID,
+			Name:          name,
+			Client:        client,
+			Confirmations: config.Confirmations,
+			QuoteTokens:   config.QuoteTokens,
+			WrappedNative: config.WrappedNative,
+			Ingestion:     ingestion,
+			PollInterval:  pollInterval,
+		}
+		RegisterChain(chain)
+		dialed = append(dialed, chain)
+	}
+	return dialed
+}
+
+func RegisterChain(chain *Chain) {
+	chainsLock.Lock()
+	defer chainsLock.Unlock()
+	chains[chain.ID] = chain
+}
+
+func GetChain(id uint64) (*Chain, error) {
+	chainsLock.RLock()
+	defer chainsLock.RUnlock()
+	chain, ok := chains[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, id)
+	}
+	return chain, nil
+}
+
+// GetChains returns every registered chain ordered by chain ID.
+func GetChains() []*Chain {
+	chainsLock.RLock()
+	defer chainsLock.RUnlock()
+	list := make([]*Chain, 0, len(chains))
+	for _, chain := range chains {
+		list = append(list, chain)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
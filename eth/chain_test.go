@@ -0,0 +1,73 @@
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func testChain(client ChainClient) *Chain {
+	return &Chain{ID: MainnetChainID, Name: "mainnet", Client: client}
+}
+
+func TestLoadChainConfigs(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("chains", []map[string]interface{}{
+		{"id": 42161, "name": "arbitrum", "url": "wss://arbitrum", "transport": "ws", "confirmations": 0},
+		{"id": 10, "name": "optimism", "url": "https://optimism", "ingestion": "poll", "poll_interval": "2s"},
+		{"id": 8453, "name": "base", "endpoints": []map[string]interface{}{{"name": "primary", "url": "https://base"}}, "quote_tokens": []string{"0x1"}},
+	})
+	configs := loadChainConfigs()
+	assert.Equal(t, []ChainConfig{
+		{ID: 42161, Name: "arbitrum", URL: "wss://arbitrum", Transport: "ws"},
+		{ID: 10, Name: "optimism", URL: "https://optimism", Ingestion: IngestionPoll, PollInterval: 2 * time.Second},
+		{ID: 8453, Name: "base", Endpoints: []ClientConfig{{Name: "primary", URL: "https://base"}}, QuoteTokens: []string{"0x1"}},
+	}, configs)
+	assert.Equal(t, []ClientConfig{{URL: "wss://arbitrum", Transport: "ws"}}, configs[0].endpoints())
+	assert.Equal(t, []ClientConfig{{Name: "primary", URL: "https://base"}}, configs[2].endpoints())
+
+	viper.Reset()
+	viper.Set("eth.url", "http://single")
+	viper.Set("eth.confirmations", 12)
+	viper.Set("eth.ingestion", "poll")
+	assert.Equal(t, []ChainConfig{{
+		ID:            MainnetChainID,
+		Name:          "mainnet",
+		Endpoints:     []ClientConfig{{URL: "http://single"}},
+		Confirmations: 12,
+		Ingestion:     IngestionPoll,
+	}}, loadChainConfigs())
+
+	viper.Reset()
+	assert.Empty(t, loadChainConfigs())
+}
+
+func TestGetChain(t *testing.T) {
+	defer resetChains()
+
+	_, err := GetChain(10)
+	assert.ErrorIs(t, err, ErrUnknownChain)
+
+	optimism := &Chain{ID: 10, Name: "optimism", QuoteTokens: []string{"0x1"}}
+	RegisterChain(optimism)
+	RegisterChain(&Chain{ID: MainnetChainID, Name: "mainnet"})
+
+	chain, err := GetChain(10)
+	assert.NoError(t, err)
+	assert.Equal(t, optimism, chain)
+	assert.Len(t, GetChains(), 2)
+	assert.Equal(t, MainnetChainID, GetChains()[0].ID)
+
+	assert.Equal(t, []string{"0x1"}, quoteTokens(10))
+	assert.Equal(t, defaultQuoteTokens[MainnetChainID], quoteTokens(MainnetChainID))
+	assert.Empty(t, quoteTokens(56))
+}
+
+func resetChains() {
+	chainsLock.Lock()
+	defer chainsLock.Unlock()
+	chains = make(map[uint64]*Chain)
+}
@@ -0,0 +1,150 @@
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrNotIngested is returned when the swaps of a pool up to some time may not
+// all be stored yet.
+var ErrNotIngested = errors.New("swaps are not all ingested yet")
+
+// checkIngested fails with ErrNotIngested unless every swap of the pool made
+// before endTime is stored: the checkpoint of the pool must have reached a
+// block mined at or after endTime, with no log at or below it still in the
+// pipeline.
+func checkIngested(chain *Chain, poolAddress string, endTime int64) error {
+	lastBlock, err := database.GetIngestionCheckpoint(chain.ID, poolAddress)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s has no checkpoint on %s", ErrNotIngested, poolAddress, chain.Name)
+	} else if err != nil {
+		return fmt.Errorf("failed to get ingestion checkpoint: %w", err)
+	}
+	header, err := chain.Client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(lastBlock))
+	if err != nil {
+		return fmt.Errorf("failed to get header of block %d: %w", lastBlock, err)
+	}
+	if int64(header.Time) < endTime {
+		return fmt.Errorf("%w: checkpoint of %s on %s is at block %d mined at %d", ErrNotIngested, poolAddress, chain.Name, lastBlock, header.Time)
+	}
+	if lowest, ok := inFlightBlock(chain.ID, common.HexToAddress(poolAddress)); ok && lowest <= lastBlock {
+		return fmt.Errorf("%w: log of %s on %s at block %d is in flight", ErrNotIngested, poolAddress, chain.Name, lowest)
+	}
+	return nil
+}
+
+// backfillAddresses feeds every log emitted since each pool's checkpoint into
+// the pending buffer, up to the head seen once the subscription is live.
+// Pools without a checkpoint start from the last confirmed block, and the
+// logs of the unconfirmed blocks above it, emitted before the subscription,
+// are fetched too.
+func (l *listener) backfillAddresses(addrList []common.Address) error {
+	header, err := l.chain.Client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get latest header: %w", err)
+	}
+	head := header.Number.Uint64()
+
+	for _, addr := range addrList {
+		lastBlock, err := database.GetIngestionCheckpoint(l.chain.ID, addr.Hex())
+		if err == sql.ErrNoRows {
+			lastBlock = safeBlock(head, l.confirmations)
+			err = database.UpsertIngestionCheckpoint(l.chain.ID, addr.Hex(), lastBlock)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get ingestion checkpoint: %w", err)
+		}
+
+		if lastBlock >= head {
+			continue
+		}
+		fmt.Printf("Backfilling %s on %s from block %d to %d\n", addr.Hex(), l.chain.Name, lastBlock+1, head)
+		err = l.backfillRange(addr, lastBlock+1, head)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *listener) backfillRange(addr common.Address, fromBlock, toBlock uint64) error {
+	return filterSwapLogs(l.chain, addr, fromBlock, toBlock, func(logs []types.Log, end uint64) error {
+		for _, vLog := range logs {
+			l.receiveLog(vLog)
+		}
+		return nil
+	})
+}
+
+// filterSwapLogs fetches the Swap logs of a pool from fromBlock to toBlock one
+// chunk at a time, calling handle with the logs of each chunk and its last
+// block.
+func filterSwapLogs(chain *Chain, addr common.Address, fromBlock, toBlock uint64, handle func(logs []types.Log, end uint64) error) error {
+	config := LoadLogFetchConfig()
+	for start := fromBlock; start <= toBlock; start += config.ChunkSize {
+		end := min(start+config.ChunkSize-1, toBlock)
+		logs, err := filterLogRange(chain, addr, start, end, config)
+		if err != nil {
+			return err
+		}
+		err = handle(logs, end)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advanceCheckpoints processes logs that became final and moves each pool's
+// checkpoint up to the highest block with no log still waiting for
+// confirmations or in the pipeline.
+func (l *listener) advanceCheckpoints(addrList []common.Address) {
+	header, err := l.chain.Client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		log.Printf("Failed to get latest header: %v", err)
+		return
+	}
+	head := header.Number.Uint64()
+
+	if l.confirmations > 0 {
+		l.flushConfirmedLogs(head)
+	}
+
+	for _, addr := range addrList {
+		l.saveCheckpoint(addr, safeBlock(head, l.confirmations))
+	}
+}
+
+// saveCheckpoint moves the checkpoint of a pool to lastBlock, or below the
+// lowest block with a log still waiting for confirmations or in the pipeline.
+func (l *listener) saveCheckpoint(addr common.Address, lastBlock uint64) {
+	for _, pending := range []func(common.Address) (uint64, bool){l.pending.lowestBlock, l.pipeline.lowestBlock} {
+		if lowest, ok := pending(addr); ok && lowest <= lastBlock {
+			if lowest == 0 {
+				return
+			}
+			lastBlock = lowest - 1
+		}
+	}
+	err := database.UpsertIngestionCheckpoint(l.chain.ID, addr.Hex(), lastBlock)
+	if err != nil {
+		log.Printf("Failed to update ingestion checkpoint: %v", err)
+	}
+}
+
+func safeBlock(head, confirmations uint64) uint64 {
+	if head < confirmations {
+		return 0
+	}
+	return head - confirmations
+}
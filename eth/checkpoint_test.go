@@ -0,0 +1,119 @@
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_safeBlock(t *testing.T) {
+	tests := []struct {
+		name          string
+		head          uint64
+		confirmations uint64
+		want          uint64
+	}{
+		{
+			name:          "No confirmations",
+			head:          100,
+			confirmations: 0,
+			want:          100,
+		},
+		{
+			name:          "With confirmations",
+			head:          100,
+			confirmations: 12,
+			want:          88,
+		},
+		{
+			name:          "Head below confirmations",
+			head:          5,
+			confirmations: 12,
+			want:          0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeBlock(tt.head, tt.confirmations); got != tt.want {
+				t.Errorf("safeBlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkIngested(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	client := &mockClient{headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+		return &types.Header{Number: number, Time: number.Uint64() * 10}, nil
+	}}
+	chain := testChain(client)
+	checkpoint := uint64(20)
+	patches := gomonkey.ApplyFunc(database.GetIngestionCheckpoint, func(chainID uint64, poolAddress string) (uint64, error) {
+		if checkpoint == 0 {
+			return 0, sql.ErrNoRows
+		}
+		return checkpoint, nil
+	})
+	defer patches.Reset()
+
+	assert.NoError(t, checkIngested(chain, pool.Hex(), 200), "a checkpoint at a block mined at the end time covers the round")
+	assert.ErrorIs(t, checkIngested(chain, pool.Hex(), 201), ErrNotIngested)
+
+	pipelinesLock.Lock()
+	p := newPipeline(chain, 1, 1, 1, 1)
+	pipelines[chain.ID] = p
+	pipelinesLock.Unlock()
+	defer func() {
+		pipelinesLock.Lock()
+		delete(pipelines, chain.ID)
+		pipelinesLock.Unlock()
+	}()
+	p.inFlight.add(types.Log{Address: pool, BlockNumber: 18})
+	assert.ErrorIs(t, checkIngested(chain, pool.Hex(), 200), ErrNotIngested, "a log in flight below the checkpoint may still be stored")
+	p.inFlight.remove(types.Log{Address: pool, BlockNumber: 18})
+	assert.NoError(t, checkIngested(chain, pool.Hex(), 200))
+
+	checkpoint = 0
+	assert.ErrorIs(t, checkIngested(chain, pool.Hex(), 200), ErrNotIngested)
+}
+
+func TestBackfillAddressesFirstStart(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	var queried [][2]uint64
+	client := &mockClient{
+		headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+			return &types.Header{Number: big.NewInt(100)}, nil
+		},
+		filterLogs: func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+			queried = append(queried, [2]uint64{query.FromBlock.Uint64(), query.ToBlock.Uint64()})
+			return []types.Log{{Address: pool, BlockNumber: 95, TxHash: common.HexToHash("0x1")}}, nil
+		},
+	}
+	var saved []uint64
+	patches := gomonkey.ApplyFunc(database.GetIngestionCheckpoint, func(chainID uint64, poolAddress string) (uint64, error) {
+		return 0, sql.ErrNoRows
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(database.UpsertIngestionCheckpoint, func(chainID uint64, poolAddress string, lastBlock uint64) error {
+		saved = append(saved, lastBlock)
+		return nil
+	})
+
+	chain := testChain(client)
+	l := &listener{chain: chain, pending: newPendingLogs(), pipeline: newPipeline(chain, 1, 1, 1, 1), confirmations: 12}
+	assert.NoError(t, l.backfillAddresses([]common.Address{pool}))
+
+	assert.Equal(t, []uint64{88}, saved, "a new pool should start from the last confirmed block")
+	assert.Equal(t, [][2]uint64{{89, 100}}, queried, "logs emitted before the subscription should be fetched up to the head")
+	lowest, ok := l.pending.lowestBlock(pool)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(95), lowest)
+}
@@ -2,15 +2,14 @@ package eth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math"
 	"math/big"
-	"strings"
+	"sort"
 
 	"github.com/Largeb0525/Trading_Ace/database"
-	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/Largeb0525/Trading_Ace/decimal"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
@@ -30,6 +29,10 @@ const (
 		"type": "event"
 	}]`
 	swapEventTopicHash = "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822"
+
+	// pointsScale is the number of fractional digits points are kept with,
+	// matching the NUMERIC(38, 6) points columns.
+	pointsScale = 6
 )
 
 type SwapEvent struct {
@@ -39,120 +42,175 @@ type SwapEvent struct {
 	Amount1Out *big.Int
 }
 
+// SwapInfo is a decoded swap. Trader is the address credited for it, the
+// Sender unless AttributeSwaps picked another one. USD is the value of the
+// swap and WETH the amount of wrapped native token traded, if any.
 type SwapInfo struct {
+	ChainID     uint64
+	Trader      string
 	Sender      string
-	USDC        float64
+	Recipient   string
+	Origin      string
+	USD         decimal.Decimal
+	WETH        decimal.Decimal
+	Amount0In   *big.Int
+	Amount1In   *big.Int
+	Amount0Out  *big.Int
+	Amount1Out  *big.Int
 	Timestamp   int64
 	PoolAddress string
 	TxHash      string
+	BlockNumber uint64
+	BlockHash   string
+	TxIndex     uint
+	LogIndex    uint
 }
 
-func getBlockTime(blockNumber uint64) (int64, error) {
-	block, err := client.BlockByNumber(context.Background(), big.NewInt(int64(blockNumber)))
+func getBlockTime(chain *Chain, blockNumber uint64) (int64, error) {
+	if blockTime, ok := getBlockTimeCache(chain.ID).get(blockNumber); ok {
+		return blockTime, nil
+	}
+	header, err := chain.Client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(blockNumber))
 	if err != nil {
 		return 0, err
 	}
-	return int64(block.Time()), nil
+	rememberBlockTime(chain.ID, blockNumber, int64(header.Time))
+	return int64(header.Time), nil
 }
 
-func timeToBlockNumber(targetTime int64, afterBlock uint64, beforeBlock uint64) (uint64, error) {
-	var low, high uint64
-	if afterBlock != 0 {
-		low = afterBlock
-	} else {
-		low = 0
-	}
-	if beforeBlock != 0 {
-		high = beforeBlock
-	} else {
-		latestBlock, err := client.BlockByNumber(context.Background(), nil)
+// timeToBlockNumber returns the last block mined at or before targetTime
+// within (afterBlock, beforeBlock], where a zero beforeBlock means the head.
+// The range is narrowed with the block time index and then searched by
+// interpolating between the timestamps of the current bounds.
+func timeToBlockNumber(chain *Chain, targetTime int64, afterBlock uint64, beforeBlock uint64) (uint64, error) {
+	high := beforeBlock
+	if high == 0 {
+		latestHeader, err := chain.Client.HeaderByNumber(context.Background(), nil)
 		if err != nil {
 			return 0, err
 		}
-		high = latestBlock.NumberU64()
+		high = latestHeader.Number.Uint64()
+		rememberBlockTime(chain.ID, high, int64(latestHeader.Time))
+	}
+
+	low, lowTime, hasLowTime := afterBlock, int64(0), false
+	if blockTime, ok := getBlockTimeCache(chain.ID).get(low); ok {
+		lowTime, hasLowTime = blockTime, true
+	}
+	floor, hasFloor, ceiling, hasCeiling := getBlockTimeIndex(chain.ID).bounds(targetTime)
+	if hasFloor && floor.Number > low && floor.Number <= high {
+		low, lowTime, hasLowTime = floor.Number, floor.Time, true
+	}
+	if hasCeiling && ceiling.Number > low && ceiling.Number <= high {
+		high = ceiling.Number
 	}
 
-	for low <= high {
+	highTime, err := getBlockTime(chain, high)
+	if err != nil {
+		return 0, err
+	}
+	if highTime <= targetTime {
+		return high, nil
+	}
+
+	for high-low > 1 {
 		mid := (low + high) / 2
-		if mid == low {
-			break
+		if hasLowTime && highTime > lowTime {
+			offset := uint64(float64(targetTime-lowTime) / float64(highTime-lowTime) * float64(high-low))
+			mid = min(max(low+offset, low+1), high-1)
 		}
-		blockTime, err := getBlockTime(mid)
+		blockTime, err := getBlockTime(chain, mid)
 		if err != nil {
 			return 0, err
 		}
-		if blockTime == targetTime {
-			return mid, nil
-		} else if blockTime < targetTime {
-			low = mid + 1
+		if blockTime <= targetTime {
+			low, lowTime, hasLowTime = mid, blockTime, true
 		} else {
-			high = mid - 1
+			high, highTime = mid, blockTime
 		}
 	}
-	if low == 0 {
-		return 0, nil
-	}
-	return low - 1, nil
+	return low, nil
 }
 
-func FetchSwapEvents(poolAddress string, startTime, endTime int64) ([]types.Log, error) {
-	startBlock, err := timeToBlockNumber(startTime, 0, 0)
+func FetchSwapEvents(chain *Chain, poolAddress string, startTime, endTime int64) ([]types.Log, error) {
+	startBlock, err := timeToBlockNumber(chain, startTime, 0, 0)
 	if err != nil {
 		return nil, err
 	}
-	endBlock, err := timeToBlockNumber(endTime, startBlock, 0)
+	endBlock, err := timeToBlockNumber(chain, endTime, startBlock, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	query := ethereum.FilterQuery{
-		FromBlock: big.NewInt(int64(startBlock + 1)),
-		ToBlock:   big.NewInt(int64(endBlock)),
-		Addresses: []common.Address{common.HexToAddress(poolAddress)},
-		Topics:    [][]common.Hash{{common.HexToHash(swapEventTopicHash)}},
-	}
-
-	return client.FilterLogs(context.Background(), query)
+	return fetchSwapLogs(chain, common.HexToAddress(poolAddress), startBlock+1, endBlock, LoadLogFetchConfig())
 }
 
-func ParseSwapEvents(logs []types.Log) ([]SwapInfo, error) {
+// ParseSwapEvents decodes the Swap events among the logs and prices them.
+// Swaps without a price for either token are skipped, while a block time,
+// pool or price that cannot be looked up fails the whole batch so it can be
+// retried.
+func ParseSwapEvents(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
 	var swapInfos []SwapInfo
-	contractABI, err := abi.JSON(strings.NewReader(swapEventABI))
+	decoders, err := newSwapDecoders()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+		return nil, err
 	}
 
 	for _, vLog := range logs {
-		if len(vLog.Topics) != 3 ||
-			vLog.Topics[0].Hex() != swapEventTopicHash {
+		if len(vLog.Topics) != 3 {
+			continue
+		}
+		decoder, ok := decoders[vLog.Topics[0]]
+		if !ok {
 			continue
 		}
 
 		var swapInfo SwapInfo
+		swapInfo.ChainID = chain.ID
 		swapInfo.Sender = ParseAddress(vLog.Topics[1].Hex())
+		swapInfo.Recipient = ParseAddress(vLog.Topics[2].Hex())
+		swapInfo.Trader = swapInfo.Sender
 		swapInfo.PoolAddress = vLog.Address.Hex()
 		swapInfo.TxHash = vLog.TxHash.Hex()
-		t, err := getBlockTime(vLog.BlockNumber)
+		swapInfo.BlockNumber = vLog.BlockNumber
+		swapInfo.BlockHash = vLog.BlockHash.Hex()
+		swapInfo.TxIndex = vLog.TxIndex
+		swapInfo.LogIndex = vLog.Index
+		t, err := getBlockTime(chain, vLog.BlockNumber)
 		if err != nil {
-			log.Printf("Failed to get log timestamp: %v", err)
-			continue
+			return nil, fmt.Errorf("failed to get log timestamp: %w", err)
 		}
 		swapInfo.Timestamp = t
 
-		var swapEvent SwapEvent
-		err = contractABI.UnpackIntoInterface(&swapEvent, "Swap", vLog.Data)
+		pool, err := GetPool(chain, vLog.Address, decoder.protocol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pool metadata: %w", err)
+		}
+		if pool.Protocol != decoder.protocol {
+			log.Printf("Skipping %s Swap event of %s pool %s", decoder.protocol, pool.Protocol, pool.PoolAddress)
+			continue
+		}
+
+		swapEvent, err := decoder.decode(vLog.Data)
 		if err != nil {
 			log.Printf("Failed to unpack Swap event: %v", err)
 			continue
 		}
-		if swapEvent.Amount0In.Cmp(big.NewInt(0)) > 0 {
-			swapInfo.USDC = float64(swapEvent.Amount0In.Int64()) / 1e6
-		} else if swapEvent.Amount0Out.Cmp(big.NewInt(0)) > 0 {
-			swapInfo.USDC = float64(swapEvent.Amount0Out.Int64()) / 1e6
-		} else {
-			log.Printf("Invalid USDC value in log: %v", vLog)
+		swapInfo.Amount0In, swapInfo.Amount1In = swapEvent.Amount0In, swapEvent.Amount1In
+		swapInfo.Amount0Out, swapInfo.Amount1Out = swapEvent.Amount0Out, swapEvent.Amount1Out
+		usd, err := swapValue(chain, pool, swapEvent, vLog.BlockNumber)
+		if errors.Is(err, ErrNoPrice) || errors.Is(err, ErrUnpricedPool) {
+			log.Printf("Skipping swap of %s: %v", pool.PoolAddress, err)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to price swap: %w", err)
+		}
+		if usd.Sign() <= 0 {
+			log.Printf("Invalid USD value in log: %v", vLog)
 			continue
 		}
+		swapInfo.USD = usd
+		swapInfo.WETH = wrappedNativeAmount(chain.ID, pool, swapEvent)
 
 		swapInfos = append(swapInfos, swapInfo)
 	}
@@ -160,17 +218,56 @@ func ParseSwapEvents(logs []types.Log) ([]SwapInfo, error) {
 	return swapInfos, nil
 }
 
-func ProcessSwapInfos(task database.Task, swaps []SwapInfo, onboardingTask database.Task) {
-	senderMap := make(map[string]float64)
-	for _, swap := range swaps {
-		senderMap[swap.Sender] += swap.USDC
+// ProcessSwapInfos settles a share pool task. Swaps flagged as wash trading
+// only count for the configured discount of their volume, and their flags are
+// stored for review. MEV found among the swaps is labeled first, and traders
+// with an excluded label are left out, as are traders below the onboarding
+// threshold who have not completed the onboarding task.
+func ProcessSwapInfos(task database.Task, swaps []SwapInfo, onboarding database.Task) error {
+	config, err := sharePoolTask{}.config(task)
+	if err != nil {
+		return err
+	}
+	onboardingConfig, err := onboardingTask{}.config(onboarding)
+	if err != nil {
+		return err
 	}
 
-	validatedSenderMap, totalUSDC := calculateTotalUSDC(senderMap, onboardingTask.TaskID, onboardingTask.OnboardingThreshold)
+	mevConfig := LoadMEVConfig()
+	labelMEV(swaps, mevConfig)
+	excluded := make(map[string]bool)
+	if len(swaps) > 0 {
+		excluded, err = database.GetLabeledAddresses(swaps[0].ChainID, mevConfig.ExcludedLabels)
+		if err != nil {
+			log.Printf("Failed to get labeled addresses: %v", err)
+		}
+	}
+
+	washConfig := LoadWashConfig()
+	flags := DetectWashTrades(swaps, washConfig)
+	storeWashFlags(task, swaps, flags)
+	discounted := make(map[int]bool, len(flags))
+	for _, flag := range flags {
+		discounted[flag.Index] = true
+	}
+
+	senderMap := make(map[string]decimal.Decimal)
+	for i, swap := range swaps {
+		if swap.Trader == "" || excluded[swap.Trader] {
+			continue
+		}
+		usd := swap.USD
+		if discounted[i] {
+			usd = usd.Mul(washConfig.Discount)
+		}
+		senderMap[swap.Trader] = senderMap[swap.Trader].Add(usd)
+	}
+
+	validatedSenderMap, _ := calculateTotalUSDC(senderMap, onboarding.TaskID, onboardingConfig.Threshold)
+	rewards := distributePoints(config.PointsPool, validatedSenderMap)
 
 	for sender, usdc := range validatedSenderMap {
-		reward := task.PointsPool * (usdc / totalUSDC)
-		reward = math.Floor(reward*1e6) / 1e6
+		reward := rewards[sender]
 
 		user, err := database.GetUserByAddress(sender)
 		if err != nil {
@@ -183,7 +280,7 @@ func ProcessSwapInfos(task database.Task, swaps []SwapInfo, onboardingTask datab
 			log.Printf("Failed to update user task: %v", err)
 			continue
 		}
-		if reward > 0 {
+		if reward.Sign() > 0 {
 			err = database.CreateUserPointsHistory(user.UserID, task.TaskID, task.CampaignID, reward)
 			if err != nil {
 				log.Printf("Failed to create user points history: %v", err)
@@ -191,14 +288,80 @@ func ProcessSwapInfos(task database.Task, swaps []SwapInfo, onboardingTask datab
 			}
 		}
 	}
+	return nil
+}
+
+func storeWashFlags(task database.Task, swaps []SwapInfo, flags []WashFlag) {
+	for _, flag := range flags {
+		swap := swaps[flag.Index]
+		err := database.CreateSwapFlag(database.SwapFlag{
+			ChainID:         swap.ChainID,
+			TransactionHash: swap.TxHash,
+			LogIndex:        swap.LogIndex,
+			CampaignID:      task.CampaignID,
+			TaskID:          task.TaskID,
+			TraderAddress:   swap.Trader,
+			Reason:          flag.Reason,
+			Detail:          flag.Detail,
+			AmountUSD:       swap.USD,
+		})
+		if err != nil {
+			log.Printf("Failed to flag swap %s: %v", swap.TxHash, err)
+		}
+	}
+}
+
+// distributePoints splits pointsPool pro rata to volume in steps of
+// 10^-pointsScale. Shares are rounded down and the steps left over go to the
+// largest remainders, so the rewards always add up to the pool.
+func distributePoints(pointsPool decimal.Decimal, volumes map[string]decimal.Decimal) map[string]decimal.Decimal {
+	type share struct {
+		sender    string
+		units     *big.Int
+		remainder decimal.Decimal
+	}
+
+	totalVolume := decimal.Zero()
+	for _, volume := range volumes {
+		totalVolume = totalVolume.Add(volume)
+	}
+	rewards := make(map[string]decimal.Decimal, len(volumes))
+	if totalVolume.Sign() <= 0 {
+		return rewards
+	}
+
+	poolUnits := pointsPool.Units(pointsScale)
+	left := new(big.Int).Set(poolUnits)
+	shares := make([]share, 0, len(volumes))
+	for sender, volume := range volumes {
+		exact := decimal.NewFromBigInt(poolUnits, 0).Mul(volume).Div(totalVolume)
+		units := exact.Units(0)
+		left.Sub(left, units)
+		shares = append(shares, share{sender: sender, units: units, remainder: exact.Sub(decimal.NewFromBigInt(units, 0))})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if c := shares[i].remainder.Cmp(shares[j].remainder); c != 0 {
+			return c > 0
+		}
+		return shares[i].sender < shares[j].sender
+	})
+
+	for _, s := range shares {
+		if left.Sign() > 0 {
+			s.units.Add(s.units, big.NewInt(1))
+			left.Sub(left, big.NewInt(1))
+		}
+		rewards[s.sender] = decimal.NewFromBigInt(s.units, pointsScale)
+	}
+	return rewards
 }
 
-func calculateTotalUSDC(senderMap map[string]float64, taskID int, threshold float64) (map[string]float64, float64) {
-	totalUSDC := 0.0
+func calculateTotalUSDC(senderMap map[string]decimal.Decimal, taskID int, threshold decimal.Decimal) (map[string]decimal.Decimal, decimal.Decimal) {
+	totalUSDC := decimal.Zero()
 
 	for sender, usdc := range senderMap {
-		if usdc >= threshold {
-			totalUSDC += usdc
+		if usdc.Cmp(threshold) >= 0 {
+			totalUSDC = totalUSDC.Add(usdc)
 			continue
 		}
 		user, err := database.GetUserByAddress(sender)
@@ -214,7 +377,7 @@ func calculateTotalUSDC(senderMap map[string]float64, taskID int, threshold floa
 		}
 
 		if userTask.Completed {
-			totalUSDC += usdc
+			totalUSDC = totalUSDC.Add(usdc)
 			continue
 		}
 		delete(senderMap, sender)
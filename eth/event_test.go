@@ -8,10 +8,12 @@ import (
 	"testing"
 
 	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -53,7 +55,9 @@ func TestGetBlockTime(t *testing.T) {
 		Time: 1633072800,
 	})
 
-	patches := gomonkey.ApplyMethodFunc(client, "BlockByNumber", func(ctx context.Context, number *big.Int) (*types.Block, error) {
+	resetBlockTimes()
+	client := &mockClient{}
+	client.blockByNumber = func(ctx context.Context, number *big.Int) (*types.Block, error) {
 		if number == nil {
 			return mockBlock, nil
 		}
@@ -65,8 +69,7 @@ func TestGetBlockTime(t *testing.T) {
 		default:
 			return nil, errors.New("block not found")
 		}
-	})
-	defer patches.Reset()
+	}
 
 	tests := []struct {
 		name        string
@@ -96,7 +99,7 @@ func TestGetBlockTime(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotTime, err := getBlockTime(tt.blockNumber)
+			gotTime, err := getBlockTime(testChain(client), tt.blockNumber)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getBlockTime() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -112,7 +115,9 @@ func Test_timeToBlockNumber(t *testing.T) {
 	mockBlock3 := types.NewBlockWithHeader(&types.Header{Time: 1633080000, Number: big.NewInt(3)})
 	mockBlock4 := types.NewBlockWithHeader(&types.Header{Time: 1633083600, Number: big.NewInt(4)})
 
-	patches := gomonkey.ApplyMethodFunc(client, "BlockByNumber", func(ctx context.Context, number *big.Int) (*types.Block, error) {
+	resetBlockTimes()
+	client := &mockClient{}
+	client.blockByNumber = func(ctx context.Context, number *big.Int) (*types.Block, error) {
 		if number == nil {
 			return mockBlock4, nil
 		}
@@ -128,8 +133,7 @@ func Test_timeToBlockNumber(t *testing.T) {
 		default:
 			return nil, errors.New("block not found")
 		}
-	})
-	defer patches.Reset()
+	}
 
 	tests := []struct {
 		name          string
@@ -160,7 +164,7 @@ func Test_timeToBlockNumber(t *testing.T) {
 			targetTime:    1633087200,
 			afterBlock:    0,
 			beforeBlock:   0,
-			expectedBlock: 2,
+			expectedBlock: 4,
 			expectError:   false,
 		},
 		{
@@ -175,7 +179,7 @@ func Test_timeToBlockNumber(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			block, err := timeToBlockNumber(tt.targetTime, tt.afterBlock, tt.beforeBlock)
+			block, err := timeToBlockNumber(testChain(client), tt.targetTime, tt.afterBlock, tt.beforeBlock)
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
@@ -187,13 +191,18 @@ func Test_timeToBlockNumber(t *testing.T) {
 }
 
 func TestFetchSwapEvents(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("get_logs.max_retries", 0)
+
 	mockBlock1 := types.NewBlockWithHeader(&types.Header{Time: 1633072800, Number: big.NewInt(1)})
 	mockBlock2 := types.NewBlockWithHeader(&types.Header{Time: 1633076400, Number: big.NewInt(2)})
 	mockBlock3 := types.NewBlockWithHeader(&types.Header{Time: 1633080000, Number: big.NewInt(3)})
 	mockBlock4 := types.NewBlockWithHeader(&types.Header{Time: 1633083600, Number: big.NewInt(4)})
 	mockBlock6 := types.NewBlockWithHeader(&types.Header{Time: 1633086000, Number: big.NewInt(6)})
 
-	patches := gomonkey.ApplyMethodFunc(client, "BlockByNumber", func(ctx context.Context, number *big.Int) (*types.Block, error) {
+	resetBlockTimes()
+	client := &mockClient{}
+	client.blockByNumber = func(ctx context.Context, number *big.Int) (*types.Block, error) {
 		if number == nil {
 			return mockBlock6, nil
 		}
@@ -211,8 +220,7 @@ func TestFetchSwapEvents(t *testing.T) {
 		default:
 			return nil, errors.New("block not found")
 		}
-	})
-	defer patches.Reset()
+	}
 
 	mockLogs := []types.Log{
 		{
@@ -221,13 +229,12 @@ func TestFetchSwapEvents(t *testing.T) {
 			Data:    []byte("mockData"),
 		},
 	}
-	patches2 := gomonkey.ApplyMethodFunc(client, "FilterLogs", func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	client.filterLogs = func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
 		if query.Addresses[0] == common.HexToAddress("0x123") {
 			return mockLogs, nil
 		}
 		return nil, errors.New("no logs found")
-	})
-	defer patches2.Reset()
+	}
 
 	tests := []struct {
 		name        string
@@ -265,7 +272,7 @@ func TestFetchSwapEvents(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			logs, err := FetchSwapEvents(tt.poolAddress, tt.startTime, tt.endTime)
+			logs, err := FetchSwapEvents(testChain(client), tt.poolAddress, tt.startTime, tt.endTime)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -278,22 +285,91 @@ func TestFetchSwapEvents(t *testing.T) {
 }
 
 func TestParseSwapEvents(t *testing.T) {
+	usdcPool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	daiPool := common.HexToAddress("0xA478c2975Ab1Ea89e8196811F51A7B7Ade33eB11")
+	v3Pool := common.HexToAddress("0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640")
+	setPoolCache(database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    usdcPool.Hex(),
+		Protocol:       ProtocolUniswapV2,
+		Token0:         "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		Token1:         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+		Token0Decimals: 6,
+		Token1Decimals: 18,
+	}, database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    daiPool.Hex(),
+		Protocol:       ProtocolUniswapV2,
+		Token0:         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+		Token1:         "0x6B175474E89094C44Da98b954EedeAC495271d0F",
+		Token0Decimals: 18,
+		Token1Decimals: 18,
+	}, database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    v3Pool.Hex(),
+		Protocol:       ProtocolUniswapV3,
+		Token0:         "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		Token1:         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+		Token0Decimals: 6,
+		Token1Decimals: 18,
+	})
+	v3Data := common.FromHex("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffc465360000000000000000000000000000000000000000000000000006f05b59d3b20000000000000000000000000000fffd8963efd1fc6a506488495d951d5263988d250000000000000000000000000000000000000000000000000de0b6b3a7640000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffcf2c0")
+
 	mockLogs := []types.Log{
 		{
-			Address: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"),
+			Address: usdcPool,
 			Topics: []common.Hash{
 				common.HexToHash(swapEventTopicHash),
 				common.HexToHash("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
 				common.HexToHash("0x19D1B048c8CDb4Cc280676627CE8c05756C5519e"),
 			},
-			Data:        []byte(`0x0000000000000000000000000000000000000000000000000000000017d784000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000001c8d2577d58988e`),
+			Data:        common.FromHex("0x0000000000000000000000000000000000000000000000000000000017d784000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000001c8d2577d58988e"),
 			BlockNumber: 12345,
 			TxHash:      common.HexToHash("0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70"),
 		},
+		{
+			Address: daiPool,
+			Topics: []common.Hash{
+				common.HexToHash(swapEventTopicHash),
+				common.HexToHash("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+				common.HexToHash("0x19D1B048c8CDb4Cc280676627CE8c05756C5519e"),
+			},
+			Data:        common.FromHex("0x00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000878678326eac9000000000000000000000000000000000000000000000000000000de0b6b3a76400000000000000000000000000000000000000000000000000000000000000000000"),
+			BlockNumber: 12345,
+			TxHash:      common.HexToHash("0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70"),
+			Index:       1,
+		},
+		{
+			Address: v3Pool,
+			Topics: []common.Hash{
+				common.HexToHash(uniswapV3SwapEventTopicHash),
+				common.HexToHash("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+				common.HexToHash("0x19D1B048c8CDb4Cc280676627CE8c05756C5519e"),
+			},
+			Data:        v3Data,
+			BlockNumber: 12345,
+			TxHash:      common.HexToHash("0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70"),
+			Index:       2,
+		},
+		{
+			// V3 event emitted by a pool registered as V2 is skipped
+			Address: usdcPool,
+			Topics: []common.Hash{
+				common.HexToHash(uniswapV3SwapEventTopicHash),
+				common.HexToHash("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+				common.HexToHash("0x19D1B048c8CDb4Cc280676627CE8c05756C5519e"),
+			},
+			Data:        v3Data,
+			BlockNumber: 12345,
+			TxHash:      common.HexToHash("0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70"),
+			Index:       3,
+		},
 	}
 
 	mockBlock := types.NewBlockWithHeader(&types.Header{Time: 1633083600, Number: big.NewInt(12345)})
-	patches := gomonkey.ApplyMethodFunc(client, "BlockByNumber", func(ctx context.Context, number *big.Int) (*types.Block, error) {
+	resetBlockTimes()
+	client := &mockClient{}
+	client.blockByNumber = func(ctx context.Context, number *big.Int) (*types.Block, error) {
 		if number == nil {
 			return mockBlock, nil
 		}
@@ -303,21 +379,95 @@ func TestParseSwapEvents(t *testing.T) {
 		default:
 			return nil, errors.New("block not found")
 		}
-	})
-	defer patches.Reset()
+	}
 
-	swapInfos, err := ParseSwapEvents(mockLogs)
+	swapInfos, err := ParseSwapEvents(testChain(client), mockLogs)
 	assert.NoError(t, err)
-	assert.Len(t, swapInfos, 1)
+	assert.Len(t, swapInfos, 3)
 
-	expectedSwapInfo := SwapInfo{
-		Sender:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
-		USDC:        3472328296227.68,
-		Timestamp:   1633083600,
-		PoolAddress: "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc",
-		TxHash:      "0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70",
+	expectedSwapInfos := []SwapInfo{
+		{
+			ChainID:     MainnetChainID,
+			Trader:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			Sender:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			Recipient:   "0x19D1B048c8CDb4Cc280676627CE8c05756C5519e",
+			USD:         decimal.NewFromInt(400),
+			WETH:        decimal.RequireFromString("0.12858386258700507"),
+			Timestamp:   1633083600,
+			PoolAddress: "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc",
+			TxHash:      "0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70",
+			BlockNumber: 12345,
+			BlockHash:   "0x0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			ChainID:     MainnetChainID,
+			Trader:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			Sender:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			Recipient:   "0x19D1B048c8CDb4Cc280676627CE8c05756C5519e",
+			USD:         decimal.NewFromInt(2500),
+			WETH:        decimal.NewFromInt(1),
+			Timestamp:   1633083600,
+			PoolAddress: "0xA478c2975Ab1Ea89e8196811F51A7B7Ade33eB11",
+			TxHash:      "0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70",
+			BlockNumber: 12345,
+			BlockHash:   "0x0000000000000000000000000000000000000000000000000000000000000000",
+			LogIndex:    1,
+		},
+		{
+			ChainID:     MainnetChainID,
+			Trader:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			Sender:      "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			Recipient:   "0x19D1B048c8CDb4Cc280676627CE8c05756C5519e",
+			USD:         decimal.NewFromInt(1000),
+			WETH:        decimal.RequireFromString("0.5"),
+			Timestamp:   1633083600,
+			PoolAddress: "0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640",
+			TxHash:      "0xfce9072349cf28089f7816c526fcf9d50ac277fc7ba9a43377228ea4a0604f70",
+			BlockNumber: 12345,
+			BlockHash:   "0x0000000000000000000000000000000000000000000000000000000000000000",
+			LogIndex:    2,
+		},
+	}
+	expectedAmounts := [][4]string{
+		{"400000000", "0", "0", "128583862587005070"},
+		{"0", "2500000000000000000000", "1000000000000000000", "0"},
+		{"0", "500000000000000000", "1000000000", "0"},
 	}
-	assert.Equal(t, expectedSwapInfo, swapInfos[0])
+	for i := range swapInfos {
+		swap := &swapInfos[i]
+		assert.Equal(t, expectedAmounts[i], [4]string{swap.Amount0In.String(), swap.Amount1In.String(), swap.Amount0Out.String(), swap.Amount1Out.String()})
+		swap.Amount0In, swap.Amount1In, swap.Amount0Out, swap.Amount1Out = nil, nil, nil, nil
+	}
+	assert.Equal(t, expectedSwapInfos, swapInfos)
+
+	// A block whose time cannot be fetched fails the batch.
+	unknownBlock := mockLogs[0]
+	unknownBlock.BlockNumber = 99999
+	_, err = ParseSwapEvents(testChain(client), []types.Log{unknownBlock})
+	assert.Error(t, err)
+
+	// Swaps of a pool without a quote token are skipped when neither token has
+	// a price, while an oracle failure fails the batch.
+	unquotedPool := common.HexToAddress("0x0000000000000000000000000000000000000abc")
+	setPoolCache(database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    unquotedPool.Hex(),
+		Protocol:       ProtocolUniswapV2,
+		Token0:         "0x0000000000000000000000000000000000000001",
+		Token1:         "0x0000000000000000000000000000000000000002",
+		Token0Decimals: 18,
+		Token1Decimals: 18,
+	})
+	defer resetPriceOracle()
+	unquoted := mockLogs[0]
+	unquoted.Address = unquotedPool
+	SetPriceOracle(&countingOracle{err: ErrNoPrice})
+	swapInfos, err = ParseSwapEvents(testChain(client), []types.Log{unquoted})
+	assert.NoError(t, err)
+	assert.Empty(t, swapInfos)
+	SetPriceOracle(&countingOracle{err: errors.New("connection refused")})
+	_, err = ParseSwapEvents(testChain(client), []types.Log{unquoted})
+	assert.Error(t, err)
 }
 
 func Test_calculateTotalUSDC(t *testing.T) {
@@ -343,62 +493,62 @@ func Test_calculateTotalUSDC(t *testing.T) {
 	defer patches2.Reset()
 
 	type args struct {
-		senderMap map[string]float64
+		senderMap map[string]decimal.Decimal
 		taskID    int
-		threshold float64
+		threshold decimal.Decimal
 	}
 	tests := []struct {
 		name  string
 		args  args
-		want  map[string]float64
-		want1 float64
+		want  map[string]decimal.Decimal
+		want1 decimal.Decimal
 	}{
 		{
 			name: "test1",
 			args: args{
-				senderMap: map[string]float64{
-					"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": 100,
-					"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": 200,
+				senderMap: map[string]decimal.Decimal{
+					"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": decimal.NewFromInt(100),
+					"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": decimal.NewFromInt(200),
 				},
 				taskID:    1,
-				threshold: 100,
+				threshold: decimal.NewFromInt(100),
 			},
-			want: map[string]float64{
-				"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": 100,
-				"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": 200,
+			want: map[string]decimal.Decimal{
+				"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": decimal.NewFromInt(100),
+				"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": decimal.NewFromInt(200),
 			},
-			want1: 300,
+			want1: decimal.NewFromInt(300),
 		},
 		{
 			name: "test2",
 			args: args{
-				senderMap: map[string]float64{
-					"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": 100,
-					"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": 200,
+				senderMap: map[string]decimal.Decimal{
+					"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": decimal.NewFromInt(100),
+					"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": decimal.NewFromInt(200),
 				},
 				taskID:    2,
-				threshold: 150,
+				threshold: decimal.NewFromInt(150),
 			},
-			want: map[string]float64{
-				"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": 200,
+			want: map[string]decimal.Decimal{
+				"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": decimal.NewFromInt(200),
 			},
-			want1: 200,
+			want1: decimal.NewFromInt(200),
 		},
 		{
 			name: "test3",
 			args: args{
-				senderMap: map[string]float64{
-					"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": 200,
-					"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": 100,
+				senderMap: map[string]decimal.Decimal{
+					"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": decimal.NewFromInt(200),
+					"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": decimal.NewFromInt(100),
 				},
 				taskID:    2,
-				threshold: 150,
+				threshold: decimal.NewFromInt(150),
 			},
-			want: map[string]float64{
-				"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": 200,
-				"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": 100,
+			want: map[string]decimal.Decimal{
+				"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": decimal.NewFromInt(200),
+				"0x19D1B048c8CDb4Cc280676627CE8c05756C5519e": decimal.NewFromInt(100),
 			},
-			want1: 300,
+			want1: decimal.NewFromInt(300),
 		},
 	}
 	for _, tt := range tests {
@@ -407,9 +557,77 @@ func Test_calculateTotalUSDC(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("calculateTotalUSDC() got = %v, want %v", got, tt.want)
 			}
-			if got1 != tt.want1 {
+			if !got1.Equal(tt.want1) {
 				t.Errorf("calculateTotalUSDC() got1 = %v, want %v", got1, tt.want1)
 			}
 		})
 	}
 }
+
+func Test_distributePoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		pointsPool decimal.Decimal
+		volumes    map[string]decimal.Decimal
+		want       map[string]decimal.Decimal
+	}{
+		{
+			name:       "Even split",
+			pointsPool: decimal.NewFromInt(10000),
+			volumes: map[string]decimal.Decimal{
+				"0xa": decimal.NewFromInt(100),
+				"0xb": decimal.NewFromInt(300),
+			},
+			want: map[string]decimal.Decimal{
+				"0xa": decimal.NewFromInt(2500),
+				"0xb": decimal.NewFromInt(7500),
+			},
+		},
+		{
+			name:       "Remainder goes to largest fraction",
+			pointsPool: decimal.NewFromInt(100),
+			volumes: map[string]decimal.Decimal{
+				"0xa": decimal.NewFromInt(1),
+				"0xb": decimal.NewFromInt(1),
+				"0xc": decimal.NewFromInt(1),
+			},
+			want: map[string]decimal.Decimal{
+				"0xa": decimal.RequireFromString("33.333334"),
+				"0xb": decimal.RequireFromString("33.333333"),
+				"0xc": decimal.RequireFromString("33.333333"),
+			},
+		},
+		{
+			name:       "Large volumes keep precision",
+			pointsPool: decimal.RequireFromString("1000.5"),
+			volumes: map[string]decimal.Decimal{
+				"0xa": decimal.RequireFromString("123456789012345.123456"),
+				"0xb": decimal.RequireFromString("0.000001"),
+			},
+			want: map[string]decimal.Decimal{
+				"0xa": decimal.RequireFromString("1000.5"),
+				"0xb": decimal.Zero(),
+			},
+		},
+		{
+			name:       "No volume",
+			pointsPool: decimal.NewFromInt(100),
+			volumes:    map[string]decimal.Decimal{},
+			want:       map[string]decimal.Decimal{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distributePoints(tt.pointsPool, tt.volumes)
+			assert.Equal(t, tt.want, got)
+
+			total := decimal.Zero()
+			for _, reward := range got {
+				total = total.Add(reward)
+			}
+			if len(tt.volumes) > 0 {
+				assert.Equal(t, tt.pointsPool, total)
+			}
+		})
+	}
+}
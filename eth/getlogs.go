@@ -0,0 +1,202 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultLogChunkSize   = 2000
+	defaultLogConcurrency = 4
+	defaultLogMaxRetries  = 5
+	defaultLogRetryDelay  = 500 * time.Millisecond
+	defaultLogTimeout     = 30 * time.Second
+)
+
+// Provider errors meaning a query matched too many logs or blocks. Ranges
+// failing with one of them are split in half.
+var oversizedLogErrors = []string{
+	"query returned more than",   // Infura
+	"query exceeds max results",  // Alchemy, Erigon
+	"response size exceeded",     // Alchemy
+	"eth_getlogs is limited to",  // QuickNode
+	"block range is too",         // Ankr
+	"block range too large",      // Cloudflare
+	"exceed maximum block range", // Geth, BSC
+	"exceeds max block range",
+}
+
+// Provider errors meaning the endpoint is throttling requests. Queries
+// failing with one of them are retried with backoff, never split, as smaller
+// ranges only mean more requests.
+var rateLimitErrors = []string{
+	"429",
+	"too many requests",
+	"rate limit",
+	"request count limit",
+	"compute units per second",
+}
+
+// LogFetchConfig tunes eth_getLogs queries. Ranges are fetched in chunks of
+// ChunkSize blocks, Concurrency at a time, each query giving up after
+// Timeout. Chunks that time out or match too many logs are split in half,
+// other failures, including rate limiting, are retried up to MaxRetries
+// times, waiting RetryDelay doubled on every attempt.
+type LogFetchConfig struct {
+	ChunkSize   uint64
+	Concurrency int
+	MaxRetries  int
+	RetryDelay  time.Duration
+	Timeout     time.Duration
+}
+
+func LoadLogFetchConfig() LogFetchConfig {
+	config := LogFetchConfig{
+		ChunkSize:   defaultLogChunkSize,
+		Concurrency: defaultLogConcurrency,
+		MaxRetries:  defaultLogMaxRetries,
+		RetryDelay:  defaultLogRetryDelay,
+		Timeout:     defaultLogTimeout,
+	}
+	if size := viper.GetUint64("get_logs.chunk_size"); size > 0 {
+		config.ChunkSize = size
+	}
+	if concurrency := viper.GetInt("get_logs.concurrency"); concurrency > 0 {
+		config.Concurrency = concurrency
+	}
+	if viper.IsSet("get_logs.max_retries") {
+		config.MaxRetries = viper.GetInt("get_logs.max_retries")
+	}
+	if delay := viper.GetDuration("get_logs.retry_delay"); delay > 0 {
+		config.RetryDelay = delay
+	}
+	if timeout := viper.GetDuration("get_logs.timeout"); timeout > 0 {
+		config.Timeout = timeout
+	}
+	return config
+}
+
+// fetchSwapLogs returns the Swap logs of a pool from fromBlock to toBlock in
+// block order, fetching up to Concurrency chunks at a time.
+func fetchSwapLogs(chain *Chain, addr common.Address, fromBlock, toBlock uint64, config LogFetchConfig) ([]types.Log, error) {
+	if fromBlock > toBlock {
+		return nil, nil
+	}
+	var ranges [][2]uint64
+	for start := fromBlock; start <= toBlock; start += config.ChunkSize {
+		ranges = append(ranges, [2]uint64{start, min(start+config.ChunkSize-1, toBlock)})
+	}
+
+	results := make([][]types.Log, len(ranges))
+	errs := make([]error, len(ranges))
+	sem := make(chan struct{}, max(config.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, from, to uint64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = filterLogRange(chain, addr, from, to, config)
+		}(i, r[0], r[1])
+	}
+	wg.Wait()
+
+	var logs []types.Log
+	for i := range ranges {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		logs = append(logs, results[i]...)
+	}
+	return logs, nil
+}
+
+// filterLogRange fetches the Swap logs of a pool from fromBlock to toBlock.
+// A range that times out or matches too many logs is split in half, and
+// each half fetched the same way. Other errors, and those of single blocks,
+// are retried with exponential backoff.
+func filterLogRange(chain *Chain, addr common.Address, fromBlock, toBlock uint64, config LogFetchConfig) ([]types.Log, error) {
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: []common.Address{addr},
+		Topics:    [][]common.Hash{swapTopics()},
+	}
+	delay := config.RetryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		logs, err := chain.Client.FilterLogs(ctx, query)
+		cancel()
+		if err == nil {
+			return logs, nil
+		}
+
+		if fromBlock < toBlock && splittable(err) {
+			mid := fromBlock + (toBlock-fromBlock)/2
+			log.Printf("Splitting logs query from %d to %d on %s: %v", fromBlock, toBlock, chain.Name, err)
+			lower, err := filterLogRange(chain, addr, fromBlock, mid, config)
+			if err != nil {
+				return nil, err
+			}
+			upper, err := filterLogRange(chain, addr, mid+1, toBlock, config)
+			if err != nil {
+				return nil, err
+			}
+			return append(lower, upper...), nil
+		}
+		if attempt >= config.MaxRetries {
+			return nil, fmt.Errorf("failed to filter logs from %d to %d: %w", fromBlock, toBlock, err)
+		}
+		log.Printf("Retrying logs query from %d to %d on %s in %s: %v", fromBlock, toBlock, chain.Name, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// splittable reports whether a logs query failed because its range is too
+// large for the provider. Rate limited queries are not.
+func splittable(err error) bool {
+	if rateLimited(err) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, "timeout") || strings.Contains(message, "timed out") {
+		return true
+	}
+	for _, oversized := range oversizedLogErrors {
+		if strings.Contains(message, oversized) {
+			return true
+		}
+	}
+	return false
+}
+
+func rateLimited(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, limited := range rateLimitErrors {
+		if strings.Contains(message, limited) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchSwapLogs(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	config := LogFetchConfig{ChunkSize: 100, Concurrency: 2, MaxRetries: 2, RetryDelay: time.Millisecond, Timeout: time.Second}
+
+	tests := []struct {
+		name        string
+		filterLogs  func(from, to uint64, attempt int) error
+		expectError bool
+		minQueries  int
+	}{
+		{
+			name:       "Every chunk succeeds",
+			filterLogs: func(from, to uint64, attempt int) error { return nil },
+			minQueries: 4,
+		},
+		{
+			name: "Oversized ranges are split",
+			filterLogs: func(from, to uint64, attempt int) error {
+				if to-from >= 30 {
+					return errors.New("query returned more than 10000 results")
+				}
+				return nil
+			},
+			minQueries: 16,
+		},
+		{
+			name: "Timeouts are split",
+			filterLogs: func(from, to uint64, attempt int) error {
+				if from <= 150 && 150 <= to && to > from {
+					return context.DeadlineExceeded
+				}
+				return nil
+			},
+			minQueries: 5,
+		},
+		{
+			name: "Transient errors are retried",
+			filterLogs: func(from, to uint64, attempt int) error {
+				if attempt < 2 {
+					return errors.New("connection reset by peer")
+				}
+				return nil
+			},
+			minQueries: 12,
+		},
+		{
+			name: "Rate limited queries are retried without splitting",
+			filterLogs: func(from, to uint64, attempt int) error {
+				if to-from < 99 {
+					return errors.New("a rate limited range should not be split")
+				}
+				if attempt < 2 {
+					return errors.New("429 Too Many Requests")
+				}
+				return nil
+			},
+			minQueries: 12,
+		},
+		{
+			name: "Retries run out",
+			filterLogs: func(from, to uint64, attempt int) error {
+				if from == 200 {
+					return errors.New("connection reset by peer")
+				}
+				return nil
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lock sync.Mutex
+			attempts := make(map[[2]uint64]int)
+			queries := 0
+			client := &mockClient{
+				filterLogs: func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+					from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+					lock.Lock()
+					key := [2]uint64{from, to}
+					attempt := attempts[key]
+					attempts[key]++
+					queries++
+					lock.Unlock()
+
+					if err := tt.filterLogs(from, to, attempt); err != nil {
+						return nil, err
+					}
+					var logs []types.Log
+					for block := from; block <= to; block++ {
+						if block%10 == 0 {
+							logs = append(logs, types.Log{Address: pool, BlockNumber: block})
+						}
+					}
+					return logs, nil
+				},
+			}
+
+			logs, err := fetchSwapLogs(testChain(client), pool, 100, 499, config)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, queries, tt.minQueries)
+			var blocks []uint64
+			for _, vLog := range logs {
+				blocks = append(blocks, vLog.BlockNumber)
+			}
+			var expected []uint64
+			for block := uint64(100); block < 500; block += 10 {
+				expected = append(expected, block)
+			}
+			assert.Equal(t, expected, blocks, "logs should cover every block once, in order")
+		})
+	}
+}
+
+func Test_splittable(t *testing.T) {
+	assert.True(t, splittable(errors.New("query returned more than 10000 results")))
+	assert.True(t, splittable(errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range")))
+	assert.True(t, splittable(fmt.Errorf("request failed: %w", context.DeadlineExceeded)))
+	assert.True(t, splittable(errors.New("i/o timeout")))
+	assert.True(t, splittable(errors.New("eth_getLogs is limited to a 10,000 range")))
+	assert.True(t, splittable(errors.New("block range is too wide")))
+	assert.False(t, splittable(errors.New("connection reset by peer")))
+	assert.False(t, splittable(errors.New("invalid params")))
+	assert.False(t, splittable(errors.New("429 Too Many Requests")))
+	assert.False(t, splittable(errors.New("daily request count limit exceeded")))
+	assert.False(t, splittable(errors.New("Your app has exceeded its compute units per second capacity")))
+	assert.False(t, splittable(errors.New("rate limit exceeded: request timed out in queue")))
+}
+
+func TestLoadLogFetchConfig(t *testing.T) {
+	defer viper.Reset()
+	assert.Equal(t, LogFetchConfig{ChunkSize: 2000, Concurrency: 4, MaxRetries: 5, RetryDelay: 500 * time.Millisecond, Timeout: 30 * time.Second}, LoadLogFetchConfig())
+
+	viper.Set("get_logs.chunk_size", 500)
+	viper.Set("get_logs.concurrency", 8)
+	viper.Set("get_logs.max_retries", 0)
+	viper.Set("get_logs.retry_delay", "1s")
+	viper.Set("get_logs.timeout", "10s")
+	assert.Equal(t, LogFetchConfig{ChunkSize: 500, Concurrency: 8, MaxRetries: 0, RetryDelay: time.Second, Timeout: 10 * time.Second}, LoadLogFetchConfig())
+}
@@ -6,136 +6,262 @@ import (
 	"log"
 
 	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-func handleLogs(vLog types.Log) {
-	swapInfos, err := ParseSwapEvents([]types.Log{vLog})
+// insertSwapEvent stores the swap and returns the campaigns of its pool, whose
+// tasks are left for the caller to update and mark accrued. accrue is false
+// if the swap was already stored and accrued, in which case its tasks must
+// not be updated again. A swap stored by a run that stopped before accruing
+// it is accrued again.
+func insertSwapEvent(chain *Chain, swapInfo *SwapInfo) (campaigns []database.Campaign, accrue bool, err error) {
+	campaigns, err = database.GetCampaignsByAddress(chain.ID, swapInfo.PoolAddress)
 	if err != nil {
-		log.Printf("Failed to parse Swap event: %v", err)
-		return
+		return nil, false, fmt.Errorf("failed to get campaign: %w", err)
 	}
-
-	for _, swapInfo := range swapInfos {
-		insertSwapEventAndUpdateTask(swapInfo)
+	if needsOrigin(campaigns) {
+		swaps := []SwapInfo{*swapInfo}
+		err = AttributeSwaps(chain.Client, swaps, AttributionOrigin)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to resolve swap origin: %w", err)
+		}
+		swapInfo.Origin = swaps[0].Origin
 	}
-}
 
-func insertSwapEventAndUpdateTask(swapInfo SwapInfo) {
-	userID, err := database.GetOrCreateUserID(swapInfo.Sender)
+	userID, err := database.GetOrCreateUserID(swapUser(campaigns, swapInfo.Sender, swapInfo.Recipient, swapInfo.Origin))
 	if err != nil {
-		log.Printf("Failed to get or create user ID: %v", err)
-		return
+		return nil, false, fmt.Errorf("failed to get or create user ID: %w", err)
 	}
 
-	err = database.InsertSwapEvent(userID, swapInfo.PoolAddress, swapInfo.USDC, swapInfo.Timestamp, swapInfo.TxHash)
+	inserted, err := database.InsertSwapEvent(database.UserSwap{
+		ChainID:          swapInfo.ChainID,
+		UserID:           userID,
+		TransactionHash:  swapInfo.TxHash,
+		PoolAddress:      swapInfo.PoolAddress,
+		SenderAddress:    swapInfo.Sender,
+		RecipientAddress: swapInfo.Recipient,
+		OriginAddress:    swapInfo.Origin,
+		AmountUSDC:       swapInfo.USD,
+		AmountWETH:       swapInfo.WETH,
+		Amount0In:        decimal.NewFromBigInt(swapInfo.Amount0In, 0),
+		Amount1In:        decimal.NewFromBigInt(swapInfo.Amount1In, 0),
+		Amount0Out:       decimal.NewFromBigInt(swapInfo.Amount0Out, 0),
+		Amount1Out:       decimal.NewFromBigInt(swapInfo.Amount1Out, 0),
+		SwapTime:         swapInfo.Timestamp,
+		BlockNumber:      swapInfo.BlockNumber,
+		BlockHash:        swapInfo.BlockHash,
+		LogIndex:         swapInfo.LogIndex,
+		TxIndex:          swapInfo.TxIndex,
+	})
 	if err != nil {
-		log.Printf("Failed to insert swap event: %v", err)
-		return
+		return nil, false, err
+	}
+	if !inserted {
+		accrued, err := database.IsSwapAccrued(chain.ID, swapInfo.TxHash, swapInfo.LogIndex)
+		if err != nil {
+			return nil, false, err
+		}
+		if !accrued {
+			fmt.Printf("Resumed accrual of stored swap event: Chain: %s, Tx: %s, Log: %d\n", chain.Name, swapInfo.TxHash, swapInfo.LogIndex)
+			return campaigns, true, nil
+		}
+		fmt.Printf("Skipped stored swap event: Chain: %s, Tx: %s, Log: %d\n", chain.Name, swapInfo.TxHash, swapInfo.LogIndex)
+		return campaigns, false, nil
 	}
 
-	fmt.Printf("Stored swap event: User: %s, Pool: %s, USDC: %f, Timestamp: %d\n", swapInfo.Sender, swapInfo.PoolAddress, swapInfo.USDC, swapInfo.Timestamp)
-	go updateTask(userID, swapInfo.PoolAddress, swapInfo.USDC, swapInfo.Timestamp)
+	fmt.Printf("Stored swap event: Chain: %s, User: %s, Pool: %s, USD: %s, Timestamp: %d\n", chain.Name, swapUser(campaigns, swapInfo.Sender, swapInfo.Recipient, swapInfo.Origin), swapInfo.PoolAddress, swapInfo.USD, swapInfo.Timestamp)
+	return campaigns, true, nil
 }
 
-func updateTask(userID int, poolAddress string, usdc float64, t int64) {
-	campaigns, err := database.GetCampaignsByAddress(poolAddress)
-	if err != nil {
-		log.Printf("Failed to get campaign: %v", err)
-		return
-	}
+// accrual is the volume of one swap credited to, or taken back from, the
+// trader of one campaign. applied holds the tasks already updated, so an
+// accrual that failed on some tasks only updates the others when applied
+// again.
+type accrual struct {
+	chainID    uint64
+	campaignID int
+	trader     string
+	amount     decimal.Decimal
+	time       int64
+	rollback   bool
+	applied    map[int]bool
+}
 
+// swapAccruals returns the accruals of a stored swap, one per campaign whose
+// window covers it, credited to the trader picked by the campaign's
+// attribution mode.
+func swapAccruals(campaigns []database.Campaign, sender, recipient, origin string, usd decimal.Decimal, t int64) []accrual {
+	var accruals []accrual
 	for _, campaign := range campaigns {
 		if t < campaign.StartTime || t > campaign.EndTime {
-			return
+			continue
+		}
+		trader := traderAddress(campaign.Attribution, sender, recipient, origin)
+		if trader == "" {
+			log.Printf("No %s address to credit for campaign %d", campaign.Attribution, campaign.CampaignID)
+			continue
 		}
-		processOnboardingTasks(campaign.CampaignID, userID, usdc)
-		processSharePoolTask(campaign.CampaignID, userID, usdc, t)
+		accruals = append(accruals, accrual{chainID: campaign.ChainID, campaignID: campaign.CampaignID, trader: trader, amount: usd, time: t, applied: make(map[int]bool)})
 	}
+	return accruals
 }
 
-func insertUserTaskAndGetOnboardingUserTask(userID int, campaignID int, taskID int) (database.UserTask, error) {
-	tasks, err := database.GetTasksByCampaignID(campaignID)
+// updateTask credits the swap to the trader of each campaign.
+func updateTask(campaigns []database.Campaign, sender, recipient, origin string, usd decimal.Decimal, t int64) error {
+	for _, a := range swapAccruals(campaigns, sender, recipient, origin, usd, t) {
+		err := a.apply()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply credits the accrual, or takes it back. Traders with a label excluded
+// from campaigns are skipped both ways. It fails if any task could not be
+// updated.
+func (a accrual) apply() error {
+	excluded, err := database.IsAddressLabeled(a.chainID, a.trader, LoadMEVConfig().ExcludedLabels)
 	if err != nil {
-		return database.UserTask{}, err
+		return fmt.Errorf("failed to check address labels: %w", err)
+	}
+	if excluded {
+		log.Printf("Skipped labeled trader %s for campaign %d", a.trader, a.campaignID)
+		return nil
 	}
 
-	onboardingUserTask := database.UserTask{}
+	if a.rollback {
+		user, err := database.GetUserByAddress(a.trader)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get user by address: %w", err)
+		}
+		return rollbackTasks(a.campaignID, user.UserID, a.amount, a.time, a.applied)
+	}
+	userID, err := database.GetOrCreateUserID(a.trader)
+	if err != nil {
+		return fmt.Errorf("failed to get or create user ID: %w", err)
+	}
+	return accrueTasks(a.campaignID, userID, a.amount, a.time, a.applied)
+}
 
+// accrueTasks credits the swap to every task of the campaign through its
+// task type, skipping the tasks in applied and adding the updated ones to
+// it. The user's tasks of the campaign are created on their first swap.
+func accrueTasks(campaignID, userID int, usd decimal.Decimal, t int64, applied map[int]bool) error {
+	tasks, err := database.GetTasksByCampaignID(campaignID)
+	if err != nil {
+		return fmt.Errorf("failed to get tasks: %w", err)
+	}
+	failed := 0
 	for _, task := range tasks {
-		userTaskID, err := database.CreateUserTask(userID, task.TaskID, false, 0, 0)
+		if applied[task.TaskID] {
+			continue
+		}
+		taskType, err := GetTaskType(task.Type)
 		if err != nil {
-			log.Printf("Failed to create user task: %v", err)
+			log.Printf("Skipped task %d: %v", task.TaskID, err)
 			continue
 		}
-
-		if task.Type == "onboarding" && taskID == task.TaskID {
-			onboardingUserTask = database.UserTask{
-				UserTaskID: userTaskID,
-				UserID:     userID,
-				TaskID:     taskID,
-				Completed:  false,
-				Amount:     0,
-				Points:     0,
-			}
+		userTask, err := database.GetUserTaskByUserIDTaskID(userID, task.TaskID)
+		if err == sql.ErrNoRows {
+			var userTaskID int
+			userTaskID, err = database.CreateUserTask(userID, task.TaskID, false, decimal.Zero(), decimal.Zero())
+			userTask = database.UserTask{UserTaskID: userTaskID, UserID: userID, TaskID: task.TaskID, Amount: decimal.Zero(), Points: decimal.Zero()}
 		}
+		if err != nil {
+			log.Printf("Failed to get user task: %v", err)
+			failed++
+			continue
+		}
+		err = taskType.Accrue(task, userTask, usd, t)
+		if err != nil {
+			log.Printf("Failed to update task %d: %v", task.TaskID, err)
+			failed++
+			continue
+		}
+		applied[task.TaskID] = true
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to update %d tasks of campaign %d", failed, campaignID)
 	}
-	return onboardingUserTask, nil
+	return nil
 }
 
-func processOnboardingTasks(campaignID, userID int, usdc float64) {
-	task, err := database.GetOnboardingTaskByCampaignID(campaignID)
+// rollbackTasks takes the swap back from every task of the campaign the user
+// has started, skipping the tasks in applied and adding the updated ones to
+// it.
+func rollbackTasks(campaignID, userID int, usd decimal.Decimal, t int64, applied map[int]bool) error {
+	tasks, err := database.GetTasksByCampaignID(campaignID)
 	if err != nil {
-		log.Printf("Failed to get onboarding tasks: %v", err)
-		return
+		return fmt.Errorf("failed to get tasks: %w", err)
 	}
-
-	userTask, err := database.GetUserTaskByUserIDTaskID(userID, task.TaskID)
-	if err == sql.ErrNoRows {
-		userTask, err = insertUserTaskAndGetOnboardingUserTask(userID, campaignID, task.TaskID)
-		if err != nil {
-			log.Printf("Failed to insert user task: %v", err)
-			return
+	failed := 0
+	for _, task := range tasks {
+		if applied[task.TaskID] {
+			continue
 		}
-	} else if err != nil {
-		log.Printf("Failed to get user task: %v", err)
-		return
-	}
-
-	totalAmount := userTask.Amount + usdc
-	if !userTask.Completed && totalAmount >= task.OnboardingThreshold {
-		err = database.UpdateUserTask(userTask.UserTaskID, true, totalAmount, task.OnboardingReward)
+		taskType, err := GetTaskType(task.Type)
 		if err != nil {
-			log.Printf("Failed to update user task: %v", err)
-			return
+			log.Printf("Skipped task %d: %v", task.TaskID, err)
+			continue
 		}
-		err = database.CreateUserPointsHistory(userID, task.TaskID, campaignID, task.OnboardingReward)
-		if err != nil {
-			log.Printf("Failed to create user points history: %v", err)
-			return
+		userTask, err := database.GetUserTaskByUserIDTaskID(userID, task.TaskID)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			log.Printf("Failed to get user task: %v", err)
+			failed++
+			continue
 		}
-	} else if !userTask.Completed {
-		err = database.UpdateUserTask(userTask.UserTaskID, false, totalAmount, 0)
+		err = taskType.Rollback(task, userTask, usd, t)
 		if err != nil {
-			log.Printf("Failed to update user task: %v", err)
-			return
+			log.Printf("Failed to update task %d: %v", task.TaskID, err)
+			failed++
+			continue
 		}
+		applied[task.TaskID] = true
 	}
+	if failed > 0 {
+		return fmt.Errorf("failed to update %d tasks of campaign %d", failed, campaignID)
+	}
+	return nil
 }
 
-func processSharePoolTask(campaignID, userID int, USDC float64, t int64) {
-	tasks, err := database.GetActiveTasksByCampaignID(campaignID, t)
+// rollbackSwap returns the swap emitted by a reorged log and the accruals
+// that revert its task progress. The swap stays stored until deleteSwap is
+// called once they are all applied, so a rollback that fails is retried from
+// the stored swap. ok is false if the swap was not stored.
+func rollbackSwap(chainID uint64, vLog types.Log) (accruals []accrual, ok bool, err error) {
+	campaigns, err := database.GetCampaignsByAddress(chainID, vLog.Address.Hex())
 	if err != nil {
-		log.Printf("Failed to get active tasks: %v", err)
-		return
+		return nil, false, fmt.Errorf("failed to get campaign: %w", err)
 	}
-	for _, task := range tasks {
-		if task.Type == "share_pool" {
-			err = database.IncreaseUserTaskAmount(task.TaskID, userID, USDC)
-			if err != nil {
-				log.Printf("Failed to update task: %v", err)
-				continue
-			}
-		}
+	swap, err := database.GetSwapEvent(chainID, vLog.TxHash.Hex(), vLog.Index, vLog.BlockHash.Hex())
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to get reorged swap event: %w", err)
+	}
+
+	accruals = swapAccruals(campaigns, swap.SenderAddress, swap.RecipientAddress, swap.OriginAddress, swap.AmountUSDC, swap.SwapTime)
+	for i := range accruals {
+		accruals[i].rollback = true
+	}
+	return accruals, true, nil
+}
+
+// deleteSwap removes the swap emitted by a reorged log once its accruals are
+// taken back.
+func deleteSwap(chainID uint64, vLog types.Log) error {
+	swap, err := database.DeleteSwapEvent(chainID, vLog.TxHash.Hex(), vLog.Index, vLog.BlockHash.Hex())
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to delete reorged swap event: %w", err)
 	}
+	fmt.Printf("Rolled back swap event: Tx: %s, Block: %d, USDC: %s\n", swap.TransactionHash, swap.BlockNumber, swap.AmountUSDC)
+	return nil
 }
@@ -1,30 +1,151 @@
 package eth
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/spf13/viper"
 )
 
-var client *ethclient.Client
+// ChainClient is the subset of the Ethereum JSON-RPC API used by this package.
+type ChainClient interface {
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error)
+}
+
+const defaultHealthCheckInterval = 15 * time.Second
+
+// ClientConfig describes an rpc endpoint. Over http, calls can be recorded
+// to the Record fixture file, or answered from the Replay one without a node.
+type ClientConfig struct {
+	Name      string `mapstructure:"name"`
+	URL       string `mapstructure:"url"`
+	Transport string `mapstructure:"transport"`
+	Record    string `mapstructure:"record"`
+	Replay    string `mapstructure:"replay"`
+}
+
+func loadClientConfig() ClientConfig {
+	config := ClientConfig{
+		URL:       viper.GetString("eth.url"),
+		Transport: viper.GetString("eth.transport"),
+		Record:    viper.GetString("eth.record"),
+		Replay:    viper.GetString("eth.replay"),
+	}
+	if config.URL == "" {
+		apiKey := viper.GetString("infura.api_key")
+		if apiKey != "" {
+			config.URL = fmt.Sprintf("wss://mainnet.infura.io/ws/v3/%s", apiKey)
+		}
+	}
+	return config
+}
 
-func initClient() {
+func loadClientConfigs() []ClientConfig {
+	var configs []ClientConfig
+	err := viper.UnmarshalKey("eth.endpoints", &configs)
+	if err != nil {
+		log.Printf("Failed to parse eth endpoints: %v", err)
+	}
+	if len(configs) > 0 {
+		return configs
+	}
+	config := loadClientConfig()
+	if config.URL == "" {
+		return nil
+	}
+	return []ClientConfig{config}
+}
+
+// newClient dials the given endpoints, wrapping them in a Pool when there is
+// more than one.
+func newClient(configs []ClientConfig) (ChainClient, error) {
+	if len(configs) == 1 {
+		return Dial(context.Background(), configs[0])
+	}
+
+	clients := make(map[string]ChainClient)
+	for i, config := range configs {
+		name := config.Name
+		if name == "" {
+			name = fmt.Sprintf("endpoint-%d", i)
+		}
+		client, err := Dial(context.Background(), config)
+		if err != nil {
+			log.Printf("Skipping rpc endpoint %s: %v", name, err)
+			continue
+		}
+		clients[name] = client
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("failed to dial any eth endpoint")
+	}
+
+	interval := viper.GetDuration("eth.health_check_interval")
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	return NewPool(clients, interval), nil
+}
+
+func Dial(ctx context.Context, config ClientConfig) (ChainClient, error) {
+	if config.Record != "" || config.Replay != "" {
+		return dialRecorder(ctx, config)
+	}
+
+	var rpcClient *rpc.Client
 	var err error
-	api_key := viper.GetString("infura.api_key")
-	if api_key == "" {
-		log.Fatal("/config/config.toml infura api key is required")
+	switch config.Transport {
+	case "":
+		rpcClient, err = rpc.DialContext(ctx, config.URL)
+	case "ws":
+		rpcClient, err = rpc.DialWebsocket(ctx, config.URL, "")
+	case "http":
+		rpcClient, err = rpc.DialHTTP(config.URL)
+	case "ipc":
+		rpcClient, err = rpc.DialIPC(ctx, config.URL)
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", config.Transport)
 	}
-	client, err = ethclient.Dial(fmt.Sprintf("wss://mainnet.infura.io/ws/v3/%s", api_key))
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to dial eth client: %w", err)
 	}
+	return ethclient.NewClient(rpcClient), nil
 }
 
-func GetClient() *ethclient.Client {
-	if client == nil {
-		initClient()
+// dialRecorder dials an http endpoint through an rpcRecorder.
+func dialRecorder(ctx context.Context, config ClientConfig) (ChainClient, error) {
+	if config.Transport != "" && config.Transport != "http" {
+		return nil, fmt.Errorf("record and replay need the http transport, not %q", config.Transport)
+	}
+	if !strings.HasPrefix(config.URL, "http://") && !strings.HasPrefix(config.URL, "https://") {
+		return nil, fmt.Errorf("record and replay need an http url, not %q", config.URL)
+	}
+	recorder, err := newRPCRecorder(config.Record, config.Replay)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := rpc.DialOptions(ctx, config.URL, rpc.WithHTTPClient(&http.Client{Transport: recorder}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial eth client: %w", err)
 	}
-	return client
+	return ethclient.NewClient(rpcClient), nil
 }
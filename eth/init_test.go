@@ -0,0 +1,168 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockClient struct {
+	ChainClient
+	blockByNumber       func(ctx context.Context, number *big.Int) (*types.Block, error)
+	headerByNumber      func(ctx context.Context, number *big.Int) (*types.Header, error)
+	filterLogs          func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	subscribeFilterLogs func(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	callContract        func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	transactionByHash   func(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	transactionSender   func(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error)
+}
+
+type mockSubscription struct {
+	err  chan error
+	once sync.Once
+}
+
+func newMockSubscription() *mockSubscription {
+	return &mockSubscription{err: make(chan error, 1)}
+}
+
+func (m *mockSubscription) Err() <-chan error {
+	return m.err
+}
+
+func (m *mockSubscription) Unsubscribe() {
+	m.once.Do(func() { close(m.err) })
+}
+
+func (m *mockClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	if m.blockByNumber == nil {
+		return nil, errors.New("block not found")
+	}
+	return m.blockByNumber(ctx, number)
+}
+
+// BlockNumber returns the number of the latest header.
+func (m *mockClient) BlockNumber(ctx context.Context) (uint64, error) {
+	header, err := m.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+func (m *mockClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if m.headerByNumber != nil {
+		return m.headerByNumber(ctx, number)
+	}
+	block, err := m.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (m *mockClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if m.filterLogs == nil {
+		return nil, errors.New("no logs found")
+	}
+	return m.filterLogs(ctx, query)
+}
+
+func (m *mockClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	if m.subscribeFilterLogs == nil {
+		return nil, errors.New("subscriptions not supported")
+	}
+	return m.subscribeFilterLogs(ctx, query, ch)
+}
+
+func (m *mockClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if m.callContract == nil {
+		return nil, errors.New("execution reverted")
+	}
+	return m.callContract(ctx, msg, blockNumber)
+}
+
+func (m *mockClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if m.transactionByHash == nil {
+		return nil, false, ethereum.NotFound
+	}
+	return m.transactionByHash(ctx, hash)
+}
+
+func (m *mockClient) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	if m.transactionSender == nil {
+		return common.Address{}, ethereum.NotFound
+	}
+	return m.transactionSender(ctx, tx, block, index)
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		name      string
+		url       string
+		transport string
+		apiKey    string
+		want      ClientConfig
+	}{
+		{
+			name:      "Configured endpoint",
+			url:       "http://localhost:8545",
+			transport: "http",
+			apiKey:    "key",
+			want:      ClientConfig{URL: "http://localhost:8545", Transport: "http"},
+		},
+		{
+			name:   "Infura fallback",
+			apiKey: "key",
+			want:   ClientConfig{URL: "wss://mainnet.infura.io/ws/v3/key"},
+		},
+		{
+			name: "Nothing configured",
+			want: ClientConfig{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("eth.url", tt.url)
+			viper.Set("eth.transport", tt.transport)
+			viper.Set("infura.api_key", tt.apiKey)
+			assert.Equal(t, tt.want, loadClientConfig())
+		})
+	}
+}
+
+func TestDial(t *testing.T) {
+	_, err := Dial(context.Background(), ClientConfig{URL: "localhost:8545", Transport: "carrier-pigeon"})
+	assert.Error(t, err)
+
+	client, err := Dial(context.Background(), ClientConfig{URL: "http://localhost:8545", Transport: "http"})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+}
+
+func TestLoadClientConfigs(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("eth.endpoints", []map[string]interface{}{
+		{"name": "primary", "url": "wss://primary", "transport": "ws"},
+		{"url": "http://secondary"},
+	})
+	assert.Equal(t, []ClientConfig{
+		{Name: "primary", URL: "wss://primary", Transport: "ws"},
+		{URL: "http://secondary"},
+	}, loadClientConfigs())
+
+	viper.Reset()
+	viper.Set("eth.url", "http://single")
+	assert.Equal(t, []ClientConfig{{URL: "http://single"}}, loadClientConfigs())
+}
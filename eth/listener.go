@@ -4,61 +4,135 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/Largeb0525/Trading_Ace/database"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-func ListenToContractEvents() {
-	client := GetClient()
-	campaignAddresses, err := database.GetActiveCampaignAddresses()
+const (
+	confirmationCheckInterval = 12 * time.Second
+	resubscribeDelay          = 5 * time.Second
+	ingestionLockDelay        = 30 * time.Second
+)
+
+type listener struct {
+	chain         *Chain
+	pending       *pendingLogs
+	pipeline      *pipeline
+	confirmations uint64
+}
+
+// ListenToContractEvents follows the Swap events of the campaign pools of one
+// chain, over a subscription or by polling depending on the chain's ingestion
+// mode. Every chain runs its own listener, which holds the ingestion lock of
+// the chain so no backfill or other server credits its swaps at the same time.
+func ListenToContractEvents(chain *Chain) {
+	lockIngestion(chain)
+	l := &listener{
+		chain:         chain,
+		pending:       newPendingLogs(),
+		pipeline:      getPipeline(chain),
+		confirmations: chain.Confirmations,
+	}
+	campaignAddresses, err := database.GetActiveCampaignAddresses(chain.ID)
 	if err != nil {
 		log.Fatal(err)
 	}
 	if len(campaignAddresses) > 0 {
-		AddAddresses(campaignAddresses)
+		AddAddresses(chain.ID, campaignAddresses)
+	}
+	if chain.Ingestion == IngestionPoll {
+		l.poll()
+		return
 	}
 	for {
-		addrList := GetAddresses()
+		addrList := GetAddresses(chain.ID)
 		if len(addrList) == 0 {
-			fmt.Println("No addresses to listen to, waiting for changes...")
-			<-GetNotifyChannel()
+			fmt.Printf("No addresses to listen to on %s, waiting for changes...\n", chain.Name)
+			<-GetNotifyChannel(chain.ID)
 			continue
 		}
 
 		query := ethereum.FilterQuery{Addresses: addrList}
 		logs := make(chan types.Log)
 		ctx, cancel := context.WithCancel(context.Background())
-		sub, err := client.SubscribeFilterLogs(ctx, query, logs)
+		sub, err := chain.Client.SubscribeFilterLogs(ctx, query, logs)
+		if err != nil {
+			log.Printf("Failed to subscribe on %s: %v", chain.Name, err)
+			cancel()
+			waitForResubscribe(chain.ID)
+			continue
+		}
+
+		err = l.backfillAddresses(addrList)
 		if err != nil {
-			log.Printf("Failed to subscribe: %v", err)
+			log.Printf("Failed to backfill missed logs on %s: %v", chain.Name, err)
 			cancel()
-			<-GetNotifyChannel()
+			sub.Unsubscribe()
+			waitForResubscribe(chain.ID)
 			continue
 		}
-		fmt.Println("Listening for Swap events...")
+		fmt.Printf("Listening for Swap events on %s...\n", chain.Name)
 
 		done := make(chan struct{})
 		go func() {
+			ticker := time.NewTicker(confirmationCheckInterval)
+			defer ticker.Stop()
 			for {
 				select {
 				case err := <-sub.Err():
 					if err != nil {
-						log.Printf("Subscription error: %v", err)
+						log.Printf("Subscription error on %s: %v", chain.Name, err)
 					}
 					cancel()
 					done <- struct{}{}
 					return
 				case vLog := <-logs:
-					handleLogs(vLog)
+					l.receiveLog(vLog)
+				case <-ticker.C:
+					l.advanceCheckpoints(addrList)
 				}
 			}
 		}()
 
-		<-GetNotifyChannel()
-		cancel()
-		sub.Unsubscribe()
-		<-done
+		select {
+		case <-GetNotifyChannel(chain.ID):
+			cancel()
+			sub.Unsubscribe()
+			<-done
+		case <-done:
+			sub.Unsubscribe()
+			waitForResubscribe(chain.ID)
+		}
+	}
+}
+
+// waitForResubscribe pauses before the next subscription attempt, returning
+// early if the set of watched addresses of the chain changes.
+func waitForResubscribe(chainID uint64) {
+	select {
+	case <-GetNotifyChannel(chainID):
+		addressesLock.Lock()
+		notifyChange(chainID)
+		addressesLock.Unlock()
+	case <-time.After(resubscribeDelay):
+	}
+}
+
+// lockIngestion waits until the ingestion lock of the chain is free and keeps
+// it for as long as the process runs.
+func lockIngestion(chain *Chain) {
+	for {
+		_, ok, err := database.TryLockIngestion(chain.ID)
+		if err != nil {
+			log.Printf("Failed to lock ingestion on %s: %v", chain.Name, err)
+		} else if ok {
+			return
+		} else {
+			fmt.Printf("Ingestion on %s is locked by another process, waiting...\n", chain.Name)
+		}
+		time.Sleep(ingestionLockDelay)
 	}
 }
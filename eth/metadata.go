@@ -0,0 +1,259 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const pairABI = `[
+	{"inputs": [], "name": "token0", "outputs": [{"internalType": "address", "name": "", "type": "address"}], "stateMutability": "view", "type": "function"},
+	{"inputs": [], "name": "token1", "outputs": [{"internalType": "address", "name": "", "type": "address"}], "stateMutability": "view", "type": "function"},
+	{"inputs": [], "name": "decimals", "outputs": [{"internalType": "uint8", "name": "", "type": "uint8"}], "stateMutability": "view", "type": "function"},
+	{"inputs": [], "name": "symbol", "outputs": [{"internalType": "string", "name": "", "type": "string"}], "stateMutability": "view", "type": "function"},
+	{"inputs": [], "name": "getReserves", "outputs": [{"internalType": "uint112", "name": "reserve0", "type": "uint112"}, {"internalType": "uint112", "name": "reserve1", "type": "uint112"}, {"internalType": "uint32", "name": "blockTimestampLast", "type": "uint32"}], "stateMutability": "view", "type": "function"},
+	{"inputs": [], "name": "fee", "outputs": [{"internalType": "uint24", "name": "", "type": "uint24"}], "stateMutability": "view", "type": "function"}
+]`
+
+// defaultQuoteTokens are the USD stablecoins volume is measured in on each
+// chain unless the chain configures its own quote tokens.
+var defaultQuoteTokens = map[uint64][]string{
+	MainnetChainID: {
+		"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", // USDC
+		"0xdAC17F958D2ee523a2206206994597C13D831ec7", // USDT
+		"0x6B175474E89094C44Da98b954EedeAC495271d0F", // DAI
+	},
+	10: {
+		"0x0b2C639c533813f4Aa9D7837CAf62653d097Ff85", // USDC on Optimism
+	},
+	8453: {
+		"0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", // USDC on Base
+	},
+	42161: {
+		"0xaf88d065e77c8cC2239327C5EDb3A432268e5831", // USDC on Arbitrum One
+	},
+}
+
+// defaultWrappedNativeTokens are the WETH contracts of each chain unless the
+// chain configures its own.
+var defaultWrappedNativeTokens = map[uint64]string{
+	MainnetChainID: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+	10:             "0x4200000000000000000000000000000000000006",
+	8453:           "0x4200000000000000000000000000000000000006",
+	42161:          "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1",
+}
+
+var ErrNoQuoteToken = errors.New("pool has no supported quote token")
+
+// poolKey identifies a pool, as the same address can be a different
+// contract on every chain.
+type poolKey struct {
+	chainID uint64
+	address common.Address
+}
+
+var (
+	poolCache     = make(map[poolKey]database.Pool)
+	poolCacheLock sync.RWMutex
+)
+
+func quoteTokens(chainID uint64) []string {
+	if chain, err := GetChain(chainID); err == nil && len(chain.QuoteTokens) > 0 {
+		return chain.QuoteTokens
+	}
+	return defaultQuoteTokens[chainID]
+}
+
+func wrappedNativeToken(chainID uint64) string {
+	if chain, err := GetChain(chainID); err == nil && chain.WrappedNative != "" {
+		return chain.WrappedNative
+	}
+	return defaultWrappedNativeTokens[chainID]
+}
+
+// wrappedNativeAmount returns the amount of WETH traded in a swap, or zero
+// when neither pool token is the chain's wrapped native token.
+func wrappedNativeAmount(chainID uint64, pool database.Pool, swapEvent SwapEvent) decimal.Decimal {
+	weth := wrappedNativeToken(chainID)
+	switch {
+	case weth == "":
+		return decimal.Zero()
+	case common.HexToAddress(pool.Token0) == common.HexToAddress(weth):
+		return tradedAmount(pool, swapEvent, 0)
+	case common.HexToAddress(pool.Token1) == common.HexToAddress(weth):
+		return tradedAmount(pool, swapEvent, 1)
+	default:
+		return decimal.Zero()
+	}
+}
+
+func isQuoteToken(chainID uint64, token string) bool {
+	for _, quoteToken := range quoteTokens(chainID) {
+		if common.HexToAddress(quoteToken) == common.HexToAddress(token) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteSide returns 0 or 1 for the pool token volume is measured in.
+func quoteSide(pool database.Pool) (int, error) {
+	if isQuoteToken(pool.ChainID, pool.Token0) {
+		return 0, nil
+	}
+	if isQuoteToken(pool.ChainID, pool.Token1) {
+		return 1, nil
+	}
+	return 0, ErrNoQuoteToken
+}
+
+func HasQuoteToken(pool database.Pool) bool {
+	_, err := quoteSide(pool)
+	return err == nil
+}
+
+// GetPool returns the metadata of a pool, resolving it on chain as a pool of
+// the given protocol the first time the pool is seen.
+func GetPool(chain *Chain, poolAddress common.Address, protocol string) (database.Pool, error) {
+	key := poolKey{chainID: chain.ID, address: poolAddress}
+	poolCacheLock.RLock()
+	pool, ok := poolCache[key]
+	poolCacheLock.RUnlock()
+	if ok {
+		return pool, nil
+	}
+
+	stored, err := database.GetPoolByAddress(chain.ID, poolAddress.Hex())
+	if err == nil {
+		pool = *stored
+	} else if err == sql.ErrNoRows {
+		pool, err = ResolvePool(chain, poolAddress, protocol)
+		if err != nil {
+			return database.Pool{}, err
+		}
+	} else {
+		return database.Pool{}, fmt.Errorf("failed to get pool: %w", err)
+	}
+
+	poolCacheLock.Lock()
+	poolCache[key] = pool
+	poolCacheLock.Unlock()
+	return pool, nil
+}
+
+// ResolvePool checks the pool implements the given protocol, reads the pair
+// tokens, their decimals and symbols through eth_call and stores them in the
+// pools table.
+func ResolvePool(chain *Chain, poolAddress common.Address, protocol string) (database.Pool, error) {
+	client := chain.Client
+	contractABI, err := abi.JSON(strings.NewReader(pairABI))
+	if err != nil {
+		return database.Pool{}, fmt.Errorf("failed to parse pair ABI: %w", err)
+	}
+
+	probe, ok := protocolProbes[protocol]
+	if !ok {
+		return database.Pool{}, fmt.Errorf("%w: %s", ErrUnknownProtocol, protocol)
+	}
+	_, err = call(client, contractABI, poolAddress, probe)
+	if err != nil {
+		return database.Pool{}, fmt.Errorf("%s is not a %s pool: %w", poolAddress.Hex(), protocol, err)
+	}
+
+	pool := database.Pool{ChainID: chain.ID, PoolAddress: poolAddress.Hex(), Protocol: protocol}
+	token0, err := callAddress(client, contractABI, poolAddress, "token0")
+	if err != nil {
+		return database.Pool{}, err
+	}
+	token1, err := callAddress(client, contractABI, poolAddress, "token1")
+	if err != nil {
+		return database.Pool{}, err
+	}
+	pool.Token0, pool.Token1 = token0.Hex(), token1.Hex()
+
+	pool.Token0Decimals, err = callDecimals(client, contractABI, token0)
+	if err != nil {
+		return database.Pool{}, err
+	}
+	pool.Token1Decimals, err = callDecimals(client, contractABI, token1)
+	if err != nil {
+		return database.Pool{}, err
+	}
+	pool.Token0Symbol = callSymbol(client, contractABI, token0)
+	pool.Token1Symbol = callSymbol(client, contractABI, token1)
+
+	err = database.CreatePool(pool)
+	if err != nil {
+		return database.Pool{}, err
+	}
+	return pool, nil
+}
+
+func call(client ChainClient, contractABI abi.ABI, contract common.Address, method string) ([]interface{}, error) {
+	return callAt(client, contractABI, contract, method, nil)
+}
+
+// callAt calls a view method without arguments against the state at the
+// given block, or the latest state when blockNumber is nil.
+func callAt(client ChainClient, contractABI abi.ABI, contract common.Address, method string, blockNumber *big.Int) ([]interface{}, error) {
+	data, err := contractABI.Pack(method)
+	if err != nil {
+		return nil, err
+	}
+	output, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &contract, Data: data}, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s on %s: %w", method, contract.Hex(), err)
+	}
+	values, err := contractABI.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s of %s: %w", method, contract.Hex(), err)
+	}
+	return values, nil
+}
+
+func callAddress(client ChainClient, contractABI abi.ABI, contract common.Address, method string) (common.Address, error) {
+	values, err := call(client, contractABI, contract, method)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return values[0].(common.Address), nil
+}
+
+func callDecimals(client ChainClient, contractABI abi.ABI, token common.Address) (int, error) {
+	values, err := call(client, contractABI, token, "decimals")
+	if err != nil {
+		return 0, err
+	}
+	return int(values[0].(uint8)), nil
+}
+
+// callSymbol returns the token symbol, also accepting tokens such as MKR that
+// return bytes32 instead of string. Symbols are informational only.
+func callSymbol(client ChainClient, contractABI abi.ABI, token common.Address) string {
+	values, err := call(client, contractABI, token, "symbol")
+	if err == nil {
+		return values[0].(string)
+	}
+	data, _ := contractABI.Pack("symbol")
+	output, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil || len(output) != 32 {
+		return ""
+	}
+	return string(bytes.TrimRight(output, "\x00"))
+}
+
+// scaleAmount converts a raw token amount into whole token units.
+func scaleAmount(amount *big.Int, decimals int) decimal.Decimal {
+	return decimal.NewFromBigInt(amount, decimals)
+}
@@ -0,0 +1,148 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvePool(t *testing.T) {
+	contractABI, _ := abi.JSON(strings.NewReader(pairABI))
+	poolAddress := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	mkr := common.HexToAddress("0x9f8F72aA9304c8B593d555F12eF6589cC3A579A2")
+
+	client := &mockClient{
+		callContract: func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+			method, err := contractABI.MethodById(msg.Data)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case *msg.To == poolAddress && method.Name == "getReserves":
+				return method.Outputs.Pack(big.NewInt(1), big.NewInt(2), uint32(3))
+			case *msg.To == poolAddress && method.Name == "token0":
+				return method.Outputs.Pack(usdc)
+			case *msg.To == poolAddress && method.Name == "token1":
+				return method.Outputs.Pack(mkr)
+			case *msg.To == usdc && method.Name == "decimals":
+				return method.Outputs.Pack(uint8(6))
+			case *msg.To == mkr && method.Name == "decimals":
+				return method.Outputs.Pack(uint8(18))
+			case *msg.To == usdc && method.Name == "symbol":
+				return method.Outputs.Pack("USDC")
+			case *msg.To == mkr && method.Name == "symbol":
+				return common.RightPadBytes([]byte("MKR"), 32), nil
+			}
+			return nil, errors.New("execution reverted")
+		},
+	}
+
+	var stored database.Pool
+	patches := gomonkey.ApplyFunc(database.CreatePool, func(pool database.Pool) error {
+		stored = pool
+		return nil
+	})
+	defer patches.Reset()
+
+	pool, err := ResolvePool(testChain(client), poolAddress, ProtocolUniswapV2)
+	assert.NoError(t, err)
+	expected := database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    poolAddress.Hex(),
+		Protocol:       ProtocolUniswapV2,
+		Token0:         usdc.Hex(),
+		Token1:         mkr.Hex(),
+		Token0Symbol:   "USDC",
+		Token1Symbol:   "MKR",
+		Token0Decimals: 6,
+		Token1Decimals: 18,
+	}
+	assert.Equal(t, expected, pool)
+	assert.Equal(t, expected, stored)
+
+	_, err = ResolvePool(testChain(client), poolAddress, ProtocolUniswapV3)
+	assert.Error(t, err)
+
+	_, err = ResolvePool(testChain(client), poolAddress, "curve")
+	assert.ErrorIs(t, err, ErrUnknownProtocol)
+
+	_, err = ResolvePool(testChain(client), common.HexToAddress("0x123"), ProtocolUniswapV2)
+	assert.Error(t, err)
+}
+
+func Test_quoteSide(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    database.Pool
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "USDC is token0",
+			pool:    database.Pool{ChainID: MainnetChainID, Token0: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", Token1: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"},
+			want:    0,
+			wantErr: false,
+		},
+		{
+			name:    "DAI is token1",
+			pool:    database.Pool{ChainID: MainnetChainID, Token0: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Token1: "0x6b175474e89094c44da98b954eedeac495271d0f"},
+			want:    1,
+			wantErr: false,
+		},
+		{
+			name:    "Mainnet USDC on another chain",
+			pool:    database.Pool{ChainID: 42161, Token0: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", Token1: "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"},
+			wantErr: true,
+		},
+		{
+			name:    "Arbitrum USDC is token1",
+			pool:    database.Pool{ChainID: 42161, Token0: "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1", Token1: "0xaf88d065e77c8cC2239327C5EDb3A432268e5831"},
+			want:    1,
+			wantErr: false,
+		},
+		{
+			name:    "No stablecoin",
+			pool:    database.Pool{ChainID: MainnetChainID, Token0: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Token1: "0x6982508145454Ce325dDbE47a25d4ec3d2311933"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quoteSide(tt.pool)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("quoteSide() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("quoteSide() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_scaleAmount(t *testing.T) {
+	large, _ := new(big.Int).SetString("123456789000000000000000000", 10)
+	odd, _ := new(big.Int).SetString("1234567891234567891", 10)
+	assert.Equal(t, decimal.NewFromInt(400), scaleAmount(big.NewInt(400000000), 6))
+	assert.Equal(t, decimal.NewFromInt(123456789), scaleAmount(large, 18))
+	assert.Equal(t, "1.234567891234567891", scaleAmount(odd, 18).String())
+}
+
+func setPoolCache(pools ...database.Pool) {
+	poolCacheLock.Lock()
+	defer poolCacheLock.Unlock()
+	for _, pool := range pools {
+		poolCache[poolKey{chainID: pool.ChainID, address: common.HexToAddress(pool.PoolAddress)}] = pool
+	}
+}
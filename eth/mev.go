@@ -0,0 +1,190 @@
+package eth
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+// Labels given to addresses by MEV detection.
+const (
+	LabelSandwich = "sandwich"
+	LabelMEVBot   = "mev_bot"
+)
+
+const defaultMEVMinZeroBytes = 4
+
+var defaultMEVTolerance = decimal.RequireFromString("0.05")
+
+// MEVConfig tunes MEV detection. A back-run matches a front-run when their
+// token0 amounts differ by at most Tolerance of the larger one. Addresses
+// starting with at least MinZeroBytes zero bytes, a gas optimisation of MEV
+// bots, and KnownBots are labeled mev_bot. Traders with any of
+// ExcludedLabels earn nothing from onboarding and share pool tasks.
+type MEVConfig struct {
+	Tolerance      decimal.Decimal
+	MinZeroBytes   int
+	KnownBots      []string
+	ExcludedLabels []string
+}
+
+func LoadMEVConfig() MEVConfig {
+	config := MEVConfig{
+		Tolerance:      defaultMEVTolerance,
+		MinZeroBytes:   defaultMEVMinZeroBytes,
+		KnownBots:      viper.GetStringSlice("mev.known_bots"),
+		ExcludedLabels: []string{LabelSandwich, LabelMEVBot},
+	}
+	if tolerance, err := decimal.NewFromString(viper.GetString("mev.tolerance")); err == nil {
+		config.Tolerance = tolerance
+	}
+	if viper.IsSet("mev.min_zero_bytes") {
+		config.MinZeroBytes = viper.GetInt("mev.min_zero_bytes")
+	}
+	if viper.IsSet("mev.excluded_labels") {
+		config.ExcludedLabels = viper.GetStringSlice("mev.excluded_labels")
+	}
+	return config
+}
+
+// DetectMEV labels the addresses behind sandwiches and known MEV bot patterns
+// among the swaps of one pool, whose transaction origins must be resolved. A
+// sandwich is a front-run and a back-run in the same block signed by the same
+// account, trading in opposite directions with matching sizes, around at
+// least one swap of another account in the direction of the front-run. The
+// transaction origin, the recipient, which keeps the profit, and the sender
+// if it is the recipient are labeled.
+func DetectMEV(swaps []SwapInfo, config MEVConfig) []database.AddressLabel {
+	var labels []database.AddressLabel
+	seen := make(map[string]bool)
+	label := func(chainID uint64, address, name, detail string) {
+		key := name + strings.ToLower(address)
+		if address == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		labels = append(labels, database.AddressLabel{ChainID: chainID, Address: address, Label: name, Detail: detail})
+	}
+
+	blocks := make(map[uint64][]SwapInfo)
+	for _, swap := range swaps {
+		blocks[swap.BlockNumber] = append(blocks[swap.BlockNumber], swap)
+	}
+	for _, block := range blocks {
+		sort.Slice(block, func(i, j int) bool { return block[i].LogIndex < block[j].LogIndex })
+		for i, front := range block {
+			frontDelta := token0Delta(front)
+			for k := i + 2; k < len(block); k++ {
+				back := block[k]
+				if !sameActor(front, back) || !reverses(frontDelta, token0Delta(back), config.Tolerance) {
+					continue
+				}
+				victim, ok := sandwichVictim(front, block[i+1:k])
+				if !ok {
+					continue
+				}
+				detail := fmt.Sprintf("sandwiched tx %s between tx %s and tx %s in block %d", victim.TxHash, front.TxHash, back.TxHash, front.BlockNumber)
+				label(front.ChainID, front.Origin, LabelSandwich, detail)
+				label(front.ChainID, front.Recipient, LabelSandwich, detail)
+				if sameAddress(front.Sender, front.Recipient) {
+					label(front.ChainID, front.Sender, LabelSandwich, detail)
+				}
+				break
+			}
+		}
+	}
+
+	knownBots := make(map[string]bool, len(config.KnownBots))
+	for _, bot := range config.KnownBots {
+		knownBots[strings.ToLower(bot)] = true
+	}
+	for _, swap := range swaps {
+		for _, address := range []string{swap.Sender, swap.Recipient, swap.Origin} {
+			if address == "" {
+				continue
+			}
+			if knownBots[strings.ToLower(address)] {
+				label(swap.ChainID, address, LabelMEVBot, "known MEV bot")
+			} else if config.MinZeroBytes > 0 && leadingZeroBytes(address) >= config.MinZeroBytes {
+				label(swap.ChainID, address, LabelMEVBot, fmt.Sprintf("address starts with %d zero bytes", leadingZeroBytes(address)))
+			}
+		}
+	}
+	return labels
+}
+
+// sameActor reports whether two swaps were signed by the same account.
+// Routers are shared by everyone, so the sender and recipient of a swap say
+// nothing about who made it.
+func sameActor(a, b SwapInfo) bool {
+	return sameAddress(a.Origin, b.Origin)
+}
+
+// sandwichVictim returns the first swap between a front-run and its back-run
+// made by someone else in the direction of the front-run.
+func sandwichVictim(front SwapInfo, between []SwapInfo) (SwapInfo, bool) {
+	direction := token0Delta(front).Sign()
+	for _, swap := range between {
+		if !sameActor(front, swap) && token0Delta(swap).Sign() == direction {
+			return swap, true
+		}
+	}
+	return SwapInfo{}, false
+}
+
+func leadingZeroBytes(address string) int {
+	if !common.IsHexAddress(address) {
+		return 0
+	}
+	count := 0
+	for _, b := range common.HexToAddress(address).Bytes() {
+		if b != 0 {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// labelMEV stores the labels DetectMEV finds among the swaps.
+func labelMEV(swaps []SwapInfo, config MEVConfig) {
+	for _, label := range DetectMEV(swaps, config) {
+		err := LabelAddress(label, config)
+		if err != nil {
+			log.Printf("Failed to label %s: %v", label.Address, err)
+		}
+	}
+}
+
+// LabelAddress stores a label. An address given one of the excluded labels
+// earns nothing from then on, and the onboarding, milestone and streak
+// rewards it was paid on the chain before it was labeled are taken back.
+func LabelAddress(label database.AddressLabel, config MEVConfig) error {
+	err := database.CreateAddressLabel(label)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(config.ExcludedLabels, label.Label) {
+		return nil
+	}
+	user, err := database.GetUserByAddress(label.Address)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get user by address: %w", err)
+	}
+	err = database.RevokeUserTaskRewards(user.UserID, label.ChainID, []string{TaskTypeOnboarding, TaskTypeMilestone, TaskTypeStreak})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Revoked rewards of %s labeled %s on chain %d\n", label.Address, label.Label, label.ChainID)
+	return nil
+}
@@ -0,0 +1,147 @@
+package eth
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectMEV(t *testing.T) {
+	config := MEVConfig{Tolerance: decimal.RequireFromString("0.05"), MinZeroBytes: 4, KnownBots: []string{"0xknownbot"}}
+	bot := "0xB07B07b07b07b07B07b07B07B07b07b07B07B07b"
+	mevSwap := func(tx, origin, sender, recipient string, block uint64, logIndex uint, amount0 int64) SwapInfo {
+		swap := washSwap(tx, sender, recipient, block, amount0)
+		swap.ChainID = MainnetChainID
+		swap.Sender = sender
+		swap.Origin = origin
+		swap.LogIndex = logIndex
+		return swap
+	}
+	tests := []struct {
+		name   string
+		swaps  []SwapInfo
+		labels []string
+	}{
+		{
+			name: "Sandwich",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "0xsearcher", bot, bot, 100, 0, 1000),
+				mevSwap("0x2", "0xvictim", "0xrouter", "0xvictim", 100, 1, 300),
+				mevSwap("0x3", "0xsearcher", bot, bot, 100, 2, -990),
+			},
+			labels: []string{LabelSandwich + " 0xsearcher", LabelSandwich + " " + bot},
+		},
+		{
+			name: "Back-run in another block",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "0xsearcher", bot, bot, 100, 0, 1000),
+				mevSwap("0x2", "0xvictim", "0xrouter", "0xvictim", 100, 1, 300),
+				mevSwap("0x3", "0xsearcher", bot, bot, 101, 0, -1000),
+			},
+			labels: nil,
+		},
+		{
+			name: "No victim in the direction of the front-run",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "0xsearcher", bot, bot, 100, 0, 1000),
+				mevSwap("0x2", "0xvictim", "0xrouter", "0xvictim", 100, 1, -300),
+				mevSwap("0x3", "0xsearcher", bot, bot, 100, 2, -1000),
+			},
+			labels: nil,
+		},
+		{
+			name: "Router user is not labeled as sender",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "0xattacker", "0xrouter", "0xattacker", 100, 0, 1000),
+				mevSwap("0x2", "0xvictim", "0xrouter", "0xvictim", 100, 1, 300),
+				mevSwap("0x3", "0xattacker", "0xrouter", "0xattacker", 100, 2, -1000),
+			},
+			labels: []string{LabelSandwich + " 0xattacker"},
+		},
+		{
+			name: "Front-run and back-run sent to different recipients",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "0xsearcher", "0xrouter", "0xfresh1", 100, 0, 1000),
+				mevSwap("0x2", "0xvictim", "0xrouter", "0xvictim", 100, 1, 300),
+				mevSwap("0x3", "0xsearcher", "0xrouter", "0xfresh2", 100, 2, -1000),
+			},
+			labels: []string{LabelSandwich + " 0xsearcher", LabelSandwich + " 0xfresh1"},
+		},
+		{
+			name: "Different accounts trading through one router to itself",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "0xalice", "0xrouter", "0xrouter", 100, 0, 1000),
+				mevSwap("0x2", "0xbob", "0xrouter", "0xrouter", 100, 1, 300),
+				mevSwap("0x3", "0xcarol", "0xrouter", "0xrouter", 100, 2, -1000),
+			},
+			labels: nil,
+		},
+		{
+			name: "Known bot and zero byte address",
+			swaps: []SwapInfo{
+				mevSwap("0x1", "", "0xknownbot", "0xKnownBot", 100, 0, 1000),
+				mevSwap("0x2", "", "0x00000000003b3cc22aF3aE1EAc0440BcEe416B40", "0x00000000003b3cc22aF3aE1EAc0440BcEe416B40", 101, 0, 1000),
+				mevSwap("0x3", "", "0x000000A0b86991c6218b36c1d19D4a2e9Eb0cE36", "0xtrader", 102, 0, 1000),
+			},
+			labels: []string{LabelMEVBot + " 0xknownbot", LabelMEVBot + " 0x00000000003b3cc22aF3aE1EAc0440BcEe416B40"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, label := range DetectMEV(tt.swaps, config) {
+				assert.Equal(t, MainnetChainID, label.ChainID)
+				assert.NotEmpty(t, label.Detail)
+				got = append(got, label.Label+" "+label.Address)
+			}
+			assert.Equal(t, tt.labels, got)
+		})
+	}
+}
+
+func TestLoadMEVConfig(t *testing.T) {
+	defer viper.Reset()
+	assert.Equal(t, MEVConfig{Tolerance: decimal.RequireFromString("0.05"), MinZeroBytes: 4, KnownBots: nil, ExcludedLabels: []string{LabelSandwich, LabelMEVBot}}, LoadMEVConfig())
+
+	viper.Set("mev.min_zero_bytes", 0)
+	viper.Set("mev.known_bots", []string{"0xbot"})
+	viper.Set("mev.excluded_labels", []string{LabelSandwich})
+	assert.Equal(t, MEVConfig{Tolerance: decimal.RequireFromString("0.05"), MinZeroBytes: 0, KnownBots: []string{"0xbot"}, ExcludedLabels: []string{LabelSandwich}}, LoadMEVConfig())
+}
+
+func Test_leadingZeroBytes(t *testing.T) {
+	assert.Equal(t, 5, leadingZeroBytes("0x00000000003b3cc22aF3aE1EAc0440BcEe416B40"))
+	assert.Equal(t, 0, leadingZeroBytes("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"))
+	assert.Equal(t, 0, leadingZeroBytes("0xrouter"))
+}
+
+func TestLabelAddress(t *testing.T) {
+	var revoked []string
+	patches := gomonkey.ApplyFunc(database.CreateAddressLabel, func(label database.AddressLabel) error {
+		return nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(database.GetUserByAddress, func(address string) (*database.User, error) {
+		if address == "0xnewcomer" {
+			return nil, sql.ErrNoRows
+		}
+		return &database.User{UserID: 7, Address: address}, nil
+	})
+	patches.ApplyFunc(database.RevokeUserTaskRewards, func(userID int, chainID uint64, taskTypes []string) error {
+		assert.Equal(t, []string{TaskTypeOnboarding, TaskTypeMilestone, TaskTypeStreak}, taskTypes)
+		revoked = append(revoked, fmt.Sprintf("%d@%d", userID, chainID))
+		return nil
+	})
+	config := MEVConfig{ExcludedLabels: []string{LabelSandwich, LabelMEVBot}}
+
+	assert.NoError(t, LabelAddress(database.AddressLabel{ChainID: 10, Address: "0xbot", Label: LabelSandwich}, config))
+	assert.NoError(t, LabelAddress(database.AddressLabel{ChainID: 10, Address: "0xfund", Label: "market_maker"}, config))
+	assert.NoError(t, LabelAddress(database.AddressLabel{ChainID: 10, Address: "0xnewcomer", Label: LabelMEVBot}, config))
+	assert.Equal(t, []string{"7@10"}, revoked, "only traders given an excluded label should lose their rewards")
+}
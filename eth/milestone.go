@@ -0,0 +1,148 @@
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+)
+
+const TaskTypeMilestone = "milestone"
+
+// MilestoneTier pays Points once a user's volume reaches Volume.
+type MilestoneTier struct {
+	Volume decimal.Decimal `json:"volume"`
+	Points decimal.Decimal `json:"points"`
+}
+
+// MilestoneConfig lists the tiers of a milestone task by ascending volume.
+type MilestoneConfig struct {
+	Tiers []MilestoneTier `json:"tiers"`
+}
+
+// MilestoneStatus is the detail of a milestone task in the task status API.
+// NextTier is nil, and Remaining zero, once every tier is paid.
+type MilestoneStatus struct {
+	Tiers     []MilestoneTier `json:"tiers"`
+	NextTier  *MilestoneTier  `json:"nextTier"`
+	Remaining decimal.Decimal `json:"remaining"`
+}
+
+// milestoneTask pays every tier once as the user's volume over the task
+// window crosses it, each payout with its own points history entry. Tiers
+// taken back below their volume by a reorg are revoked.
+type milestoneTask struct{}
+
+func (milestoneTask) config(task database.Task) (MilestoneConfig, error) {
+	var config MilestoneConfig
+	err := decodeTaskConfig(task.Config, &config)
+	return config, err
+}
+
+func (milestoneTask) ValidateConfig(data json.RawMessage) error {
+	var config MilestoneConfig
+	if err := decodeTaskConfig(data, &config); err != nil {
+		return err
+	}
+	if len(config.Tiers) == 0 {
+		return fmt.Errorf("%w: milestone task needs at least one tier", ErrInvalidConfig)
+	}
+	previous := decimal.Zero()
+	for i, tier := range config.Tiers {
+		if tier.Volume.Cmp(previous) <= 0 {
+			return fmt.Errorf("%w: tier %d volume must be positive and above the previous tier", ErrInvalidConfig, i+1)
+		}
+		if tier.Points.Sign() < 0 {
+			return fmt.Errorf("%w: tier %d points cannot be negative", ErrInvalidConfig, i+1)
+		}
+		previous = tier.Volume
+	}
+	return nil
+}
+
+// crossedTiers returns the tiers whose volume is above from and at most to.
+func crossedTiers(tiers []MilestoneTier, from, to decimal.Decimal) []MilestoneTier {
+	var crossed []MilestoneTier
+	for _, tier := range tiers {
+		if tier.Volume.Cmp(from) > 0 && tier.Volume.Cmp(to) <= 0 {
+			crossed = append(crossed, tier)
+		}
+	}
+	return crossed
+}
+
+func inTaskWindow(task database.Task, t int64) bool {
+	return t >= task.StartTime && t <= task.EndTime
+}
+
+func (m milestoneTask) Accrue(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if !inTaskWindow(task, t) {
+		return nil
+	}
+	config, err := m.config(task)
+	if err != nil {
+		return err
+	}
+
+	totalAmount := userTask.Amount.Add(usd)
+	points := userTask.Points
+	for _, tier := range crossedTiers(config.Tiers, userTask.Amount, totalAmount) {
+		err = database.CreateUserPointsHistory(userTask.UserID, task.TaskID, task.CampaignID, tier.Points)
+		if err != nil {
+			return fmt.Errorf("failed to create user points history: %w", err)
+		}
+		points = points.Add(tier.Points)
+	}
+	completed := totalAmount.Cmp(config.Tiers[len(config.Tiers)-1].Volume) >= 0
+	return database.UpdateUserTask(userTask.UserTaskID, completed, totalAmount, points)
+}
+
+func (m milestoneTask) Rollback(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if !inTaskWindow(task, t) {
+		return nil
+	}
+	config, err := m.config(task)
+	if err != nil {
+		return err
+	}
+
+	totalAmount := decimal.Max(userTask.Amount.Sub(usd), decimal.Zero())
+	points := userTask.Points
+	for _, tier := range crossedTiers(config.Tiers, totalAmount, userTask.Amount) {
+		err = database.DeleteLatestUserPointsHistory(userTask.UserID, task.TaskID, tier.Points)
+		if err != nil {
+			return fmt.Errorf("failed to delete user points history: %w", err)
+		}
+		points = decimal.Max(points.Sub(tier.Points), decimal.Zero())
+	}
+	completed := totalAmount.Cmp(config.Tiers[len(config.Tiers)-1].Volume) >= 0
+	return database.UpdateUserTask(userTask.UserTaskID, completed, totalAmount, points)
+}
+
+// Settle does nothing: tiers are paid as soon as they are reached.
+func (milestoneTask) Settle(chain *Chain, campaign database.Campaign, task database.Task) error {
+	return nil
+}
+
+func (m milestoneTask) Status(task database.Task, userTask database.UserTask) (TaskStatus, error) {
+	config, err := m.config(task)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	detail := MilestoneStatus{Tiers: config.Tiers, Remaining: decimal.Zero()}
+	for _, tier := range config.Tiers {
+		if tier.Volume.Cmp(userTask.Amount) > 0 {
+			next := tier
+			detail.NextTier = &next
+			detail.Remaining = tier.Volume.Sub(userTask.Amount)
+			break
+		}
+	}
+	return TaskStatus{
+		Completed: userTask.Completed,
+		Amount:    userTask.Amount,
+		Points:    userTask.Points,
+		Detail:    detail,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTiers = `{"tiers":[{"volume":"1000","points":"50"},{"volume":"10000","points":"300"},{"volume":"100000","points":"2000"}]}`
+
+func TestMilestoneValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "Tiers", config: testTiers},
+		{name: "No tiers", config: `{"tiers":[]}`, wantErr: true},
+		{name: "Zero volume", config: `{"tiers":[{"volume":"0","points":"50"}]}`, wantErr: true},
+		{name: "Descending volume", config: `{"tiers":[{"volume":"10000","points":"300"},{"volume":"1000","points":"50"}]}`, wantErr: true},
+		{name: "Negative points", config: `{"tiers":[{"volume":"1000","points":"-1"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := milestoneTask{}.ValidateConfig(json.RawMessage(tt.config))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMilestoneTask(t *testing.T) {
+	var history []string
+	var userTask database.UserTask
+	patches := gomonkey.ApplyFunc(database.CreateUserPointsHistory, func(userID, taskID, campaignID int, points decimal.Decimal) error {
+		history = append(history, points.String())
+		return nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(database.DeleteLatestUserPointsHistory, func(userID, taskID int, points decimal.Decimal) error {
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i] == points.String() {
+				history = append(history[:i], history[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+	patches.ApplyFunc(database.UpdateUserTask, func(userTaskID int, completed bool, amount, points decimal.Decimal) error {
+		userTask.Completed, userTask.Amount, userTask.Points = completed, amount, points
+		return nil
+	})
+
+	task := database.Task{TaskID: 3, CampaignID: 1, Type: TaskTypeMilestone, Config: json.RawMessage(testTiers), StartTime: 100, EndTime: 200}
+	userTask = database.UserTask{UserTaskID: 1, UserID: 1, TaskID: 3, Amount: decimal.Zero(), Points: decimal.Zero()}
+	milestone := milestoneTask{}
+
+	steps := []struct {
+		name        string
+		rollback    bool
+		usd         int64
+		t           int64
+		wantHistory []string
+		wantPoints  string
+		wantNext    string
+		remaining   string
+	}{
+		{name: "Below the first tier", usd: 999, t: 150, wantPoints: "0", wantNext: "1000", remaining: "1"},
+		{name: "Outside the window", usd: 5000, t: 201, wantPoints: "0", wantNext: "1000", remaining: "1"},
+		{name: "Exactly the first tier", usd: 1, t: 150, wantHistory: []string{"50"}, wantPoints: "50", wantNext: "10000", remaining: "9000"},
+		{name: "Two tiers at once", usd: 99000, t: 150, wantHistory: []string{"50", "300", "2000"}, wantPoints: "2350", remaining: "0"},
+		{name: "Rollback below the last tier", rollback: true, usd: 50000, t: 150, wantHistory: []string{"50", "300"}, wantPoints: "350", wantNext: "100000", remaining: "50000"},
+		{name: "Tier paid again", usd: 50000, t: 150, wantHistory: []string{"50", "300", "2000"}, wantPoints: "2350", remaining: "0"},
+	}
+	for _, step := range steps {
+		var err error
+		if step.rollback {
+			err = milestone.Rollback(task, userTask, decimal.NewFromInt(step.usd), step.t)
+		} else {
+			err = milestone.Accrue(task, userTask, decimal.NewFromInt(step.usd), step.t)
+		}
+		assert.NoError(t, err, step.name)
+		assert.Equal(t, step.wantHistory, nilIfEmpty(history), step.name)
+		assert.Equal(t, step.wantPoints, userTask.Points.String(), step.name)
+
+		status, err := milestone.Status(task, userTask)
+		assert.NoError(t, err, step.name)
+		detail := status.Detail.(MilestoneStatus)
+		if step.wantNext == "" {
+			assert.Nil(t, detail.NextTier, step.name)
+			assert.True(t, status.Completed, step.name)
+		} else {
+			assert.Equal(t, step.wantNext, detail.NextTier.Volume.String(), step.name)
+			assert.False(t, status.Completed, step.name)
+		}
+		assert.Equal(t, step.remaining, detail.Remaining.String(), step.name)
+	}
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
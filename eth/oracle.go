@@ -0,0 +1,333 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+const aggregatorABI = `[
+	{"inputs": [], "name": "decimals", "outputs": [{"internalType": "uint8", "name": "", "type": "uint8"}], "stateMutability": "view", "type": "function"},
+	{"inputs": [], "name": "latestRoundData", "outputs": [{"internalType": "uint80", "name": "roundId", "type": "uint80"}, {"internalType": "int256", "name": "answer", "type": "int256"}, {"internalType": "uint256", "name": "startedAt", "type": "uint256"}, {"internalType": "uint256", "name": "updatedAt", "type": "uint256"}, {"internalType": "uint80", "name": "answeredInRound", "type": "uint80"}], "stateMutability": "view", "type": "function"}
+]`
+
+const defaultPriceCacheSize = 10000
+
+var (
+	ErrNoPrice      = errors.New("no price for token")
+	ErrUnpricedPool = errors.New("pool has no quote token and no token the price oracle can price")
+)
+
+// PriceOracle returns the USD price of one whole token as of a block. A zero
+// block number asks for the latest price.
+type PriceOracle interface {
+	Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error)
+}
+
+type tokenKey struct {
+	chainID uint64
+	token   common.Address
+}
+
+type StaticPriceConfig struct {
+	ChainID uint64 `mapstructure:"chain_id"`
+	Token   string `mapstructure:"token"`
+	Price   string `mapstructure:"price"`
+}
+
+type ChainlinkFeedConfig struct {
+	ChainID uint64 `mapstructure:"chain_id"`
+	Token   string `mapstructure:"token"`
+	Feed    string `mapstructure:"feed"`
+}
+
+type ReferencePoolConfig struct {
+	ChainID uint64 `mapstructure:"chain_id"`
+	Token   string `mapstructure:"token"`
+	Pool    string `mapstructure:"pool"`
+}
+
+var (
+	priceOracle     PriceOracle
+	priceOracleOnce sync.Once
+)
+
+// getPriceOracle returns the configured oracle, loading it on first use.
+func getPriceOracle() PriceOracle {
+	priceOracleOnce.Do(func() {
+		priceOracle = loadPriceOracle()
+	})
+	return priceOracle
+}
+
+// SetPriceOracle replaces the configured oracle.
+func SetPriceOracle(oracle PriceOracle) {
+	priceOracleOnce.Do(func() {})
+	priceOracle = oracle
+}
+
+// loadPriceOracle builds the oracle from the oracle section. Static prices
+// are tried first, then Chainlink feeds, then reference pools.
+func loadPriceOracle() PriceOracle {
+	var statics []StaticPriceConfig
+	if err := viper.UnmarshalKey("oracle.static", &statics); err != nil {
+		log.Printf("Failed to parse static prices: %v", err)
+	}
+	var feeds []ChainlinkFeedConfig
+	if err := viper.UnmarshalKey("oracle.chainlink", &feeds); err != nil {
+		log.Printf("Failed to parse chainlink feeds: %v", err)
+	}
+	var pools []ReferencePoolConfig
+	if err := viper.UnmarshalKey("oracle.pools", &pools); err != nil {
+		log.Printf("Failed to parse reference pools: %v", err)
+	}
+
+	static := make(staticOracle)
+	for _, config := range statics {
+		price, err := decimal.NewFromString(config.Price)
+		if err != nil || price.Sign() <= 0 {
+			log.Printf("Skipping static price of %s: invalid price %q", config.Token, config.Price)
+			continue
+		}
+		static[tokenKey{chainID: config.ChainID, token: common.HexToAddress(config.Token)}] = price
+	}
+	chainlink := newChainlinkOracle()
+	for _, config := range feeds {
+		chainlink.feeds[tokenKey{chainID: config.ChainID, token: common.HexToAddress(config.Token)}] = common.HexToAddress(config.Feed)
+	}
+	direct := oracles{static, chainlink}
+	reference := newPoolOracle(direct)
+	for _, config := range pools {
+		reference.pools[tokenKey{chainID: config.ChainID, token: common.HexToAddress(config.Token)}] = common.HexToAddress(config.Pool)
+	}
+
+	return newCachedOracle(oracles{static, chainlink, reference}, viper.GetInt("oracle.cache_size"))
+}
+
+func blockArg(blockNumber uint64) *big.Int {
+	if blockNumber == 0 {
+		return nil
+	}
+	return new(big.Int).SetUint64(blockNumber)
+}
+
+// staticOracle prices tokens from a fixed table.
+type staticOracle map[tokenKey]decimal.Decimal
+
+func (o staticOracle) Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error) {
+	price, ok := o[tokenKey{chainID: chain.ID, token: token}]
+	if !ok {
+		return decimal.Zero(), ErrNoPrice
+	}
+	return price, nil
+}
+
+// chainlinkOracle reads the latest answer of a Chainlink USD feed.
+type chainlinkOracle struct {
+	feeds map[tokenKey]common.Address
+	abi   abi.ABI
+}
+
+func newChainlinkOracle() *chainlinkOracle {
+	contractABI, err := abi.JSON(strings.NewReader(aggregatorABI))
+	if err != nil {
+		log.Fatalf("Failed to parse aggregator ABI: %v", err)
+	}
+	return &chainlinkOracle{feeds: make(map[tokenKey]common.Address), abi: contractABI}
+}
+
+func (o *chainlinkOracle) Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error) {
+	feed, ok := o.feeds[tokenKey{chainID: chain.ID, token: token}]
+	if !ok {
+		return decimal.Zero(), ErrNoPrice
+	}
+	values, err := callAt(chain.Client, o.abi, feed, "latestRoundData", blockArg(blockNumber))
+	if err != nil {
+		return decimal.Zero(), err
+	}
+	answer := values[1].(*big.Int)
+	if answer.Sign() <= 0 {
+		return decimal.Zero(), fmt.Errorf("feed %s answered %s", feed.Hex(), answer)
+	}
+	values, err = callAt(chain.Client, o.abi, feed, "decimals", blockArg(blockNumber))
+	if err != nil {
+		return decimal.Zero(), err
+	}
+	return decimal.NewFromBigInt(answer, int(values[0].(uint8))), nil
+}
+
+// poolOracle prices a token from the reserves of a Uniswap V2 pool pairing
+// it with a quote token or a token the counter oracle can price. The
+// reserves are the ones of the pool's last Sync event as of the block.
+type poolOracle struct {
+	pools   map[tokenKey]common.Address
+	counter PriceOracle
+	abi     abi.ABI
+}
+
+func newPoolOracle(counter PriceOracle) *poolOracle {
+	contractABI, err := abi.JSON(strings.NewReader(pairABI))
+	if err != nil {
+		log.Fatalf("Failed to parse pair ABI: %v", err)
+	}
+	return &poolOracle{pools: make(map[tokenKey]common.Address), counter: counter, abi: contractABI}
+}
+
+func (o *poolOracle) Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error) {
+	poolAddress, ok := o.pools[tokenKey{chainID: chain.ID, token: token}]
+	if !ok {
+		return decimal.Zero(), ErrNoPrice
+	}
+	pool, err := GetPool(chain, poolAddress, ProtocolUniswapV2)
+	if err != nil {
+		return decimal.Zero(), err
+	}
+	values, err := callAt(chain.Client, o.abi, poolAddress, "getReserves", blockArg(blockNumber))
+	if err != nil {
+		return decimal.Zero(), err
+	}
+	reserve0, reserve1 := values[0].(*big.Int), values[1].(*big.Int)
+
+	var reserve, otherReserve *big.Int
+	var decimals, otherDecimals int
+	var other string
+	switch token {
+	case common.HexToAddress(pool.Token0):
+		reserve, decimals, otherReserve, otherDecimals, other = reserve0, pool.Token0Decimals, reserve1, pool.Token1Decimals, pool.Token1
+	case common.HexToAddress(pool.Token1):
+		reserve, decimals, otherReserve, otherDecimals, other = reserve1, pool.Token1Decimals, reserve0, pool.Token0Decimals, pool.Token0
+	default:
+		return decimal.Zero(), fmt.Errorf("pool %s does not hold %s", pool.PoolAddress, token.Hex())
+	}
+	if reserve.Sign() == 0 {
+		return decimal.Zero(), fmt.Errorf("pool %s has no %s reserve", pool.PoolAddress, token.Hex())
+	}
+
+	otherPrice := decimal.NewFromInt(1)
+	if !isQuoteToken(chain.ID, other) {
+		otherPrice, err = o.counter.Price(chain, common.HexToAddress(other), blockNumber)
+		if err != nil {
+			return decimal.Zero(), err
+		}
+	}
+	return scaleAmount(otherReserve, otherDecimals).Mul(otherPrice).Div(scaleAmount(reserve, decimals)), nil
+}
+
+// oracles tries each oracle in turn and returns the first price found.
+type oracles []PriceOracle
+
+func (o oracles) Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error) {
+	lastErr := ErrNoPrice
+	for _, oracle := range o {
+		price, err := oracle.Price(chain, token, blockNumber)
+		if err == nil {
+			return price, nil
+		}
+		if !errors.Is(err, ErrNoPrice) {
+			lastErr = err
+		}
+	}
+	return decimal.Zero(), lastErr
+}
+
+type priceKey struct {
+	tokenKey
+	blockNumber uint64
+}
+
+// cachedOracle remembers prices by token and block, so swaps of the same
+// block are priced with a single lookup. Latest prices are not cached.
+type cachedOracle struct {
+	oracle PriceOracle
+	lock   sync.Mutex
+	size   int
+	prices map[priceKey]decimal.Decimal
+}
+
+func newCachedOracle(oracle PriceOracle, size int) *cachedOracle {
+	if size <= 0 {
+		size = defaultPriceCacheSize
+	}
+	return &cachedOracle{oracle: oracle, size: size, prices: make(map[priceKey]decimal.Decimal)}
+}
+
+func (o *cachedOracle) Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error) {
+	if blockNumber == 0 {
+		return o.oracle.Price(chain, token, blockNumber)
+	}
+	key := priceKey{tokenKey: tokenKey{chainID: chain.ID, token: token}, blockNumber: blockNumber}
+	o.lock.Lock()
+	price, ok := o.prices[key]
+	o.lock.Unlock()
+	if ok {
+		return price, nil
+	}
+
+	price, err := o.oracle.Price(chain, token, blockNumber)
+	if err != nil {
+		return decimal.Zero(), err
+	}
+	o.lock.Lock()
+	if len(o.prices) >= o.size {
+		o.prices = make(map[priceKey]decimal.Decimal)
+	}
+	o.prices[key] = price
+	o.lock.Unlock()
+	return price, nil
+}
+
+// tradedAmount returns the amount of one pool token traded in a swap, in
+// whole token units.
+func tradedAmount(pool database.Pool, swapEvent SwapEvent, side int) decimal.Decimal {
+	in, out, decimals := swapEvent.Amount0In, swapEvent.Amount0Out, pool.Token0Decimals
+	if side == 1 {
+		in, out, decimals = swapEvent.Amount1In, swapEvent.Amount1Out, pool.Token1Decimals
+	}
+	if in.Sign() > 0 {
+		return scaleAmount(in, decimals)
+	}
+	return scaleAmount(out, decimals)
+}
+
+// swapValue returns the USD value of a swap. Swaps of pools with a quote token
+// are worth the quote amount, others are priced through the price oracle.
+func swapValue(chain *Chain, pool database.Pool, swapEvent SwapEvent, blockNumber uint64) (decimal.Decimal, error) {
+	if side, err := quoteSide(pool); err == nil {
+		return tradedAmount(pool, swapEvent, side), nil
+	}
+	var lastErr error = ErrUnpricedPool
+	for side, token := range []string{pool.Token0, pool.Token1} {
+		price, err := getPriceOracle().Price(chain, common.HexToAddress(token), blockNumber)
+		if err != nil {
+			if !errors.Is(err, ErrNoPrice) {
+				lastErr = err
+			}
+			continue
+		}
+		return tradedAmount(pool, swapEvent, side).Mul(price), nil
+	}
+	return decimal.Zero(), lastErr
+}
+
+// CheckPoolPricing returns an error unless swaps of the pool can be valued,
+// either through a quote token or a token with a current oracle price.
+func CheckPoolPricing(chain *Chain, pool database.Pool) error {
+	if HasQuoteToken(pool) {
+		return nil
+	}
+	for _, token := range []string{pool.Token0, pool.Token1} {
+		if _, err := getPriceOracle().Price(chain, common.HexToAddress(token), 0); err == nil {
+			return nil
+		}
+	}
+	return ErrUnpricedPool
+}
@@ -0,0 +1,183 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testWETH = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	testPEPE = common.HexToAddress("0x6982508145454Ce325dDbE47a25d4ec3d2311933")
+)
+
+func TestChainlinkOracle(t *testing.T) {
+	contractABI, _ := abi.JSON(strings.NewReader(aggregatorABI))
+	feed := common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419")
+	answer := big.NewInt(300012345678)
+	client := &mockClient{
+		callContract: func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+			assert.Equal(t, big.NewInt(12345), blockNumber)
+			method, err := contractABI.MethodById(msg.Data)
+			if err != nil || *msg.To != feed {
+				return nil, errors.New("execution reverted")
+			}
+			if method.Name == "decimals" {
+				return method.Outputs.Pack(uint8(8))
+			}
+			return method.Outputs.Pack(big.NewInt(1), answer, big.NewInt(0), big.NewInt(0), big.NewInt(1))
+		},
+	}
+	oracle := newChainlinkOracle()
+	oracle.feeds[tokenKey{chainID: MainnetChainID, token: testWETH}] = feed
+
+	price, err := oracle.Price(testChain(client), testWETH, 12345)
+	assert.NoError(t, err)
+	assert.Equal(t, "3000.12345678", price.String())
+
+	_, err = oracle.Price(testChain(client), testPEPE, 12345)
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	answer = big.NewInt(-1)
+	_, err = oracle.Price(testChain(client), testWETH, 12345)
+	assert.Error(t, err)
+}
+
+func TestPoolOracle(t *testing.T) {
+	contractABI, _ := abi.JSON(strings.NewReader(pairABI))
+	poolAddress := common.HexToAddress("0xA43fe16908251ee70EF74718545e4FE6C5cCEc9f")
+	setPoolCache(database.Pool{
+		ChainID:        MainnetChainID,
+		PoolAddress:    poolAddress.Hex(),
+		Protocol:       ProtocolUniswapV2,
+		Token0:         testPEPE.Hex(),
+		Token1:         testWETH.Hex(),
+		Token0Decimals: 18,
+		Token1Decimals: 18,
+	})
+	reserve0, _ := new(big.Int).SetString("1000000000000000000000000000", 10)
+	reserve1, _ := new(big.Int).SetString("5000000000000000000", 10)
+	client := &mockClient{
+		callContract: func(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+			method, err := contractABI.MethodById(msg.Data)
+			if err != nil || *msg.To != poolAddress || method.Name != "getReserves" {
+				return nil, errors.New("execution reverted")
+			}
+			return method.Outputs.Pack(reserve0, reserve1, uint32(0))
+		},
+	}
+
+	counter := staticOracle{tokenKey{chainID: MainnetChainID, token: testWETH}: decimal.NewFromInt(2000)}
+	oracle := newPoolOracle(counter)
+	oracle.pools[tokenKey{chainID: MainnetChainID, token: testPEPE}] = poolAddress
+
+	price, err := oracle.Price(testChain(client), testPEPE, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00001", price.String())
+
+	_, err = oracle.Price(testChain(client), testWETH, 100)
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	oracle.counter = staticOracle{}
+	_, err = oracle.Price(testChain(client), testPEPE, 100)
+	assert.ErrorIs(t, err, ErrNoPrice)
+}
+
+func TestOracles(t *testing.T) {
+	failing := &countingOracle{err: errors.New("rpc down")}
+	static := staticOracle{tokenKey{chainID: MainnetChainID, token: testWETH}: decimal.NewFromInt(2000)}
+	chain := testChain(&mockClient{})
+
+	price, err := oracles{failing, static}.Price(chain, testWETH, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(2000), price)
+
+	_, err = oracles{static, failing}.Price(chain, testPEPE, 1)
+	assert.EqualError(t, err, "rpc down")
+
+	_, err = oracles{static}.Price(chain, testPEPE, 1)
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	_, err = static.Price(&Chain{ID: 10}, testWETH, 1)
+	assert.ErrorIs(t, err, ErrNoPrice, "prices are per chain")
+}
+
+func TestCachedOracle(t *testing.T) {
+	counting := &countingOracle{price: decimal.NewFromInt(2000)}
+	oracle := newCachedOracle(counting, 2)
+	chain := testChain(&mockClient{})
+
+	for i := 0; i < 3; i++ {
+		price, err := oracle.Price(chain, testWETH, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, decimal.NewFromInt(2000), price)
+	}
+	assert.Equal(t, 1, counting.calls)
+
+	// nolint
+	oracle.Price(chain, testWETH, 0)
+	// nolint
+	oracle.Price(chain, testWETH, 0)
+	assert.Equal(t, 3, counting.calls, "latest prices should not be cached")
+
+	// nolint
+	oracle.Price(chain, testWETH, 2)
+	// nolint
+	oracle.Price(chain, testWETH, 3)
+	assert.LessOrEqual(t, len(oracle.prices), 2)
+}
+
+func Test_swapValue(t *testing.T) {
+	defer resetPriceOracle()
+	SetPriceOracle(staticOracle{tokenKey{chainID: MainnetChainID, token: testWETH}: decimal.NewFromInt(2000)})
+	chain := testChain(&mockClient{})
+
+	pepePool := database.Pool{ChainID: MainnetChainID, Token0: testPEPE.Hex(), Token1: testWETH.Hex(), Token0Decimals: 18, Token1Decimals: 18}
+	wethIn, _ := new(big.Int).SetString("500000000000000000", 10)
+	pepeOut, _ := new(big.Int).SetString("100000000000000000000000000", 10)
+	swapEvent := SwapEvent{Amount0In: new(big.Int), Amount1In: wethIn, Amount0Out: pepeOut, Amount1Out: new(big.Int)}
+
+	usd, err := swapValue(chain, pepePool, swapEvent, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(1000), usd)
+	assert.Equal(t, "0.5", wrappedNativeAmount(MainnetChainID, pepePool, swapEvent).String())
+	assert.NoError(t, CheckPoolPricing(chain, pepePool))
+
+	usdcPool := database.Pool{ChainID: MainnetChainID, Token0: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", Token1: testPEPE.Hex(), Token0Decimals: 6, Token1Decimals: 18}
+	swapEvent = SwapEvent{Amount0In: big.NewInt(250000000), Amount1In: new(big.Int), Amount0Out: new(big.Int), Amount1Out: pepeOut}
+	usd, err = swapValue(chain, usdcPool, swapEvent, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(250), usd)
+	assert.True(t, wrappedNativeAmount(MainnetChainID, usdcPool, swapEvent).IsZero())
+
+	unpriced := database.Pool{ChainID: MainnetChainID, Token0: testPEPE.Hex(), Token1: "0x95aD61b0a150d79219dCF64E1E6Cc01f0B64C4cE", Token0Decimals: 18, Token1Decimals: 18}
+	_, err = swapValue(chain, unpriced, swapEvent, 1)
+	assert.ErrorIs(t, err, ErrUnpricedPool)
+	assert.ErrorIs(t, CheckPoolPricing(chain, unpriced), ErrUnpricedPool)
+}
+
+type countingOracle struct {
+	calls int
+	price decimal.Decimal
+	err   error
+}
+
+func (o *countingOracle) Price(chain *Chain, token common.Address, blockNumber uint64) (decimal.Decimal, error) {
+	o.calls++
+	return o.price, o.err
+}
+
+func resetPriceOracle() {
+	priceOracleOnce = sync.Once{}
+	priceOracle = nil
+}
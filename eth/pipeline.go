@@ -0,0 +1,478 @@
+package eth
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultPipelineWorkers   = 4
+	defaultPipelineQueueSize = 1000
+	persistRetryDelay        = 5 * time.Second
+)
+
+// QueueStats describes one stage of a pipeline. Depth is the number of jobs
+// waiting in the stage's queues, which for the decode stage includes decoded
+// logs waiting for earlier ones, and for the accrue stage stored logs waiting
+// for earlier ones.
+type QueueStats struct {
+	Workers   int    `json:"workers"`
+	Depth     int    `json:"depth"`
+	Capacity  int    `json:"capacity"`
+	Processed uint64 `json:"processed"`
+}
+
+type PipelineStats struct {
+	Decode   QueueStats `json:"decode"`
+	Persist  QueueStats `json:"persist"`
+	Accrue   QueueStats `json:"accrue"`
+	InFlight int        `json:"inFlight"`
+	Retrying int        `json:"retrying"`
+}
+
+// decodeJob is a log on its way through the pipeline. done is closed once
+// swapInfos or err is set, and stored once all its swaps are stored, or taken
+// back for a reorged log, with their accruals in credits. pending counts what
+// is left to do for the log: storing its swaps, and crediting and marking
+// accrued each stored swap.
+type decodeJob struct {
+	vLog      types.Log
+	swapInfos []SwapInfo
+	err       error
+	done      chan struct{}
+	stored    chan struct{}
+	credits   []credit
+	pending   atomic.Int32
+}
+
+// credit is an accrual to apply on the worker of its trader.
+type credit struct {
+	trader string
+	apply  func()
+}
+
+// stage is a set of queues, each drained by one worker, so jobs sent to the
+// same queue are handled in order.
+type stage struct {
+	queues    []chan func()
+	processed atomic.Uint64
+}
+
+func newStage(workers, queueSize int) *stage {
+	s := &stage{queues: make([]chan func(), workers)}
+	for i := range s.queues {
+		s.queues[i] = make(chan func(), queueSize)
+		go func(queue chan func()) {
+			for job := range queue {
+				job()
+				s.processed.Add(1)
+			}
+		}(s.queues[i])
+	}
+	return s
+}
+
+// submit queues the job on the shard of key, blocking while it is full.
+func (s *stage) submit(key []byte, job func()) {
+	s.queues[s.shard(key)] <- job
+}
+
+func (s *stage) shard(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(len(s.queues)))
+}
+
+func (s *stage) stats() QueueStats {
+	stats := QueueStats{Workers: len(s.queues), Processed: s.processed.Load()}
+	for _, queue := range s.queues {
+		stats.Depth += len(queue)
+		stats.Capacity += cap(queue)
+	}
+	return stats
+}
+
+// pipeline handles the logs of one chain in three stages with bounded queues:
+// logs are decoded by any worker and put back in order, stored by the worker
+// of their transaction, and put back in order again before their task
+// accruals are applied by the worker of the credited trader. Accruals of one
+// user therefore run one at a time in the order of their logs, even across
+// transactions, the removal of a reorged log is handled after the log
+// itself, and a full queue blocks the listener instead of piling up
+// goroutines and database connections.
+//
+// A log stays in flight, holding back the checkpoint of its pool, until its
+// swaps are stored and credited. Logs that fail to be decoded, stored or
+// rolled back are retried after retryDelay, and so are accruals that fail to
+// be applied. The swap of a reorged log is deleted once its accruals are
+// taken back. A log waiting for a retry holds back the accruals of later logs.
+type pipeline struct {
+	chain         *Chain
+	decodeWorkers int
+	decode        chan *decodeJob
+	ordered       chan *decodeJob
+	decoded       atomic.Uint64
+	persist       *stage
+	persisted     chan *decodeJob
+	accrue        *stage
+	inFlight      *pendingLogs
+	rollbacks     *pendingLogs
+	retryDelay    time.Duration
+	retryLock     sync.Mutex
+	retrying      map[logKey]*decodeJob
+	retryingApply atomic.Int32
+}
+
+var (
+	pipelines     = make(map[uint64]*pipeline)
+	pipelinesLock sync.Mutex
+)
+
+// getPipeline returns the pipeline of the chain, starting it on first use.
+func getPipeline(chain *Chain) *pipeline {
+	pipelinesLock.Lock()
+	defer pipelinesLock.Unlock()
+	p, ok := pipelines[chain.ID]
+	if !ok {
+		p = newPipeline(chain, pipelineSetting("decode_workers", defaultPipelineWorkers), pipelineSetting("persist_workers", defaultPipelineWorkers),
+			pipelineSetting("accrue_workers", defaultPipelineWorkers), pipelineSetting("queue_size", defaultPipelineQueueSize))
+		pipelines[chain.ID] = p
+	}
+	return p
+}
+
+func pipelineSetting(key string, fallback int) int {
+	if value := viper.GetInt("pipeline." + key); value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// GetPipelineStats reports the queue depths of the chain's pipeline. ok is
+// false if the chain has not handled any log yet.
+func GetPipelineStats(chainID uint64) (stats PipelineStats, ok bool) {
+	pipelinesLock.Lock()
+	p, ok := pipelines[chainID]
+	pipelinesLock.Unlock()
+	if !ok {
+		return PipelineStats{}, false
+	}
+	return p.stats(), true
+}
+
+// inFlightBlock returns the lowest block of the address with a log still in
+// the chain's pipeline.
+func inFlightBlock(chainID uint64, address common.Address) (uint64, bool) {
+	pipelinesLock.Lock()
+	p, ok := pipelines[chainID]
+	pipelinesLock.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return p.lowestBlock(address)
+}
+
+func newPipeline(chain *Chain, decodeWorkers, persistWorkers, accrueWorkers, queueSize int) *pipeline {
+	p := &pipeline{
+		chain:         chain,
+		decodeWorkers: decodeWorkers,
+		decode:        make(chan *decodeJob, queueSize),
+		ordered:       make(chan *decodeJob, queueSize),
+		persist:       newStage(persistWorkers, queueSize),
+		persisted:     make(chan *decodeJob, queueSize),
+		accrue:        newStage(accrueWorkers, queueSize),
+		inFlight:      newPendingLogs(),
+		rollbacks:     newPendingLogs(),
+		retryDelay:    persistRetryDelay,
+		retrying:      make(map[logKey]*decodeJob),
+	}
+	for i := 0; i < decodeWorkers; i++ {
+		go p.decodeLogs()
+	}
+	go p.sequence()
+	go p.credit()
+	return p
+}
+
+// submit queues a log, or the removal of a reorged one, blocking while the
+// pipeline is full. A log that is still in flight is skipped.
+func (p *pipeline) submit(vLog types.Log) {
+	job := &decodeJob{vLog: vLog, done: make(chan struct{}), stored: make(chan struct{})}
+	if vLog.Removed {
+		close(job.done)
+		p.ordered <- job
+		return
+	}
+	if !p.inFlight.add(vLog) {
+		return
+	}
+	job.pending.Store(1)
+	p.ordered <- job
+	p.decode <- job
+}
+
+func (p *pipeline) decodeLogs() {
+	for job := range p.decode {
+		job.swapInfos, job.err = ParseSwapEvents(p.chain, []types.Log{job.vLog})
+		p.decoded.Add(1)
+		close(job.done)
+	}
+}
+
+// sequence hands decoded logs to the persist stage in the order they were
+// submitted.
+func (p *pipeline) sequence() {
+	for job := range p.ordered {
+		<-job.done
+		p.persisted <- job
+		p.persist.submit(txKey(job.vLog), func() { p.persistLog(job) })
+	}
+}
+
+// credit hands the accruals of stored logs to the accrue stage in the order
+// the logs were submitted, as logs of different transactions are stored by
+// different workers.
+func (p *pipeline) credit() {
+	for job := range p.persisted {
+		<-job.stored
+		for _, c := range job.credits {
+			p.accrue.submit([]byte(c.trader), c.apply)
+		}
+	}
+}
+
+// persistLog stores the swaps of a log and queues their accruals, or rolls
+// back a reorged log. Swaps that fail to be stored are retried alone, and so
+// is a log whose swap from a reorged block is still being rolled back.
+func (p *pipeline) persistLog(job *decodeJob) {
+	if job.vLog.Removed {
+		p.rollbackLog(job)
+		return
+	}
+	if p.rollbacks.containsSwap(job.vLog) {
+		p.retryLater(job)
+		return
+	}
+	if job.err != nil {
+		log.Printf("Failed to parse Swap event: %v", job.err)
+		p.retryLater(job)
+		return
+	}
+
+	var failed []SwapInfo
+	for _, swapInfo := range job.swapInfos {
+		campaigns, accrue, err := insertSwapEvent(p.chain, &swapInfo)
+		if err != nil {
+			log.Print(err)
+			failed = append(failed, swapInfo)
+			continue
+		}
+		if accrue {
+			p.accrueSwap(job, swapInfo, swapAccruals(campaigns, swapInfo.Sender, swapInfo.Recipient, swapInfo.Origin, swapInfo.USD, swapInfo.Timestamp))
+		}
+	}
+	if len(failed) > 0 {
+		job.swapInfos = failed
+		p.retryLater(job)
+		return
+	}
+	close(job.stored)
+	p.release(job)
+}
+
+// rollbackLog reverts the swap of a reorged log and deletes it once its
+// accruals are taken back. A retry of the log that was never stored is
+// dropped.
+func (p *pipeline) rollbackLog(job *decodeJob) {
+	if original := p.takeRetry(job.vLog); original != nil {
+		close(original.stored)
+		p.release(original)
+	}
+	accruals, ok, err := rollbackSwap(p.chain.ID, job.vLog)
+	if err != nil {
+		log.Print(err)
+		p.retryLater(job)
+		return
+	}
+	defer close(job.stored)
+	if !ok || !p.rollbacks.add(job.vLog) {
+		return
+	}
+	if len(accruals) == 0 {
+		p.deleteSwap(job.vLog)
+		return
+	}
+	remaining := new(atomic.Int32)
+	remaining.Store(int32(len(accruals)))
+	for _, a := range accruals {
+		job.credits = append(job.credits, credit{trader: a.trader, apply: func() {
+			p.applyAccrual(a)
+			if remaining.Add(-1) == 0 {
+				p.deleteSwap(job.vLog)
+			}
+		}})
+	}
+}
+
+func (p *pipeline) deleteSwap(vLog types.Log) {
+	err := deleteSwap(p.chain.ID, vLog)
+	if err != nil {
+		log.Print(err)
+		time.AfterFunc(p.retryDelay, func() { p.deleteSwap(vLog) })
+		return
+	}
+	p.rollbacks.remove(vLog)
+}
+
+// applyAccrual applies the accrual, trying again after retryDelay until it
+// succeeds. The worker of the trader waits meanwhile, so the later accruals
+// of the trader are not applied before it.
+func (p *pipeline) applyAccrual(a accrual) {
+	for {
+		err := a.apply()
+		if err == nil {
+			return
+		}
+		log.Print(err)
+		p.retryingApply.Add(1)
+		time.Sleep(p.retryDelay)
+		p.retryingApply.Add(-1)
+	}
+}
+
+// accrueSwap adds the accruals of a stored swap to the credits of its log and
+// marks it accrued once all of them are applied, so a swap stored before a
+// crash is credited when its log is replayed.
+func (p *pipeline) accrueSwap(job *decodeJob, swapInfo SwapInfo, accruals []accrual) {
+	job.pending.Add(1)
+	if len(accruals) == 0 {
+		p.markAccrued(job, swapInfo)
+		return
+	}
+	remaining := new(atomic.Int32)
+	remaining.Store(int32(len(accruals)))
+	for _, a := range accruals {
+		job.credits = append(job.credits, credit{trader: a.trader, apply: func() {
+			p.applyAccrual(a)
+			if remaining.Add(-1) == 0 {
+				p.markAccrued(job, swapInfo)
+			}
+		}})
+	}
+}
+
+func (p *pipeline) markAccrued(job *decodeJob, swapInfo SwapInfo) {
+	err := database.MarkSwapAccrued(p.chain.ID, swapInfo.TxHash, swapInfo.LogIndex)
+	if err != nil {
+		log.Print(err)
+		time.AfterFunc(p.retryDelay, func() { p.markAccrued(job, swapInfo) })
+		return
+	}
+	p.release(job)
+}
+
+// release finishes one part of the work on a log, and takes the log out of
+// flight once none is left.
+func (p *pipeline) release(job *decodeJob) {
+	if job.pending.Add(-1) == 0 {
+		p.inFlight.remove(job.vLog)
+	}
+}
+
+// retryLater queues the log again after retryDelay, keeping it in
+// flight meanwhile.
+func (p *pipeline) retryLater(job *decodeJob) {
+	p.retryLock.Lock()
+	p.retrying[keyOf(job.vLog)] = job
+	p.retryLock.Unlock()
+
+	time.AfterFunc(p.retryDelay, func() {
+		p.persist.submit(txKey(job.vLog), func() {
+			if !p.resumeRetry(job) {
+				return
+			}
+			if job.err != nil {
+				job.swapInfos, job.err = ParseSwapEvents(p.chain, []types.Log{job.vLog})
+			}
+			p.persistLog(job)
+		})
+	})
+}
+
+// takeRetry removes the log from the logs waiting for a retry and returns its
+// job, or nil if it is not waiting.
+func (p *pipeline) takeRetry(vLog types.Log) *decodeJob {
+	p.retryLock.Lock()
+	defer p.retryLock.Unlock()
+	key := keyOf(vLog)
+	job := p.retrying[key]
+	delete(p.retrying, key)
+	return job
+}
+
+// resumeRetry removes the job from the logs waiting for a retry. It is false
+// if the retry was dropped since.
+func (p *pipeline) resumeRetry(job *decodeJob) bool {
+	p.retryLock.Lock()
+	defer p.retryLock.Unlock()
+	key := keyOf(job.vLog)
+	if p.retrying[key] != job {
+		return false
+	}
+	delete(p.retrying, key)
+	return true
+}
+
+// lowestBlock returns the lowest block of the address with a log that is not
+// stored and credited yet, or whose swap is still being rolled back.
+func (p *pipeline) lowestBlock(address common.Address) (uint64, bool) {
+	lowest, ok := p.inFlight.lowestBlock(address)
+	if rolledBack, rolling := p.rollbacks.lowestBlock(address); rolling && (!ok || rolledBack < lowest) {
+		return rolledBack, true
+	}
+	return lowest, ok
+}
+
+func (p *pipeline) stats() PipelineStats {
+	return PipelineStats{
+		Decode: QueueStats{
+			Workers:   p.decodeWorkers,
+			Depth:     len(p.ordered),
+			Capacity:  cap(p.ordered),
+			Processed: p.decoded.Load(),
+		},
+		Persist:  p.persist.stats(),
+		Accrue:   p.accrueStats(),
+		InFlight: p.inFlight.len(),
+		Retrying: p.retryCount(),
+	}
+}
+
+func (p *pipeline) accrueStats() QueueStats {
+	stats := p.accrue.stats()
+	stats.Depth += len(p.persisted)
+	stats.Capacity += cap(p.persisted)
+	return stats
+}
+
+// retryCount returns the number of logs and accruals waiting for a retry.
+func (p *pipeline) retryCount() int {
+	p.retryLock.Lock()
+	defer p.retryLock.Unlock()
+	return len(p.retrying) + int(p.retryingApply.Load())
+}
+
+// txKey shards logs by transaction. Swap senders are mostly routers, so
+// sharding by sender would send nearly every log to one worker, while the
+// logs of a transaction, and the removal of a reorged log, stay together.
+func txKey(vLog types.Log) []byte {
+	return vLog.TxHash.Bytes()
+}
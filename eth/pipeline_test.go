@@ -0,0 +1,336 @@
+package eth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStage(t *testing.T) {
+	s := newStage(3, 100)
+	var lock sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		s.submit([]byte("0xtrader"), func() {
+			defer wg.Done()
+			lock.Lock()
+			order = append(order, i)
+			lock.Unlock()
+		})
+	}
+	wg.Wait()
+
+	for i := range order {
+		assert.Equal(t, i, order[i], "jobs with the same key should run in order")
+	}
+	assert.Eventually(t, func() bool { return s.stats().Processed == 50 }, time.Second, time.Millisecond)
+	assert.Equal(t, QueueStats{Workers: 3, Depth: 0, Capacity: 300, Processed: 50}, s.stats())
+}
+
+func TestPipeline(t *testing.T) {
+	txs := []common.Hash{common.HexToHash("0x1"), common.HexToHash("0x2"), common.HexToHash("0x3")}
+	router := common.HexToHash("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+	pool := common.HexToAddress("0x123")
+
+	// Later logs decode faster, so decoding finishes out of order.
+	patches := gomonkey.ApplyFunc(ParseSwapEvents, func(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
+		time.Sleep(time.Duration(100-logs[0].BlockNumber) * 50 * time.Microsecond)
+		return []SwapInfo{{TxHash: logs[0].TxHash.Hex(), BlockNumber: logs[0].BlockNumber, LogIndex: logs[0].Index}}, nil
+	})
+	defer patches.Reset()
+
+	var lock sync.Mutex
+	stored := make(map[string][]uint64)
+	var rolledBack []uint64
+	patches.ApplyFunc(insertSwapEvent, func(chain *Chain, swapInfo *SwapInfo) ([]database.Campaign, bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		stored[swapInfo.TxHash] = append(stored[swapInfo.TxHash], swapInfo.BlockNumber)
+		return nil, true, nil
+	})
+	patches.ApplyFunc(database.MarkSwapAccrued, func(chainID uint64, txHash string, logIndex uint) error {
+		return nil
+	})
+	patches.ApplyFunc(rollbackSwap, func(chainID uint64, vLog types.Log) ([]accrual, bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Contains(t, stored[vLog.TxHash.Hex()], vLog.BlockNumber, "removal should follow the insert")
+		rolledBack = append(rolledBack, vLog.BlockNumber)
+		return nil, true, nil
+	})
+	patches.ApplyFunc(deleteSwap, func(chainID uint64, vLog types.Log) error {
+		return nil
+	})
+
+	p := newPipeline(testChain(&mockClient{}), 4, 2, 2, 5)
+	var logs []types.Log
+	for i := 0; i < 60; i++ {
+		logs = append(logs, types.Log{
+			Address:     pool,
+			Topics:      []common.Hash{common.HexToHash(swapEventTopicHash), router},
+			BlockNumber: uint64(i),
+			TxHash:      txs[i%len(txs)],
+			Index:       uint(i),
+		})
+	}
+	for _, vLog := range logs {
+		p.submit(vLog)
+	}
+	removed := logs[59]
+	removed.Removed = true
+	p.submit(removed)
+
+	assert.Eventually(t, func() bool { return p.stats().Persist.Processed == 61 }, 5*time.Second, time.Millisecond)
+	for _, tx := range txs {
+		blocks := stored[tx.Hex()]
+		assert.Len(t, blocks, 20)
+		for i := 1; i < len(blocks); i++ {
+			assert.Less(t, blocks[i-1], blocks[i], "swaps of one transaction should be stored in order")
+		}
+	}
+	assert.Equal(t, []uint64{59}, rolledBack)
+
+	stats := p.stats()
+	assert.Equal(t, uint64(60), stats.Decode.Processed)
+	assert.Equal(t, 4, stats.Decode.Workers)
+	assert.Equal(t, 10, stats.Persist.Capacity)
+	assert.Zero(t, stats.InFlight)
+	_, ok := p.lowestBlock(pool)
+	assert.False(t, ok)
+}
+
+func TestPipelineRetry(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	patches := gomonkey.ApplyFunc(ParseSwapEvents, func(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
+		return []SwapInfo{{Sender: "0xtrader", TxHash: logs[0].TxHash.Hex(), LogIndex: logs[0].Index, USD: decimal.NewFromInt(10), Timestamp: 150}}, nil
+	})
+	defer patches.Reset()
+
+	// The swap of log 1 is stored on the third attempt, the one of log 2 never.
+	var lock sync.Mutex
+	attempts := make(map[uint]int)
+	var marked []uint
+	patches.ApplyFunc(insertSwapEvent, func(chain *Chain, swapInfo *SwapInfo) ([]database.Campaign, bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts[swapInfo.LogIndex]++
+		if swapInfo.LogIndex == 2 || attempts[swapInfo.LogIndex] < 3 {
+			return nil, false, errors.New("connection reset")
+		}
+		return []database.Campaign{{CampaignID: 1, Attribution: AttributionSender, StartTime: 100, EndTime: 200}}, true, nil
+	})
+	patches.ApplyFunc(database.MarkSwapAccrued, func(chainID uint64, txHash string, logIndex uint) error {
+		lock.Lock()
+		defer lock.Unlock()
+		marked = append(marked, logIndex)
+		return nil
+	})
+	patches.ApplyFunc(rollbackSwap, func(chainID uint64, vLog types.Log) ([]accrual, bool, error) {
+		return nil, false, nil
+	})
+	patches.ApplyFunc(database.IsAddressLabeled, func(chainID uint64, address string, labels []string) (bool, error) {
+		return false, nil
+	})
+	patches.ApplyFunc(database.GetOrCreateUserID, func(address string) (int, error) {
+		return 1, nil
+	})
+	accrued := make(chan struct{})
+	patches.ApplyFunc(accrueTasks, func(campaignID, userID int, usd decimal.Decimal, t int64, applied map[int]bool) error {
+		<-accrued
+		return nil
+	})
+	count := func(logIndex uint) int {
+		lock.Lock()
+		defer lock.Unlock()
+		return attempts[logIndex]
+	}
+
+	p := newPipeline(testChain(&mockClient{}), 1, 1, 1, 5)
+	p.retryDelay = time.Millisecond
+	stored := types.Log{Address: pool, BlockNumber: 10, TxHash: common.HexToHash("0x1"), Index: 1}
+	reorged := types.Log{Address: pool, BlockNumber: 11, TxHash: common.HexToHash("0x2"), Index: 2}
+	p.submit(stored)
+	p.submit(stored)
+	p.submit(reorged)
+
+	// The stored swap holds the checkpoint back until it is credited.
+	assert.Eventually(t, func() bool { return count(1) == 3 }, time.Second, time.Millisecond)
+	lowest, ok := p.lowestBlock(pool)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), lowest)
+	close(accrued)
+	assert.Eventually(t, func() bool {
+		lowest, ok := p.lowestBlock(pool)
+		return ok && lowest == 11
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 3, count(1), "a log in flight should not be submitted twice")
+	lock.Lock()
+	assert.Equal(t, []uint{1}, marked)
+	lock.Unlock()
+
+	// The removal of the failing log drops its retries.
+	assert.Eventually(t, func() bool { return count(2) >= 2 }, time.Second, time.Millisecond)
+	removed := reorged
+	removed.Removed = true
+	p.submit(removed)
+	assert.Eventually(t, func() bool { return p.stats().InFlight == 0 }, time.Second, time.Millisecond)
+	attemptsAfterRemoval := count(2)
+	time.Sleep(20 * time.Millisecond)
+	assert.LessOrEqual(t, count(2), attemptsAfterRemoval+1, "a removed log should not be retried")
+	assert.Zero(t, p.stats().Retrying)
+}
+
+func TestPipelineTraderOrder(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	first := types.Log{Address: pool, BlockNumber: 10, TxHash: common.HexToHash("0x1"), Index: 1}
+	second := types.Log{Address: pool, BlockNumber: 11, TxHash: common.HexToHash("0x2"), Index: 2}
+	p := newPipeline(testChain(&mockClient{}), 1, 2, 2, 5)
+	assert.NotEqual(t, p.persist.shard(txKey(first)), p.persist.shard(txKey(second)), "the transactions should be stored by different workers")
+
+	patches := gomonkey.ApplyFunc(ParseSwapEvents, func(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
+		return []SwapInfo{{Sender: "0xtrader", TxHash: logs[0].TxHash.Hex(), LogIndex: logs[0].Index, USD: decimal.NewFromInt(int64(logs[0].Index)), Timestamp: 150}}, nil
+	})
+	defer patches.Reset()
+
+	// The swap of the second transaction is stored first.
+	secondStored := make(chan struct{})
+	patches.ApplyFunc(insertSwapEvent, func(chain *Chain, swapInfo *SwapInfo) ([]database.Campaign, bool, error) {
+		if swapInfo.LogIndex == 1 {
+			<-secondStored
+		} else {
+			close(secondStored)
+		}
+		return []database.Campaign{{CampaignID: 1, Attribution: AttributionSender, StartTime: 100, EndTime: 200}}, true, nil
+	})
+	patches.ApplyFunc(database.MarkSwapAccrued, func(chainID uint64, txHash string, logIndex uint) error {
+		return nil
+	})
+	patches.ApplyFunc(database.IsAddressLabeled, func(chainID uint64, address string, labels []string) (bool, error) {
+		return false, nil
+	})
+	patches.ApplyFunc(database.GetOrCreateUserID, func(address string) (int, error) {
+		return 1, nil
+	})
+	var lock sync.Mutex
+	var accrued []string
+	patches.ApplyFunc(accrueTasks, func(campaignID, userID int, usd decimal.Decimal, t int64, applied map[int]bool) error {
+		lock.Lock()
+		defer lock.Unlock()
+		accrued = append(accrued, usd.String())
+		return nil
+	})
+
+	p.submit(first)
+	p.submit(second)
+	assert.Eventually(t, func() bool { return p.stats().InFlight == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"1", "2"}, accrued, "swaps of one trader should be credited in the order of their logs")
+}
+
+func TestPipelineRollbackRetry(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	reorged := types.Log{Address: pool, BlockNumber: 10, BlockHash: common.HexToHash("0xa"), TxHash: common.HexToHash("0x1"), Index: 1, Removed: true}
+	reincluded := types.Log{Address: pool, BlockNumber: 11, BlockHash: common.HexToHash("0xb"), TxHash: common.HexToHash("0x1"), Index: 1}
+
+	patches := gomonkey.ApplyFunc(rollbackSwap, func(chainID uint64, vLog types.Log) ([]accrual, bool, error) {
+		return []accrual{{campaignID: 1, trader: "0xtrader", amount: decimal.NewFromInt(10), time: 150, rollback: true, applied: map[int]bool{}}}, true, nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(database.IsAddressLabeled, func(chainID uint64, address string, labels []string) (bool, error) {
+		return false, nil
+	})
+	patches.ApplyFunc(database.GetUserByAddress, func(address string) (*database.User, error) {
+		return &database.User{UserID: 1, Address: address}, nil
+	})
+	patches.ApplyFunc(ParseSwapEvents, func(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
+		return []SwapInfo{{TxHash: logs[0].TxHash.Hex(), LogIndex: logs[0].Index, BlockHash: logs[0].BlockHash.Hex()}}, nil
+	})
+
+	// Task 1 is taken back on the first attempt, task 2 on the third.
+	var lock sync.Mutex
+	var events []string
+	rollbacks := make(map[int]int)
+	patches.ApplyFunc(rollbackTasks, func(campaignID, userID int, usd decimal.Decimal, t int64, applied map[int]bool) error {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, taskID := range []int{1, 2} {
+			if applied[taskID] {
+				continue
+			}
+			rollbacks[taskID]++
+			if taskID == 1 || rollbacks[taskID] == 3 {
+				applied[taskID] = true
+			}
+		}
+		if !applied[2] {
+			return errors.New("connection reset")
+		}
+		events = append(events, "rolled back")
+		return nil
+	})
+	patches.ApplyFunc(deleteSwap, func(chainID uint64, vLog types.Log) error {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, "deleted")
+		return nil
+	})
+	patches.ApplyFunc(insertSwapEvent, func(chain *Chain, swapInfo *SwapInfo) ([]database.Campaign, bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, "stored "+swapInfo.BlockHash)
+		return nil, true, nil
+	})
+	patches.ApplyFunc(database.MarkSwapAccrued, func(chainID uint64, txHash string, logIndex uint) error {
+		return nil
+	})
+
+	p := newPipeline(testChain(&mockClient{}), 1, 1, 1, 5)
+	p.retryDelay = time.Millisecond
+	p.submit(reorged)
+	p.submit(reincluded)
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(events) == 3
+	}, time.Second, time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"rolled back", "deleted", "stored " + reincluded.BlockHash.Hex()}, events, "the swap should be deleted after its rollback and stored again after that")
+	assert.Equal(t, map[int]int{1: 1, 2: 3}, rollbacks, "only the failed tasks should be taken back again")
+	_, ok := p.lowestBlock(pool)
+	assert.False(t, ok)
+}
+
+func Test_txKey(t *testing.T) {
+	router := common.HexToHash("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+	first := types.Log{Topics: []common.Hash{common.HexToHash(swapEventTopicHash), router}, TxHash: common.HexToHash("0x1")}
+	second := types.Log{Topics: []common.Hash{common.HexToHash(swapEventTopicHash), router}, TxHash: common.HexToHash("0x2")}
+	removed := first
+	removed.Removed = true
+	assert.NotEqual(t, txKey(first), txKey(second), "swaps through one router should be spread")
+	assert.Equal(t, txKey(first), txKey(removed))
+}
+
+func TestSwapAccruals(t *testing.T) {
+	campaigns := []database.Campaign{
+		{CampaignID: 1, Attribution: AttributionSender, StartTime: 100, EndTime: 200},
+		{CampaignID: 2, Attribution: AttributionOrigin, StartTime: 100, EndTime: 200},
+		{CampaignID: 3, Attribution: AttributionSender, StartTime: 300, EndTime: 400},
+		{CampaignID: 4, Attribution: AttributionRecipient, StartTime: 100, EndTime: 200},
+	}
+	accruals := swapAccruals(campaigns, "0xrouter", "", "0xtrader", decimal.NewFromInt(10), 150)
+	assert.Equal(t, []accrual{
+		{campaignID: 1, trader: "0xrouter", amount: decimal.NewFromInt(10), time: 150, applied: map[int]bool{}},
+		{campaignID: 2, trader: "0xtrader", amount: decimal.NewFromInt(10), time: 150, applied: map[int]bool{}},
+	}, accruals)
+}
@@ -0,0 +1,84 @@
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// poll follows the Swap events of the campaign pools without a subscription.
+// Every poll interval it asks for the latest block number and fetches the
+// logs of every pool from its last processed block up to the latest block
+// with enough confirmations, so reorged logs never reach the pipeline.
+func (l *listener) poll() {
+	cursors := make(map[common.Address]uint64)
+	ticker := time.NewTicker(l.chain.PollInterval)
+	defer ticker.Stop()
+	fmt.Printf("Polling for Swap events on %s every %s...\n", l.chain.Name, l.chain.PollInterval)
+	for {
+		addrList := GetAddresses(l.chain.ID)
+		if len(addrList) == 0 {
+			fmt.Printf("No addresses to poll on %s, waiting for changes...\n", l.chain.Name)
+			<-GetNotifyChannel(l.chain.ID)
+			continue
+		}
+
+		err := l.pollAddresses(addrList, cursors)
+		if err != nil {
+			log.Printf("Failed to poll logs on %s: %v", l.chain.Name, err)
+		}
+
+		select {
+		case <-GetNotifyChannel(l.chain.ID):
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollAddresses submits the logs of every pool from the block after its
+// cursor up to the safe head, then moves the cursors and checkpoints. Pools
+// seen for the first time resume from their checkpoint, or start from the
+// safe head without one.
+func (l *listener) pollAddresses(addrList []common.Address, cursors map[common.Address]uint64) error {
+	head, err := l.chain.Client.BlockNumber(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get latest block number: %w", err)
+	}
+	safe := safeBlock(head, l.confirmations)
+
+	for _, addr := range addrList {
+		cursor, ok := cursors[addr]
+		if !ok {
+			lastBlock, err := database.GetIngestionCheckpoint(l.chain.ID, addr.Hex())
+			if err == sql.ErrNoRows {
+				lastBlock = safe
+			} else if err != nil {
+				return fmt.Errorf("failed to get ingestion checkpoint: %w", err)
+			}
+			cursor = lastBlock
+			cursors[addr] = cursor
+		}
+
+		var err error
+		if cursor < safe {
+			err = filterSwapLogs(l.chain, addr, cursor+1, safe, func(logs []types.Log, end uint64) error {
+				for _, vLog := range logs {
+					l.pipeline.submit(vLog)
+				}
+				cursors[addr] = end
+				return nil
+			})
+		}
+		l.saveCheckpoint(addr, cursors[addr])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollAddresses(t *testing.T) {
+	resumed := common.HexToAddress("0x1")
+	added := common.HexToAddress("0x2")
+
+	checkpoints := map[string]uint64{resumed.Hex(): 90}
+	patches := gomonkey.ApplyFunc(database.GetIngestionCheckpoint, func(chainID uint64, poolAddress string) (uint64, error) {
+		block, ok := checkpoints[poolAddress]
+		if !ok {
+			return 0, sql.ErrNoRows
+		}
+		return block, nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(database.UpsertIngestionCheckpoint, func(chainID uint64, poolAddress string, lastBlock uint64) error {
+		checkpoints[poolAddress] = lastBlock
+		return nil
+	})
+
+	var lock sync.Mutex
+	var stored []uint64
+	patches.ApplyFunc(ParseSwapEvents, func(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
+		return []SwapInfo{{BlockNumber: logs[0].BlockNumber}}, nil
+	})
+	patches.ApplyFunc(insertSwapEvent, func(chain *Chain, swapInfo *SwapInfo) ([]database.Campaign, bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		stored = append(stored, swapInfo.BlockNumber)
+		return nil, false, nil
+	})
+
+	head := uint64(100)
+	var queries [][2]uint64
+	client := &mockClient{
+		headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+			return &types.Header{Number: new(big.Int).SetUint64(head)}, nil
+		},
+		filterLogs: func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+			queries = append(queries, [2]uint64{query.FromBlock.Uint64(), query.ToBlock.Uint64()})
+			return []types.Log{{
+				Address:     query.Addresses[0],
+				Topics:      []common.Hash{common.HexToHash(swapEventTopicHash)},
+				BlockNumber: query.ToBlock.Uint64(),
+				BlockHash:   common.BigToHash(query.ToBlock),
+				TxHash:      common.BytesToHash(query.Addresses[0].Bytes()),
+			}}, nil
+		},
+	}
+	chain := testChain(client)
+	l := &listener{chain: chain, pending: newPendingLogs(), pipeline: newPipeline(chain, 1, 1, 1, 10), confirmations: 5}
+	cursors := make(map[common.Address]uint64)
+
+	// The resumed pool catches up from its checkpoint, the new pool starts
+	// from the safe head.
+	assert.NoError(t, l.pollAddresses([]common.Address{resumed, added}, cursors))
+	assert.Equal(t, [][2]uint64{{91, 95}}, queries)
+	assert.Equal(t, map[common.Address]uint64{resumed: 95, added: 95}, cursors)
+
+	head = 103
+	assert.NoError(t, l.pollAddresses([]common.Address{resumed, added}, cursors))
+	assert.Equal(t, [][2]uint64{{91, 95}, {96, 98}, {96, 98}}, queries)
+	assert.Eventually(t, func() bool { return l.pipeline.stats().Persist.Processed == 3 }, time.Second, time.Millisecond)
+	assert.ElementsMatch(t, []uint64{95, 98, 98}, stored)
+
+	// Nothing new is fetched until a block gets enough confirmations, and
+	// checkpoints catch up once the pipeline is done with the logs.
+	assert.NoError(t, l.pollAddresses([]common.Address{resumed, added}, cursors))
+	assert.Len(t, queries, 3)
+	assert.Equal(t, map[string]uint64{resumed.Hex(): 98, added.Hex(): 98}, checkpoints)
+}
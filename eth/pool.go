@@ -0,0 +1,405 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	errorRateWeight    = 0.2
+	maxErrorRate       = 0.5
+	defaultMaxHeadLag  = 3
+	healthCheckTimeout = 10 * time.Second
+)
+
+var errNoHealthyEndpoint = errors.New("no healthy rpc endpoint")
+
+type endpoint struct {
+	name   string
+	client ChainClient
+
+	lock      sync.Mutex
+	head      uint64
+	latency   time.Duration
+	errorRate float64
+	healthy   bool
+}
+
+type EndpointStatus struct {
+	Name      string        `json:"name"`
+	Head      uint64        `json:"head"`
+	Latency   time.Duration `json:"latency"`
+	ErrorRate float64       `json:"errorRate"`
+	Healthy   bool          `json:"healthy"`
+}
+
+func (e *endpoint) record(latency time.Duration, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	sample := 0.0
+	if err != nil {
+		sample = 1
+	} else if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = (e.latency*4 + latency) / 5
+	}
+	e.errorRate = e.errorRate*(1-errorRateWeight) + sample*errorRateWeight
+	e.healthy = e.errorRate < maxErrorRate
+}
+
+func (e *endpoint) status() EndpointStatus {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return EndpointStatus{Name: e.name, Head: e.head, Latency: e.latency, ErrorRate: e.errorRate, Healthy: e.healthy}
+}
+
+// Pool is a ChainClient that spreads calls over several endpoints, preferring
+// healthy, up-to-date and fast ones and failing over when a call errors.
+type Pool struct {
+	endpoints  []*endpoint
+	maxHeadLag uint64
+	quit       chan struct{}
+	closeOnce  sync.Once
+}
+
+func NewPool(clients map[string]ChainClient, healthCheckInterval time.Duration) *Pool {
+	pool := &Pool{maxHeadLag: defaultMaxHeadLag, quit: make(chan struct{})}
+	for name, client := range clients {
+		pool.endpoints = append(pool.endpoints, &endpoint{name: name, client: client, healthy: true})
+	}
+	sort.Slice(pool.endpoints, func(i, j int) bool { return pool.endpoints[i].name < pool.endpoints[j].name })
+
+	if healthCheckInterval > 0 {
+		go pool.monitor(healthCheckInterval)
+	}
+	return pool
+}
+
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() { close(p.quit) })
+}
+
+func (p *Pool) Status() []EndpointStatus {
+	var statuses []EndpointStatus
+	for _, e := range p.endpoints {
+		statuses = append(statuses, e.status())
+	}
+	return statuses
+}
+
+func (p *Pool) monitor(interval time.Duration) {
+	p.checkHealth()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-ticker.C:
+			p.checkHealth()
+		}
+	}
+}
+
+func (p *Pool) checkHealth() {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			header, err := e.client.HeaderByNumber(ctx, nil)
+			e.record(time.Since(start), err)
+			if err != nil {
+				log.Printf("RPC endpoint %s health check failed: %v", e.name, err)
+				e.lock.Lock()
+				e.healthy = false
+				e.lock.Unlock()
+				return
+			}
+			e.lock.Lock()
+			e.head = header.Number.Uint64()
+			e.lock.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+// candidates orders endpoints by preference: healthy endpoints close to the
+// best known head first, fastest first, then everything else as a last resort.
+func (p *Pool) candidates() []*endpoint {
+	statuses := make(map[*endpoint]EndpointStatus, len(p.endpoints))
+	var bestHead uint64
+	for _, e := range p.endpoints {
+		status := e.status()
+		statuses[e] = status
+		if status.Healthy && status.Head > bestHead {
+			bestHead = status.Head
+		}
+	}
+
+	rank := func(e *endpoint) int {
+		status := statuses[e]
+		switch {
+		case status.Healthy && status.Head+p.maxHeadLag >= bestHead:
+			return 0
+		case status.Healthy:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	ordered := make([]*endpoint, len(p.endpoints))
+	copy(ordered, p.endpoints)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := rank(ordered[i]), rank(ordered[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if statuses[ordered[i]].ErrorRate != statuses[ordered[j]].ErrorRate {
+			return statuses[ordered[i]].ErrorRate < statuses[ordered[j]].ErrorRate
+		}
+		return statuses[ordered[i]].Latency < statuses[ordered[j]].Latency
+	})
+	return ordered
+}
+
+func (p *Pool) call(ctx context.Context, fn func(ChainClient) error) error {
+	err := errNoHealthyEndpoint
+	for _, e := range p.candidates() {
+		start := time.Now()
+		err = fn(e.client)
+		e.record(time.Since(start), err)
+		if err == nil || errors.Is(err, ethereum.NotFound) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Printf("RPC endpoint %s failed, trying next: %v", e.name, err)
+	}
+	return err
+}
+
+func (p *Pool) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		logs, err = client.FilterLogs(ctx, q)
+		return err
+	})
+	return logs, err
+}
+
+func (p *Pool) BlockNumber(ctx context.Context) (uint64, error) {
+	var number uint64
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		number, err = client.BlockNumber(ctx)
+		return err
+	})
+	return number, err
+}
+
+func (p *Pool) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	var block *types.Block
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		block, err = client.BlockByNumber(ctx, number)
+		return err
+	})
+	return block, err
+}
+
+func (p *Pool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var header *types.Header
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+func (p *Pool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var output []byte
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		output, err = client.CallContract(ctx, msg, blockNumber)
+		return err
+	})
+	return output, err
+}
+
+func (p *Pool) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	var tx *types.Transaction
+	var isPending bool
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		tx, isPending, err = client.TransactionByHash(ctx, hash)
+		return err
+	})
+	return tx, isPending, err
+}
+
+func (p *Pool) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	var sender common.Address
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		sender, err = client.TransactionSender(ctx, tx, block, index)
+		return err
+	})
+	return sender, err
+}
+
+// SubscribeNewHead subscribes on the preferred endpoint that accepts the
+// subscription. Callers resubscribe when it drops.
+func (p *Pool) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	var sub ethereum.Subscription
+	err := p.call(ctx, func(client ChainClient) error {
+		var err error
+		sub, err = client.SubscribeNewHead(ctx, ch)
+		return err
+	})
+	return sub, err
+}
+
+// SubscribeFilterLogs subscribes on the preferred endpoint. When that
+// subscription drops, it resubscribes on the next endpoint and replays the
+// logs emitted since the last block it delivered.
+func (p *Pool) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub := &poolSubscription{
+		pool:  p,
+		query: q,
+		out:   ch,
+		unsub: make(chan struct{}),
+		err:   make(chan error, 1),
+	}
+	inner, innerLogs, e, err := sub.subscribe(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	sub.lastBlock = e.status().Head
+	go sub.run(ctx, inner, innerLogs, e)
+	return sub, nil
+}
+
+type poolSubscription struct {
+	pool      *Pool
+	query     ethereum.FilterQuery
+	out       chan<- types.Log
+	lastBlock uint64
+
+	unsub     chan struct{}
+	unsubOnce sync.Once
+	err       chan error
+}
+
+func (s *poolSubscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *poolSubscription) Unsubscribe() {
+	s.unsubOnce.Do(func() { close(s.unsub) })
+}
+
+func (s *poolSubscription) subscribe(ctx context.Context, skip *endpoint) (ethereum.Subscription, chan types.Log, *endpoint, error) {
+	err := errNoHealthyEndpoint
+	for _, e := range s.pool.candidates() {
+		if e == skip {
+			continue
+		}
+		innerLogs := make(chan types.Log)
+		start := time.Now()
+		var inner ethereum.Subscription
+		inner, err = e.client.SubscribeFilterLogs(ctx, s.query, innerLogs)
+		e.record(time.Since(start), err)
+		if err == nil {
+			return inner, innerLogs, e, nil
+		}
+		log.Printf("RPC endpoint %s failed to subscribe, trying next: %v", e.name, err)
+	}
+	return nil, nil, nil, err
+}
+
+func (s *poolSubscription) run(ctx context.Context, inner ethereum.Subscription, innerLogs chan types.Log, current *endpoint) {
+	defer close(s.err)
+	for {
+		select {
+		case <-s.unsub:
+			inner.Unsubscribe()
+			return
+		case vLog := <-innerLogs:
+			if !s.forward(vLog) {
+				inner.Unsubscribe()
+				return
+			}
+		case err := <-inner.Err():
+			inner.Unsubscribe()
+			if err == nil {
+				return
+			}
+			current.record(0, err)
+			log.Printf("RPC endpoint %s subscription dropped, failing over: %v", current.name, err)
+
+			inner, innerLogs, current, err = s.subscribe(ctx, current)
+			if err == nil {
+				err = s.replay(ctx, current)
+			}
+			if err != nil {
+				if inner != nil {
+					inner.Unsubscribe()
+				}
+				s.err <- fmt.Errorf("failover failed: %w", err)
+				return
+			}
+		}
+	}
+}
+
+func (s *poolSubscription) replay(ctx context.Context, e *endpoint) error {
+	if s.lastBlock == 0 {
+		return nil
+	}
+	query := s.query
+	query.FromBlock = new(big.Int).SetUint64(s.lastBlock)
+	query.ToBlock = nil
+	logs, err := e.client.FilterLogs(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, vLog := range logs {
+		if !s.forward(vLog) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *poolSubscription) forward(vLog types.Log) bool {
+	select {
+	case s.out <- vLog:
+		if vLog.BlockNumber > s.lastBlock {
+			s.lastBlock = vLog.BlockNumber
+		}
+		return true
+	case <-s.unsub:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func headerClient(head int64, err error) *mockClient {
+	return &mockClient{
+		headerByNumber: func(ctx context.Context, number *big.Int) (*types.Header, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &types.Header{Number: big.NewInt(head)}, nil
+		},
+	}
+}
+
+func TestPoolCandidates(t *testing.T) {
+	pool := NewPool(map[string]ChainClient{
+		"a-lagging": headerClient(90, nil),
+		"b-down":    headerClient(0, errors.New("connection refused")),
+		"c-synced":  headerClient(100, nil),
+	}, 0)
+	pool.checkHealth()
+
+	var names []string
+	for _, e := range pool.candidates() {
+		names = append(names, e.name)
+	}
+	assert.Equal(t, []string{"c-synced", "a-lagging", "b-down"}, names)
+}
+
+func TestPoolFailover(t *testing.T) {
+	mockLogs := []types.Log{{BlockNumber: 1}}
+	primary := &mockClient{
+		filterLogs: func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+			return nil, errors.New("503 service unavailable")
+		},
+	}
+	secondary := &mockClient{
+		filterLogs: func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+			return mockLogs, nil
+		},
+	}
+	pool := NewPool(map[string]ChainClient{"a": primary, "b": secondary}, 0)
+
+	logs, err := pool.FilterLogs(context.Background(), ethereum.FilterQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, mockLogs, logs)
+
+	statuses := pool.Status()
+	assert.Greater(t, statuses[0].ErrorRate, 0.0)
+	assert.Equal(t, 0.0, statuses[1].ErrorRate)
+}
+
+func TestPoolSubscriptionFailover(t *testing.T) {
+	pool := common.HexToAddress("0x123")
+	primarySub := newMockSubscription()
+	primary := headerClient(10, nil)
+	primary.subscribeFilterLogs = func(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+		go func() {
+			ch <- types.Log{Address: pool, BlockNumber: 11}
+			primarySub.err <- errors.New("websocket closed")
+		}()
+		return primarySub, nil
+	}
+
+	secondarySub := newMockSubscription()
+	secondary := headerClient(5, nil)
+	secondary.subscribeFilterLogs = func(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+		go func() { ch <- types.Log{Address: pool, BlockNumber: 13} }()
+		return secondarySub, nil
+	}
+	secondary.filterLogs = func(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+		assert.Equal(t, uint64(11), query.FromBlock.Uint64())
+		return []types.Log{{Address: pool, BlockNumber: 12}}, nil
+	}
+
+	rpcPool := NewPool(map[string]ChainClient{"a": primary, "b": secondary}, 0)
+	rpcPool.checkHealth()
+
+	logs := make(chan types.Log)
+	sub, err := rpcPool.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{}, logs)
+	assert.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	var blocks []uint64
+	for len(blocks) < 3 {
+		select {
+		case vLog := <-logs:
+			blocks = append(blocks, vLog.BlockNumber)
+		case err := <-sub.Err():
+			t.Fatalf("unexpected subscription error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out, received blocks %v", blocks)
+		}
+	}
+	assert.ElementsMatch(t, []uint64{11, 12, 13}, blocks)
+}
@@ -0,0 +1,123 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	ProtocolUniswapV2 = "uniswap_v2"
+	ProtocolUniswapV3 = "uniswap_v3"
+)
+
+const (
+	uniswapV3SwapEventABI = `[{
+		"anonymous": false,
+		"inputs": [
+		  {"indexed": true, "internalType": "address", "name": "sender", "type": "address"},
+		  {"indexed": true, "internalType": "address", "name": "recipient", "type": "address"},
+		  {"indexed": false, "internalType": "int256", "name": "amount0", "type": "int256"},
+		  {"indexed": false, "internalType": "int256", "name": "amount1", "type": "int256"},
+		  {"indexed": false, "internalType": "uint160", "name": "sqrtPriceX96", "type": "uint160"},
+		  {"indexed": false, "internalType": "uint128", "name": "liquidity", "type": "uint128"},
+		  {"indexed": false, "internalType": "int24", "name": "tick", "type": "int24"}
+		],
+		"name": "Swap",
+		"type": "event"
+	}]`
+	uniswapV3SwapEventTopicHash = "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67"
+)
+
+var ErrUnknownProtocol = errors.New("unknown pool protocol")
+
+// protocolProbes are view methods only pools of the given protocol implement,
+// used to check a pool is registered with the right protocol.
+var protocolProbes = map[string]string{
+	ProtocolUniswapV2: "getReserves",
+	ProtocolUniswapV3: "fee",
+}
+
+type uniswapV3SwapEvent struct {
+	Amount0      *big.Int
+	Amount1      *big.Int
+	SqrtPriceX96 *big.Int
+	Liquidity    *big.Int
+	Tick         *big.Int
+}
+
+// swapDecoder decodes the Swap event of one pool protocol into the amounts
+// sent into and taken out of the pool.
+type swapDecoder struct {
+	protocol string
+	decode   func(data []byte) (SwapEvent, error)
+}
+
+// NormalizeProtocol returns the protocol name for a campaign, defaulting to
+// Uniswap V2 when none is given.
+func NormalizeProtocol(protocol string) (string, error) {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol == "" {
+		return ProtocolUniswapV2, nil
+	}
+	if _, ok := protocolProbes[protocol]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownProtocol, protocol)
+	}
+	return protocol, nil
+}
+
+// swapTopics returns the Swap event topics of every supported protocol.
+func swapTopics() []common.Hash {
+	return []common.Hash{common.HexToHash(swapEventTopicHash), common.HexToHash(uniswapV3SwapEventTopicHash)}
+}
+
+// newSwapDecoders returns the decoder of every supported protocol keyed by
+// the topic of its Swap event.
+func newSwapDecoders() (map[common.Hash]swapDecoder, error) {
+	v2ABI, err := abi.JSON(strings.NewReader(swapEventABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+	}
+	v3ABI, err := abi.JSON(strings.NewReader(uniswapV3SwapEventABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+	}
+
+	return map[common.Hash]swapDecoder{
+		common.HexToHash(swapEventTopicHash): {
+			protocol: ProtocolUniswapV2,
+			decode: func(data []byte) (SwapEvent, error) {
+				var swapEvent SwapEvent
+				err := v2ABI.UnpackIntoInterface(&swapEvent, "Swap", data)
+				return swapEvent, err
+			},
+		},
+		common.HexToHash(uniswapV3SwapEventTopicHash): {
+			protocol: ProtocolUniswapV3,
+			decode: func(data []byte) (SwapEvent, error) {
+				var v3Event uniswapV3SwapEvent
+				err := v3ABI.UnpackIntoInterface(&v3Event, "Swap", data)
+				if err != nil {
+					return SwapEvent{}, err
+				}
+				var swapEvent SwapEvent
+				swapEvent.Amount0In, swapEvent.Amount0Out = splitSignedAmount(v3Event.Amount0)
+				swapEvent.Amount1In, swapEvent.Amount1Out = splitSignedAmount(v3Event.Amount1)
+				return swapEvent, nil
+			},
+		},
+	}, nil
+}
+
+// splitSignedAmount converts a V3 pool balance delta, positive when tokens
+// are sent into the pool, into V2 style in and out amounts.
+func splitSignedAmount(amount *big.Int) (in *big.Int, out *big.Int) {
+	if amount.Sign() > 0 {
+		return new(big.Int).Set(amount), new(big.Int)
+	}
+	return new(big.Int), new(big.Int).Neg(amount)
+}
@@ -0,0 +1,43 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Default", protocol: "", want: ProtocolUniswapV2},
+		{name: "V2", protocol: "uniswap_v2", want: ProtocolUniswapV2},
+		{name: "V3", protocol: " Uniswap_V3 ", want: ProtocolUniswapV3},
+		{name: "Unknown", protocol: "curve", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeProtocol(tt.protocol)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownProtocol)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_splitSignedAmount(t *testing.T) {
+	in, out := splitSignedAmount(big.NewInt(-5))
+	assert.Equal(t, "0", in.String())
+	assert.Equal(t, "5", out.String())
+
+	in, out = splitSignedAmount(big.NewInt(7))
+	assert.Equal(t, "7", in.String())
+	assert.Equal(t, "0", out.String())
+}
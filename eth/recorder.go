@@ -0,0 +1,209 @@
+package eth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var ErrNoRecording = errors.New("no recorded response")
+
+// rpcCall is one recorded JSON-RPC call, a line of a fixture file.
+type rpcCall struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+type rpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// rpcRecorder is an http.RoundTripper for JSON-RPC over HTTP. In record mode
+// it passes calls on to the node and appends every call with its response
+// to a fixture file. In replay mode it answers from a fixture file without
+// any network access: calls with the same method and params get the
+// recorded responses in order, the last one repeating once they run out.
+type rpcRecorder struct {
+	transport http.RoundTripper
+
+	lock     sync.Mutex
+	fixture  *os.File
+	recorded map[string][]rpcCall
+}
+
+// newRPCRecorder records to the record path, truncating it, or replays the
+// replay path.
+func newRPCRecorder(record, replay string) (*rpcRecorder, error) {
+	if record != "" && replay != "" {
+		return nil, errors.New("record and replay cannot be used together")
+	}
+	if record != "" {
+		fixture, err := os.Create(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rpc fixture: %w", err)
+		}
+		return &rpcRecorder{transport: http.DefaultTransport, fixture: fixture}, nil
+	}
+
+	fixture, err := os.Open(replay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rpc fixture: %w", err)
+	}
+	defer fixture.Close()
+	recorded := make(map[string][]rpcCall)
+	scanner := bufio.NewScanner(fixture)
+	scanner.Buffer(nil, 256<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var call rpcCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("failed to parse rpc fixture %s: %w", replay, err)
+		}
+		key := callKey(call.Method, call.Params)
+		recorded[key] = append(recorded[key], call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rpc fixture %s: %w", replay, err)
+	}
+	return &rpcRecorder{recorded: recorded}, nil
+}
+
+func (r *rpcRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	requests, batch, err := decodeRPCMessages(body)
+	if err != nil {
+		return nil, err
+	}
+	if r.recorded != nil {
+		return r.replay(req, requests, batch)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if resp.StatusCode == http.StatusOK {
+		err = r.record(requests, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (r *rpcRecorder) record(requests []rpcMessage, data []byte) error {
+	responses, _, err := decodeRPCMessages(data)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]rpcMessage, len(responses))
+	for _, response := range responses {
+		byID[string(response.ID)] = response
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, request := range requests {
+		response, ok := byID[string(request.ID)]
+		if !ok {
+			continue
+		}
+		line, err := json.Marshal(rpcCall{Method: request.Method, Params: request.Params, Result: response.Result, Error: response.Error})
+		if err != nil {
+			return err
+		}
+		_, err = r.fixture.Write(append(line, '\n'))
+		if err != nil {
+			return fmt.Errorf("failed to write rpc fixture: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *rpcRecorder) replay(req *http.Request, requests []rpcMessage, batch bool) (*http.Response, error) {
+	r.lock.Lock()
+	responses := make([]rpcMessage, 0, len(requests))
+	for _, request := range requests {
+		key := callKey(request.Method, request.Params)
+		calls := r.recorded[key]
+		if len(calls) == 0 {
+			r.lock.Unlock()
+			return nil, fmt.Errorf("%w for %s %s", ErrNoRecording, request.Method, request.Params)
+		}
+		if len(calls) > 1 {
+			r.recorded[key] = calls[1:]
+		}
+		responses = append(responses, rpcMessage{Version: "2.0", ID: request.ID, Result: calls[0].Result, Error: calls[0].Error})
+	}
+	r.lock.Unlock()
+
+	var data []byte
+	var err error
+	if batch {
+		data, err = json.Marshal(responses)
+	} else {
+		data, err = json.Marshal(responses[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// decodeRPCMessages decodes a single JSON-RPC message or a batch of them.
+func decodeRPCMessages(data []byte) ([]rpcMessage, bool, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var messages []rpcMessage
+		err := json.Unmarshal(data, &messages)
+		return messages, true, err
+	}
+	var message rpcMessage
+	err := json.Unmarshal(data, &message)
+	return []rpcMessage{message}, false, err
+}
+
+// callKey identifies a call by its method and params, ignoring formatting.
+func callKey(method string, params json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, params); err != nil {
+		return method + string(params)
+	}
+	return method + compact.String()
+}
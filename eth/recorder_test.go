@@ -0,0 +1,81 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCRecorder(t *testing.T) {
+	blockNumber := 0x10
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request rpcMessage
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		response := rpcMessage{Version: "2.0", ID: request.ID}
+		switch request.Method {
+		case "eth_blockNumber":
+			response.Result, _ = json.Marshal(fmt.Sprintf("0x%x", blockNumber))
+			blockNumber++
+		case "eth_call":
+			response.Result = json.RawMessage(`"0x01"`)
+		default:
+			response.Error = json.RawMessage(`{"code": -32601, "message": "method not found"}`)
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	fixture := filepath.Join(t.TempDir(), "fixture.jsonl")
+	contract := common.HexToAddress("0x123")
+
+	client, err := Dial(context.Background(), ClientConfig{URL: node.URL, Record: fixture})
+	assert.NoError(t, err)
+	for _, expected := range []uint64{0x10, 0x11} {
+		number, err := client.BlockNumber(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, number)
+	}
+	result, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &contract}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1}, result)
+	node.Close()
+
+	data, err := os.ReadFile(fixture)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"method":"eth_blockNumber"`)
+
+	// The node is gone, calls are answered from the fixture.
+	client, err = Dial(context.Background(), ClientConfig{URL: node.URL, Replay: fixture})
+	assert.NoError(t, err)
+	for _, expected := range []uint64{0x10, 0x11, 0x11} {
+		number, err := client.BlockNumber(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, number)
+	}
+	result, err = client.CallContract(context.Background(), ethereum.CallMsg{To: &contract}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1}, result)
+
+	other := common.HexToAddress("0x456")
+	_, err = client.CallContract(context.Background(), ethereum.CallMsg{To: &other}, nil)
+	assert.ErrorIs(t, err, ErrNoRecording)
+}
+
+func TestDialRecorder(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.jsonl")
+	_, err := Dial(context.Background(), ClientConfig{URL: "wss://node", Record: fixture})
+	assert.Error(t, err)
+	_, err = Dial(context.Background(), ClientConfig{URL: "http://node", Transport: "ws", Record: fixture})
+	assert.Error(t, err)
+	_, err = Dial(context.Background(), ClientConfig{URL: "http://node", Record: fixture, Replay: fixture})
+	assert.Error(t, err)
+	_, err = Dial(context.Background(), ClientConfig{URL: "http://node", Replay: filepath.Join(t.TempDir(), "missing.jsonl")})
+	assert.Error(t, err)
+}
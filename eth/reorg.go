@@ -0,0 +1,154 @@
+package eth
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type logKey struct {
+	BlockHash common.Hash
+	TxHash    common.Hash
+	Index     uint
+}
+
+// pendingLogs buffers logs until their block is buried under enough
+// confirmations to be considered final.
+type pendingLogs struct {
+	lock sync.Mutex
+	logs map[logKey]types.Log
+}
+
+func newPendingLogs() *pendingLogs {
+	return &pendingLogs{logs: make(map[logKey]types.Log)}
+}
+
+func keyOf(vLog types.Log) logKey {
+	return logKey{BlockHash: vLog.BlockHash, TxHash: vLog.TxHash, Index: vLog.Index}
+}
+
+func (p *pendingLogs) add(vLog types.Log) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := keyOf(vLog)
+	if _, ok := p.logs[key]; ok {
+		return false
+	}
+	p.logs[key] = vLog
+	return true
+}
+
+func (p *pendingLogs) remove(vLog types.Log) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := keyOf(vLog)
+	if _, ok := p.logs[key]; !ok {
+		return false
+	}
+	delete(p.logs, key)
+	return true
+}
+
+// containsSwap reports whether a log of the same transaction and index as
+// vLog, from any block, is in the set.
+func (p *pendingLogs) containsSwap(vLog types.Log) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key := range p.logs {
+		if key.TxHash == vLog.TxHash && key.Index == vLog.Index {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmed pops every log with at least the given number of confirmations
+// at head, ordered by block number and log index.
+func (p *pendingLogs) confirmed(head, confirmations uint64) []types.Log {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var ready []types.Log
+	for key, vLog := range p.logs {
+		if vLog.BlockNumber+confirmations <= head {
+			ready = append(ready, vLog)
+			delete(p.logs, key)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		if ready[i].BlockNumber != ready[j].BlockNumber {
+			return ready[i].BlockNumber < ready[j].BlockNumber
+		}
+		return ready[i].Index < ready[j].Index
+	})
+	return ready
+}
+
+// lowestBlock returns the lowest block still buffered for the given address.
+func (p *pendingLogs) lowestBlock(address common.Address) (uint64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var lowest uint64
+	found := false
+	for _, vLog := range p.logs {
+		if vLog.Address != address {
+			continue
+		}
+		if !found || vLog.BlockNumber < lowest {
+			lowest = vLog.BlockNumber
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+func (p *pendingLogs) len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.logs)
+}
+
+func (l *listener) receiveLog(vLog types.Log) {
+	if vLog.Removed {
+		if !l.pending.remove(vLog) {
+			l.pipeline.submit(vLog)
+		}
+		return
+	}
+	if l.confirmations == 0 {
+		l.pipeline.submit(vLog)
+		return
+	}
+	l.pending.add(vLog)
+}
+
+func (l *listener) flushConfirmedLogs(head uint64) {
+	canonical := make(map[uint64]common.Hash)
+	for _, vLog := range l.pending.confirmed(head, l.confirmations) {
+		hash, ok := canonical[vLog.BlockNumber]
+		if !ok {
+			header, err := l.chain.Client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(vLog.BlockNumber))
+			if err != nil {
+				log.Printf("Failed to get header %d: %v", vLog.BlockNumber, err)
+				l.pending.add(vLog)
+				continue
+			}
+			hash = header.Hash()
+			canonical[vLog.BlockNumber] = hash
+		}
+		if hash != vLog.BlockHash {
+			log.Printf("Dropping reorged log: block %d, tx %s", vLog.BlockNumber, vLog.TxHash.Hex())
+			continue
+		}
+		l.pipeline.submit(vLog)
+	}
+}
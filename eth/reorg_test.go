@@ -0,0 +1,47 @@
+package eth
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPendingLogs(t *testing.T) {
+	pending := newPendingLogs()
+
+	log1 := types.Log{BlockNumber: 10, BlockHash: common.HexToHash("0xa"), TxHash: common.HexToHash("0x1"), Index: 1}
+	log2 := types.Log{BlockNumber: 10, BlockHash: common.HexToHash("0xa"), TxHash: common.HexToHash("0x1"), Index: 0}
+	log3 := types.Log{BlockNumber: 12, BlockHash: common.HexToHash("0xb"), TxHash: common.HexToHash("0x2"), Index: 0}
+
+	assert.True(t, pending.add(log1))
+	assert.True(t, pending.add(log2))
+	assert.True(t, pending.add(log3))
+	assert.False(t, pending.add(log1), "duplicate log should not be buffered twice")
+
+	removed := log3
+	removed.Removed = true
+	assert.True(t, pending.remove(removed))
+	assert.False(t, pending.remove(removed))
+
+	assert.Empty(t, pending.confirmed(12, 3))
+	assert.Equal(t, []types.Log{log2, log1}, pending.confirmed(13, 3))
+	assert.Empty(t, pending.confirmed(100, 3))
+}
+
+func TestPendingLogsLowestBlock(t *testing.T) {
+	pending := newPendingLogs()
+	pool := common.HexToAddress("0x123")
+
+	_, ok := pending.lowestBlock(pool)
+	assert.False(t, ok)
+
+	pending.add(types.Log{Address: pool, BlockNumber: 20, TxHash: common.HexToHash("0x1")})
+	pending.add(types.Log{Address: pool, BlockNumber: 15, TxHash: common.HexToHash("0x2")})
+	pending.add(types.Log{Address: common.HexToAddress("0x456"), BlockNumber: 5, TxHash: common.HexToHash("0x3")})
+
+	lowest, ok := pending.lowestBlock(pool)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(15), lowest)
+}
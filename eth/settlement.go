@@ -0,0 +1,131 @@
+package eth
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+)
+
+// StoredSwaps returns the swaps the listener stored for a pool between
+// startTime and endTime, the ones it credited to the share pool task of
+// that round.
+func StoredSwaps(chainID uint64, poolAddress string, startTime, endTime int64) ([]SwapInfo, error) {
+	userSwaps, err := database.GetSwapEventsByPool(chainID, poolAddress, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	swaps := make([]SwapInfo, 0, len(userSwaps))
+	for _, userSwap := range userSwaps {
+		swaps = append(swaps, SwapInfo{
+			ChainID:     userSwap.ChainID,
+			Trader:      userSwap.SenderAddress,
+			Sender:      userSwap.SenderAddress,
+			Recipient:   userSwap.RecipientAddress,
+			Origin:      userSwap.OriginAddress,
+			USD:         userSwap.AmountUSDC,
+			WETH:        userSwap.AmountWETH,
+			Amount0In:   userSwap.Amount0In.Units(0),
+			Amount1In:   userSwap.Amount1In.Units(0),
+			Amount0Out:  userSwap.Amount0Out.Units(0),
+			Amount1Out:  userSwap.Amount1Out.Units(0),
+			Timestamp:   userSwap.SwapTime,
+			PoolAddress: userSwap.PoolAddress,
+			TxHash:      userSwap.TransactionHash,
+			BlockNumber: userSwap.BlockNumber,
+			BlockHash:   userSwap.BlockHash,
+			LogIndex:    userSwap.LogIndex,
+			TxIndex:     userSwap.TxIndex,
+		})
+	}
+	return swaps, nil
+}
+
+// SwapDiff lists the swaps of a round found on only one side of a
+// reconciliation: Unstored ones are on chain but missing from user_swaps,
+// Orphaned ones are stored but no longer on chain.
+type SwapDiff struct {
+	Unstored []SwapInfo
+	Orphaned []SwapInfo
+}
+
+func (d SwapDiff) Empty() bool {
+	return len(d.Unstored) == 0 && len(d.Orphaned) == 0
+}
+
+// ReconcileSwaps fetches the swaps of a round from the chain again and
+// compares them with the stored ones.
+func ReconcileSwaps(chain *Chain, poolAddress string, startTime, endTime int64, stored []SwapInfo) (SwapDiff, error) {
+	logs, err := FetchSwapEvents(chain, poolAddress, startTime, endTime)
+	if err != nil {
+		return SwapDiff{}, fmt.Errorf("failed to fetch swap events: %w", err)
+	}
+	fetched, err := ParseSwapEvents(chain, logs)
+	if err != nil {
+		return SwapDiff{}, fmt.Errorf("failed to parse swap events: %w", err)
+	}
+
+	// The listener only stores swaps made strictly inside the round, while
+	// the fetched block range can include swaps made at its boundaries.
+	inRound := fetched[:0]
+	for _, swap := range fetched {
+		if swap.Timestamp > startTime && swap.Timestamp < endTime {
+			inRound = append(inRound, swap)
+		}
+	}
+	return DiffSwaps(stored, inRound), nil
+}
+
+// reconcileTask re-fetches the swaps of a round from the chain and reports
+// those missing from user_swaps or from the chain. It only reports: the
+// round is still settled from the stored swaps.
+func reconcileTask(chain *Chain, campaign database.Campaign, task database.Task, stored []SwapInfo) {
+	diff, err := ReconcileSwaps(chain, campaign.PoolAddress, task.StartTime, task.EndTime, stored)
+	if err != nil {
+		log.Printf("Failed to reconcile swap events for task %d: %v", task.TaskID, err)
+		return
+	}
+	if diff.Empty() {
+		log.Printf("Swap events of task %d match the chain (%d swaps)", task.TaskID, len(stored))
+		return
+	}
+	for _, swap := range diff.Unstored {
+		log.Printf("Task %d: swap %s#%d in block %d is on chain but not stored", task.TaskID, swap.TxHash, swap.LogIndex, swap.BlockNumber)
+	}
+	for _, swap := range diff.Orphaned {
+		log.Printf("Task %d: swap %s#%d in block %d is stored but not on chain", task.TaskID, swap.TxHash, swap.LogIndex, swap.BlockNumber)
+	}
+	log.Printf("Swap events of task %d differ from the chain: %d unstored, %d orphaned", task.TaskID, len(diff.Unstored), len(diff.Orphaned))
+}
+
+// DiffSwaps compares stored and fetched swaps by transaction hash and log
+// index.
+func DiffSwaps(stored, fetched []SwapInfo) SwapDiff {
+	storedKeys := make(map[string]bool, len(stored))
+	for _, swap := range stored {
+		storedKeys[swapKey(swap)] = true
+	}
+	fetchedKeys := make(map[string]bool, len(fetched))
+	for _, swap := range fetched {
+		fetchedKeys[swapKey(swap)] = true
+	}
+
+	var diff SwapDiff
+	for _, swap := range fetched {
+		if !storedKeys[swapKey(swap)] {
+			diff.Unstored = append(diff.Unstored, swap)
+		}
+	}
+	for _, swap := range stored {
+		if !fetchedKeys[swapKey(swap)] {
+			diff.Orphaned = append(diff.Orphaned, swap)
+		}
+	}
+	return diff
+}
+
+func swapKey(swap SwapInfo) string {
+	return fmt.Sprintf("%s:%d", strings.ToLower(swap.TxHash), swap.LogIndex)
+}
@@ -0,0 +1,108 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoredSwaps(t *testing.T) {
+	patches := gomonkey.ApplyFunc(database.GetSwapEventsByPool, func(chainID uint64, poolAddress string, startTime, endTime int64) ([]database.UserSwap, error) {
+		return []database.UserSwap{{
+			ChainID:          chainID,
+			TransactionHash:  "0xabc",
+			PoolAddress:      poolAddress,
+			SenderAddress:    "0xsender",
+			RecipientAddress: "0xrecipient",
+			OriginAddress:    "0xorigin",
+			AmountUSDC:       decimal.RequireFromString("12.5"),
+			AmountWETH:       decimal.RequireFromString("0.005"),
+			Amount0In:        decimal.NewFromInt(12500000),
+			Amount1In:        decimal.Zero(),
+			Amount0Out:       decimal.Zero(),
+			Amount1Out:       decimal.RequireFromString("5000000000000000"),
+			SwapTime:         150,
+			BlockNumber:      42,
+			BlockHash:        "0xdef",
+			LogIndex:         3,
+			TxIndex:          5,
+		}}, nil
+	})
+	defer patches.Reset()
+
+	swaps, err := StoredSwaps(1, "0xpool", 100, 200)
+	assert.NoError(t, err)
+	assert.Equal(t, []SwapInfo{{
+		ChainID:     1,
+		Trader:      "0xsender",
+		Sender:      "0xsender",
+		Recipient:   "0xrecipient",
+		Origin:      "0xorigin",
+		USD:         decimal.RequireFromString("12.5"),
+		WETH:        decimal.RequireFromString("0.005"),
+		Amount0In:   big.NewInt(12500000),
+		Amount1In:   big.NewInt(0),
+		Amount0Out:  big.NewInt(0),
+		Amount1Out:  big.NewInt(5000000000000000),
+		Timestamp:   150,
+		PoolAddress: "0xpool",
+		TxHash:      "0xabc",
+		BlockNumber: 42,
+		BlockHash:   "0xdef",
+		LogIndex:    3,
+		TxIndex:     5,
+	}}, swaps)
+}
+
+func TestDiffSwaps(t *testing.T) {
+	a := SwapInfo{TxHash: "0xAA", LogIndex: 1}
+	b := SwapInfo{TxHash: "0xbb", LogIndex: 2}
+	c := SwapInfo{TxHash: "0xbb", LogIndex: 3}
+
+	tests := []struct {
+		name    string
+		stored  []SwapInfo
+		fetched []SwapInfo
+		want    SwapDiff
+	}{
+		{name: "Match", stored: []SwapInfo{a, b}, fetched: []SwapInfo{{TxHash: "0xaa", LogIndex: 1}, b}, want: SwapDiff{}},
+		{name: "Unstored", stored: []SwapInfo{a}, fetched: []SwapInfo{a, b}, want: SwapDiff{Unstored: []SwapInfo{b}}},
+		{name: "Orphaned", stored: []SwapInfo{a, b, c}, fetched: []SwapInfo{b}, want: SwapDiff{Orphaned: []SwapInfo{a, c}}},
+		{name: "Both", stored: []SwapInfo{a, b}, fetched: []SwapInfo{b, c}, want: SwapDiff{Unstored: []SwapInfo{c}, Orphaned: []SwapInfo{a}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffSwaps(tt.stored, tt.fetched)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, len(tt.want.Unstored)+len(tt.want.Orphaned) == 0, got.Empty())
+		})
+	}
+}
+
+func TestReconcileSwaps(t *testing.T) {
+	patches := gomonkey.ApplyFunc(FetchSwapEvents, func(chain *Chain, poolAddress string, startTime, endTime int64) ([]types.Log, error) {
+		return []types.Log{{}}, nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(ParseSwapEvents, func(chain *Chain, logs []types.Log) ([]SwapInfo, error) {
+		return []SwapInfo{
+			{TxHash: "0x1", Timestamp: 100},
+			{TxHash: "0x2", Timestamp: 150},
+			{TxHash: "0x3", Timestamp: 160},
+			{TxHash: "0x4", Timestamp: 200},
+		}, nil
+	})
+
+	stored := []SwapInfo{{TxHash: "0x2", Timestamp: 150}, {TxHash: "0x5", Timestamp: 170}}
+	diff, err := ReconcileSwaps(testChain(&mockClient{}), "0xpool", 100, 200, stored)
+	assert.NoError(t, err)
+	assert.Equal(t, SwapDiff{
+		Unstored: []SwapInfo{{TxHash: "0x3", Timestamp: 160}},
+		Orphaned: []SwapInfo{{TxHash: "0x5", Timestamp: 170}},
+	}, diff)
+}
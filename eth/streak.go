@@ -0,0 +1,427 @@
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+)
+
+const (
+	TaskTypeStreak = "streak"
+
+	StreakPeriodDay   = "day"
+	StreakPeriodRound = "round"
+
+	secondsPerDay = 24 * 60 * 60
+)
+
+var errNoRounds = errors.New("campaign has no share pool rounds")
+
+// StreakMilestone pays Points once a user has traded in Length consecutive
+// periods.
+type StreakMilestone struct {
+	Length int             `json:"length"`
+	Points decimal.Decimal `json:"points"`
+}
+
+// StreakConfig rewards trading in consecutive periods, UTC days or the
+// campaign's share pool rounds. A period counts when the user's volume in it
+// reaches MinVolume. Milestones are listed by ascending length.
+type StreakConfig struct {
+	Period     string            `json:"period"`
+	MinVolume  decimal.Decimal   `json:"minVolume"`
+	Milestones []StreakMilestone `json:"milestones"`
+}
+
+// StreakStatus is the detail of a streak task in the task status API. The
+// current streak is kept while the current period is not over, and drops to
+// zero once a period is missed.
+type StreakStatus struct {
+	Period        string           `json:"period"`
+	MinVolume     decimal.Decimal  `json:"minVolume"`
+	CurrentStreak int              `json:"currentStreak"`
+	BestStreak    int              `json:"bestStreak"`
+	PeriodVolume  decimal.Decimal  `json:"periodVolume"`
+	NextMilestone *StreakMilestone `json:"nextMilestone"`
+}
+
+// streakTask derives streaks from the swaps stored in user_swaps. Each
+// milestone pays once, when the best streak of the user reaches it, and is
+// revoked if a reorg takes the streak back below it.
+type streakTask struct{}
+
+func (streakTask) config(task database.Task) (StreakConfig, error) {
+	var config StreakConfig
+	err := decodeTaskConfig(task.Config, &config)
+	if config.Period == "" {
+		config.Period = StreakPeriodDay
+	}
+	return config, err
+}
+
+func (s streakTask) ValidateConfig(data json.RawMessage) error {
+	config, err := s.config(database.Task{Config: data})
+	if err != nil {
+		return err
+	}
+	if config.Period != StreakPeriodDay && config.Period != StreakPeriodRound {
+		return fmt.Errorf("%w: unknown streak period %s", ErrInvalidConfig, config.Period)
+	}
+	if config.MinVolume.Sign() < 0 {
+		return fmt.Errorf("%w: minimum volume cannot be negative", ErrInvalidConfig)
+	}
+	if len(config.Milestones) == 0 {
+		return fmt.Errorf("%w: streak task needs at least one milestone", ErrInvalidConfig)
+	}
+	previous := 0
+	for i, milestone := range config.Milestones {
+		if milestone.Length <= previous {
+			return fmt.Errorf("%w: milestone %d length must be positive and above the previous milestone", ErrInvalidConfig, i+1)
+		}
+		if milestone.Points.Sign() <= 0 {
+			return fmt.Errorf("%w: milestone %d points must be positive", ErrInvalidConfig, i+1)
+		}
+		previous = milestone.Length
+	}
+	return nil
+}
+
+// inStreakWindow reports whether a swap made at t counts towards a streak
+// task. As for the stored swaps of share pool rounds, swaps at the boundaries
+// of the task are left out.
+func inStreakWindow(task database.Task, t int64) bool {
+	return t > task.StartTime && t < task.EndTime
+}
+
+func (s streakTask) Accrue(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if !inStreakWindow(task, t) {
+		return nil
+	}
+	return s.update(task, userTask, usd, false, t)
+}
+
+func (s streakTask) Rollback(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if !inStreakWindow(task, t) {
+		return nil
+	}
+	return s.update(task, userTask, usd, true, t)
+}
+
+// update credits a stored swap of usd made at t to the user, or takes it back
+// if rollback is set; a swap taken back is only deleted afterwards, so it is
+// left out of the stored swaps here. The best streak only changes when the
+// period of the swap starts or stops counting, so only then is it computed
+// again from all the swaps of the user, paying the milestones it reaches and
+// revoking the paid ones it no longer reaches. It is also computed on the
+// first swap credited to the user task, which catches up with swaps stored
+// before the task was added.
+func (s streakTask) update(task database.Task, userTask database.UserTask, usd decimal.Decimal, rollback bool, t int64) error {
+	config, err := s.config(task)
+	if err != nil {
+		return err
+	}
+	campaign, address, err := streakTrader(task, userTask.UserID)
+	if err != nil {
+		return err
+	}
+	periods, err := newStreakPeriods(task, config.Period)
+	if err != nil {
+		return err
+	}
+
+	if userTask.Amount.Sign() > 0 {
+		changed := false
+		if period, ok := periods.of(t); ok {
+			startTime, endTime := periods.window(task, period)
+			periodSwaps, err := creditedSwaps(campaign, address, startTime, endTime)
+			if err != nil {
+				return err
+			}
+			volume := decimal.Zero()
+			for _, swap := range periodSwaps {
+				volume = volume.Add(swap.AmountUSDC)
+			}
+			counts := func(swaps int, volume decimal.Decimal) bool {
+				return swaps > 0 && volume.Cmp(config.MinVolume) >= 0
+			}
+			changed = counts(len(periodSwaps), volume) != counts(len(periodSwaps)-1, volume.Sub(usd))
+		}
+		if !changed {
+			amount := userTask.Amount.Add(usd)
+			if rollback {
+				amount = decimal.Max(userTask.Amount.Sub(usd), decimal.Zero())
+			}
+			return database.UpdateUserTask(userTask.UserTaskID, userTask.Completed, amount, userTask.Points)
+		}
+	}
+
+	swaps, err := creditedSwaps(campaign, address, task.StartTime, task.EndTime)
+	if err != nil {
+		return err
+	}
+	if rollback {
+		swaps = withoutSwap(swaps, usd, t)
+	}
+	progress := computeProgress(config, periods, swaps, task.EndTime)
+	points := userTask.Points
+	paid := paidMilestones(config.Milestones, points)
+	for paid < len(config.Milestones) && config.Milestones[paid].Length <= progress.best {
+		err = database.CreateUserPointsHistory(userTask.UserID, task.TaskID, task.CampaignID, config.Milestones[paid].Points)
+		if err != nil {
+			return fmt.Errorf("failed to create user points history: %w", err)
+		}
+		points = points.Add(config.Milestones[paid].Points)
+		paid++
+	}
+	for paid > 0 && config.Milestones[paid-1].Length > progress.best {
+		err = database.DeleteLatestUserPointsHistory(userTask.UserID, task.TaskID, config.Milestones[paid-1].Points)
+		if err != nil {
+			return fmt.Errorf("failed to delete user points history: %w", err)
+		}
+		points = decimal.Max(points.Sub(config.Milestones[paid-1].Points), decimal.Zero())
+		paid--
+	}
+	return database.UpdateUserTask(userTask.UserTaskID, paid == len(config.Milestones), progress.volume, points)
+}
+
+// Settle does nothing: milestones are paid as soon as they are reached.
+func (streakTask) Settle(chain *Chain, campaign database.Campaign, task database.Task) error {
+	return nil
+}
+
+func (s streakTask) Status(task database.Task, userTask database.UserTask) (TaskStatus, error) {
+	config, err := s.config(task)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	campaign, address, err := streakTrader(task, userTask.UserID)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	periods, err := newStreakPeriods(task, config.Period)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	progress, err := s.progress(task, config, periods, campaign, address, time.Now().Unix())
+	if err != nil {
+		return TaskStatus{}, err
+	}
+
+	detail := StreakStatus{
+		Period:        config.Period,
+		MinVolume:     config.MinVolume,
+		CurrentStreak: progress.current,
+		BestStreak:    progress.best,
+		PeriodVolume:  progress.periodVolume,
+	}
+	if paid := paidMilestones(config.Milestones, userTask.Points); paid < len(config.Milestones) {
+		next := config.Milestones[paid]
+		detail.NextMilestone = &next
+	}
+	return TaskStatus{
+		Completed: userTask.Completed,
+		Amount:    progress.volume,
+		Points:    userTask.Points,
+		Detail:    detail,
+	}, nil
+}
+
+// paidMilestones returns how many milestones the points of a user task pay
+// for. Milestones are paid in order and their points are positive, so the
+// points add up to the first milestones.
+func paidMilestones(milestones []StreakMilestone, points decimal.Decimal) int {
+	total := decimal.Zero()
+	for i, milestone := range milestones {
+		total = total.Add(milestone.Points)
+		if total.Cmp(points) > 0 {
+			return i
+		}
+	}
+	return len(milestones)
+}
+
+type streakProgress struct {
+	volume       decimal.Decimal
+	periodVolume decimal.Decimal
+	current      int
+	best         int
+}
+
+// streakTrader returns the campaign of a streak task and the address of the
+// user.
+func streakTrader(task database.Task, userID int) (*database.Campaign, string, error) {
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get user: %w", err)
+	}
+	campaign, err := database.GetCampaignByID(task.CampaignID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get campaign: %w", err)
+	}
+	return campaign, user.Address, nil
+}
+
+// creditedSwaps returns the swaps on the campaign pool made strictly between
+// startTime and endTime that the campaign credits to address.
+func creditedSwaps(campaign *database.Campaign, address string, startTime, endTime int64) ([]database.UserSwap, error) {
+	swaps, err := database.GetSwapEventsByAddress(campaign.ChainID, campaign.PoolAddress, address, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	credited := swaps[:0]
+	for _, swap := range swaps {
+		if traderAddress(campaign.Attribution, swap.SenderAddress, swap.RecipientAddress, swap.OriginAddress) == address {
+			credited = append(credited, swap)
+		}
+	}
+	return credited, nil
+}
+
+// withoutSwap returns the swaps without one swap of usd made at t.
+func withoutSwap(swaps []database.UserSwap, usd decimal.Decimal, t int64) []database.UserSwap {
+	for i, swap := range swaps {
+		if swap.SwapTime == t && swap.AmountUSDC.Cmp(usd) == 0 {
+			return append(swaps[:i:i], swaps[i+1:]...)
+		}
+	}
+	return swaps
+}
+
+// progress computes the streaks of a user from the swaps credited to them
+// in the task window, as of now.
+func (streakTask) progress(task database.Task, config StreakConfig, periods streakPeriods, campaign *database.Campaign, address string, now int64) (streakProgress, error) {
+	swaps, err := creditedSwaps(campaign, address, task.StartTime, task.EndTime)
+	if err != nil {
+		return streakProgress{}, err
+	}
+	return computeProgress(config, periods, swaps, min(now, task.EndTime)), nil
+}
+
+// computeProgress computes the streaks of the swaps as of now.
+func computeProgress(config StreakConfig, periods streakPeriods, swaps []database.UserSwap, now int64) streakProgress {
+	volume := decimal.Zero()
+	volumes := make(map[int]decimal.Decimal)
+	for _, swap := range swaps {
+		volume = volume.Add(swap.AmountUSDC)
+		if period, ok := periods.of(swap.SwapTime); ok {
+			volumes[period] = volumes[period].Add(swap.AmountUSDC)
+		}
+	}
+	progress := computeStreak(volumes, config.MinVolume, periods.current(now))
+	progress.volume = volume
+	return progress
+}
+
+// computeStreak returns the longest run of consecutive periods whose volume
+// reaches minVolume, and the run ending at the current period, or at the
+// previous one while the current period is not over.
+func computeStreak(volumes map[int]decimal.Decimal, minVolume decimal.Decimal, current int) streakProgress {
+	var qualified []int
+	for period, volume := range volumes {
+		if volume.Cmp(minVolume) >= 0 {
+			qualified = append(qualified, period)
+		}
+	}
+	sort.Ints(qualified)
+
+	progress := streakProgress{periodVolume: decimal.Zero()}
+	if volume, ok := volumes[current]; ok {
+		progress.periodVolume = volume
+	}
+	run := 0
+	for i, period := range qualified {
+		if i > 0 && qualified[i-1] == period-1 {
+			run++
+		} else {
+			run = 1
+		}
+		progress.best = max(progress.best, run)
+		if period == current || period == current-1 {
+			progress.current = run
+		}
+	}
+	return progress
+}
+
+// streakPeriods numbers the periods of a streak task: UTC days from the day
+// the task starts, or the campaign rounds in order.
+type streakPeriods struct {
+	firstDay int64
+	rounds   []database.Task
+}
+
+func newStreakPeriods(task database.Task, period string) (streakPeriods, error) {
+	if period != StreakPeriodRound {
+		return streakPeriods{firstDay: task.StartTime / secondsPerDay}, nil
+	}
+	tasks, err := database.GetTasksByCampaignID(task.CampaignID)
+	if err != nil {
+		return streakPeriods{}, fmt.Errorf("failed to get campaign rounds: %w", err)
+	}
+	periods, err := roundPeriods(tasks)
+	if err != nil {
+		return streakPeriods{}, fmt.Errorf("%w: campaign %d", err, task.CampaignID)
+	}
+	return periods, nil
+}
+
+// roundPeriods numbers the share pool rounds among the tasks of a campaign.
+func roundPeriods(tasks []database.Task) (streakPeriods, error) {
+	var rounds []database.Task
+	for _, t := range tasks {
+		if t.Type == TaskTypeSharePool {
+			rounds = append(rounds, t)
+		}
+	}
+	if len(rounds) == 0 {
+		return streakPeriods{}, errNoRounds
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i].StartTime < rounds[j].StartTime })
+	return streakPeriods{rounds: rounds}, nil
+}
+
+// of returns the period of a swap. Swaps at the boundary of two rounds
+// belong to neither, as in share pool tasks.
+func (p streakPeriods) of(t int64) (int, bool) {
+	if p.rounds == nil {
+		return int(t/secondsPerDay - p.firstDay), true
+	}
+	for i, round := range p.rounds {
+		if t > round.StartTime && t < round.EndTime {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// window returns the bounds, exclusive, of the swaps made in a period of the
+// task.
+func (p streakPeriods) window(task database.Task, period int) (int64, int64) {
+	var startTime, endTime int64
+	if p.rounds == nil {
+		startTime = (p.firstDay+int64(period))*secondsPerDay - 1
+		endTime = startTime + 1 + secondsPerDay
+	} else {
+		startTime, endTime = p.rounds[period].StartTime, p.rounds[period].EndTime
+	}
+	return max(startTime, task.StartTime), min(endTime, task.EndTime)
+}
+
+// current returns the period in progress at now, or the last one started.
+func (p streakPeriods) current(now int64) int {
+	if p.rounds == nil {
+		return int(now/secondsPerDay - p.firstDay)
+	}
+	current := -1
+	for i, round := range p.rounds {
+		if round.StartTime <= now {
+			current = i
+		}
+	}
+	return current
+}
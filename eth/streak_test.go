@@ -0,0 +1,284 @@
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const testStreak = `{"period":"day","minVolume":"100","milestones":[{"length":3,"points":"50"},{"length":7,"points":"200"}]}`
+
+func TestStreakValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "Days", config: testStreak},
+		{name: "Rounds", config: `{"period":"round","milestones":[{"length":2,"points":"10"}]}`},
+		{name: "Default period", config: `{"minVolume":"100","milestones":[{"length":2,"points":"10"}]}`},
+		{name: "Unknown period", config: `{"period":"week","milestones":[{"length":2,"points":"10"}]}`, wantErr: true},
+		{name: "Negative volume", config: `{"minVolume":"-1","milestones":[{"length":2,"points":"10"}]}`, wantErr: true},
+		{name: "No milestones", config: `{"minVolume":"100"}`, wantErr: true},
+		{name: "Descending lengths", config: `{"milestones":[{"length":7,"points":"200"},{"length":3,"points":"50"}]}`, wantErr: true},
+		{name: "Zero points", config: `{"milestones":[{"length":3,"points":"0"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := streakTask{}.ValidateConfig(json.RawMessage(tt.config))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_computeStreak(t *testing.T) {
+	minVolume := decimal.NewFromInt(100)
+	volumes := func(periods map[int]int64) map[int]decimal.Decimal {
+		result := make(map[int]decimal.Decimal)
+		for period, volume := range periods {
+			result[period] = decimal.NewFromInt(volume)
+		}
+		return result
+	}
+	tests := []struct {
+		name         string
+		volumes      map[int]decimal.Decimal
+		current      int
+		wantCurrent  int
+		wantBest     int
+		periodVolume string
+	}{
+		{name: "No trades", volumes: volumes(nil), current: 3, periodVolume: "0"},
+		{name: "Streak up to today", volumes: volumes(map[int]int64{0: 100, 1: 150, 2: 100}), current: 2, wantCurrent: 3, wantBest: 3, periodVolume: "100"},
+		{name: "Today not traded yet", volumes: volumes(map[int]int64{0: 100, 1: 150, 2: 100}), current: 3, wantCurrent: 3, wantBest: 3, periodVolume: "0"},
+		{name: "Missed a day", volumes: volumes(map[int]int64{0: 100, 1: 150, 2: 100}), current: 4, wantBest: 3, periodVolume: "0"},
+		{name: "Below the minimum volume", volumes: volumes(map[int]int64{0: 100, 1: 99, 2: 100, 3: 100}), current: 3, wantCurrent: 2, wantBest: 2, periodVolume: "100"},
+		{name: "Longer earlier streak", volumes: volumes(map[int]int64{0: 100, 1: 100, 2: 100, 4: 100}), current: 4, wantCurrent: 1, wantBest: 3, periodVolume: "100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeStreak(tt.volumes, minVolume, tt.current)
+			assert.Equal(t, tt.wantCurrent, got.current)
+			assert.Equal(t, tt.wantBest, got.best)
+			assert.Equal(t, tt.periodVolume, got.periodVolume.String())
+		})
+	}
+}
+
+// streakStore patches the swaps a streak task reads with swaps held in
+// memory, and records the points history and the user task it writes.
+type streakStore struct {
+	swaps      []database.UserSwap
+	history    []string
+	userTask   database.UserTask
+	fullScans  int
+	taskWindow [2]int64
+}
+
+func newStreakStore(task database.Task, trader string) (*streakStore, *gomonkey.Patches) {
+	store := &streakStore{
+		userTask:   database.UserTask{UserTaskID: 1, UserID: 1, TaskID: task.TaskID, Amount: decimal.Zero(), Points: decimal.Zero()},
+		taskWindow: [2]int64{task.StartTime, task.EndTime},
+	}
+	patches := gomonkey.ApplyFunc(database.GetUserByID, func(userID int) (*database.User, error) {
+		return &database.User{UserID: userID, Address: trader}, nil
+	})
+	patches.ApplyFunc(database.GetCampaignByID, func(id int) (*database.Campaign, error) {
+		return &database.Campaign{CampaignID: id, ChainID: 1, PoolAddress: "0xpool", Attribution: AttributionRecipient}, nil
+	})
+	patches.ApplyFunc(database.GetSwapEventsByAddress, func(chainID uint64, poolAddress, address string, startTime, endTime int64) ([]database.UserSwap, error) {
+		if [2]int64{startTime, endTime} == store.taskWindow {
+			store.fullScans++
+		}
+		var swaps []database.UserSwap
+		for _, swap := range store.swaps {
+			if swap.SwapTime > startTime && swap.SwapTime < endTime {
+				swaps = append(swaps, swap)
+			}
+		}
+		return swaps, nil
+	})
+	patches.ApplyFunc(database.CreateUserPointsHistory, func(userID, taskID, campaignID int, points decimal.Decimal) error {
+		store.history = append(store.history, points.String())
+		return nil
+	})
+	patches.ApplyFunc(database.DeleteLatestUserPointsHistory, func(userID, taskID int, points decimal.Decimal) error {
+		store.history = store.history[:len(store.history)-1]
+		return nil
+	})
+	patches.ApplyFunc(database.UpdateUserTask, func(userTaskID int, completed bool, amount, points decimal.Decimal) error {
+		store.userTask.Completed, store.userTask.Amount, store.userTask.Points = completed, amount, points
+		return nil
+	})
+	return store, patches
+}
+
+// accrue stores a swap and credits it to the task.
+func (s *streakStore) accrue(t *testing.T, task database.Task, swap database.UserSwap) {
+	s.swaps = append(s.swaps, swap)
+	assert.NoError(t, streakTask{}.Accrue(task, s.userTask, swap.AmountUSDC, swap.SwapTime))
+}
+
+// rollback takes the last stored swap back from the task and then deletes it,
+// as the pipeline does.
+func (s *streakStore) rollback(t *testing.T, task database.Task) {
+	swap := s.swaps[len(s.swaps)-1]
+	assert.NoError(t, streakTask{}.Rollback(task, s.userTask, swap.AmountUSDC, swap.SwapTime))
+	s.swaps = s.swaps[:len(s.swaps)-1]
+}
+
+func TestStreakTask(t *testing.T) {
+	const day = int64(secondsPerDay)
+	start := 1000 * day
+	trader := "0x00000000000000000000000000000000000000aa"
+	swap := func(dayIndex int64, usd int64) database.UserSwap {
+		return database.UserSwap{SenderAddress: "0xrouter", RecipientAddress: trader, AmountUSDC: decimal.NewFromInt(usd), SwapTime: start + dayIndex*day + 60}
+	}
+
+	task := database.Task{TaskID: 4, CampaignID: 1, Type: TaskTypeStreak, Config: json.RawMessage(testStreak), StartTime: start, EndTime: start + 30*day}
+	store, patches := newStreakStore(task, trader)
+	defer patches.Reset()
+
+	// Two days in a row, then a day below the minimum volume: no milestone.
+	store.accrue(t, task, swap(0, 100))
+	store.accrue(t, task, swap(1, 100))
+	store.accrue(t, task, swap(2, 50))
+	assert.Empty(t, store.history)
+	assert.Equal(t, "250", store.userTask.Amount.String())
+	assert.Equal(t, 2, store.fullScans)
+
+	// Another swap completes the third day.
+	store.accrue(t, task, swap(2, 50))
+	assert.Equal(t, []string{"50"}, store.history)
+	assert.Equal(t, "50", store.userTask.Points.String())
+	assert.False(t, store.userTask.Completed)
+	assert.Equal(t, 3, store.fullScans)
+
+	// More volume on a day that already counts leaves the streak as is.
+	store.accrue(t, task, swap(2, 1000))
+	assert.Equal(t, "1300", store.userTask.Amount.String())
+	assert.Equal(t, 3, store.fullScans)
+
+	// Swaps at the boundaries of the task do not count.
+	assert.NoError(t, streakTask{}.Accrue(task, store.userTask, decimal.NewFromInt(1000), task.StartTime))
+	assert.NoError(t, streakTask{}.Accrue(task, store.userTask, decimal.NewFromInt(1000), task.EndTime))
+	assert.Equal(t, "1300", store.userTask.Amount.String())
+
+	// Reorged swaps take the third day back.
+	store.rollback(t, task)
+	assert.Equal(t, []string{"50"}, store.history)
+	store.rollback(t, task)
+	assert.Empty(t, store.history)
+	assert.Equal(t, "0", store.userTask.Points.String())
+	assert.Equal(t, "250", store.userTask.Amount.String())
+	assert.Equal(t, 4, store.fullScans)
+
+	periods, err := newStreakPeriods(task, StreakPeriodDay)
+	assert.NoError(t, err)
+	campaign, address, err := streakTrader(task, 1)
+	assert.NoError(t, err)
+	progress, err := streakTask{}.progress(task, StreakConfig{Period: StreakPeriodDay, MinVolume: decimal.NewFromInt(100)}, periods, campaign, address, start+2*day+120)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, periods.current(start+2*day+120))
+	assert.Equal(t, 2, progress.current)
+	assert.Equal(t, 2, progress.best)
+	assert.Equal(t, "50", progress.periodVolume.String())
+}
+
+func TestStreakTaskWithoutMinVolume(t *testing.T) {
+	const day = int64(secondsPerDay)
+	start := 1000 * day
+	trader := "0x00000000000000000000000000000000000000aa"
+	swap := func(dayIndex int64) database.UserSwap {
+		return database.UserSwap{RecipientAddress: trader, AmountUSDC: decimal.NewFromInt(1), SwapTime: start + dayIndex*day + 60}
+	}
+
+	task := database.Task{TaskID: 4, CampaignID: 1, Type: TaskTypeStreak, Config: json.RawMessage(`{"milestones":[{"length":2,"points":"10"}]}`), StartTime: start, EndTime: start + 30*day}
+	store, patches := newStreakStore(task, trader)
+	defer patches.Reset()
+
+	store.accrue(t, task, swap(0))
+	store.accrue(t, task, swap(0))
+	assert.Empty(t, store.history)
+	store.accrue(t, task, swap(1))
+	assert.Equal(t, []string{"10"}, store.history)
+	assert.True(t, store.userTask.Completed)
+
+	// The second day no longer counts once its only swap is reorged.
+	store.rollback(t, task)
+	assert.Empty(t, store.history)
+	assert.False(t, store.userTask.Completed)
+}
+
+func TestStreakTaskCatchesUp(t *testing.T) {
+	const day = int64(secondsPerDay)
+	start := 1000 * day
+	trader := "0x00000000000000000000000000000000000000aa"
+	task := database.Task{TaskID: 4, CampaignID: 1, Type: TaskTypeStreak, Config: json.RawMessage(testStreak), StartTime: start, EndTime: start + 30*day}
+	store, patches := newStreakStore(task, trader)
+	defer patches.Reset()
+
+	// Swaps stored before the task was added are counted on the first swap
+	// credited to it.
+	for i := int64(0); i < 3; i++ {
+		store.swaps = append(store.swaps, database.UserSwap{RecipientAddress: trader, AmountUSDC: decimal.NewFromInt(100), SwapTime: start + i*day + 60})
+	}
+	// Swaps of the other addresses of the swap do not count.
+	store.swaps = append(store.swaps, database.UserSwap{SenderAddress: trader, RecipientAddress: "0xother", AmountUSDC: decimal.NewFromInt(1000), SwapTime: start + 3*day + 60})
+	store.accrue(t, task, database.UserSwap{RecipientAddress: trader, AmountUSDC: decimal.NewFromInt(10), SwapTime: start + 5*day})
+	assert.Equal(t, []string{"50"}, store.history)
+	assert.Equal(t, "310", store.userTask.Amount.String())
+}
+
+func Test_streakPeriodsWindow(t *testing.T) {
+	const day = int64(secondsPerDay)
+	task := database.Task{StartTime: 1000*day + 600, EndTime: 1003 * day}
+	days, err := newStreakPeriods(task, StreakPeriodDay)
+	assert.NoError(t, err)
+	startTime, endTime := days.window(task, 0)
+	assert.Equal(t, []int64{task.StartTime, 1001 * day}, []int64{startTime, endTime})
+	startTime, endTime = days.window(task, 1)
+	assert.Equal(t, []int64{1001*day - 1, 1002 * day}, []int64{startTime, endTime})
+	startTime, endTime = days.window(task, 2)
+	assert.Equal(t, []int64{1002*day - 1, task.EndTime}, []int64{startTime, endTime})
+
+	rounds, err := roundPeriods([]database.Task{{Type: TaskTypeSharePool, StartTime: 100, EndTime: 200}})
+	assert.NoError(t, err)
+	startTime, endTime = rounds.window(database.Task{StartTime: 0, EndTime: 1000}, 0)
+	assert.Equal(t, []int64{100, 200}, []int64{startTime, endTime})
+}
+
+func Test_roundPeriods(t *testing.T) {
+	_, err := roundPeriods([]database.Task{{Type: TaskTypeOnboarding, StartTime: 100, EndTime: 400}})
+	assert.ErrorIs(t, err, errNoRounds)
+
+	periods, err := roundPeriods([]database.Task{
+		{Type: TaskTypeOnboarding, StartTime: 100, EndTime: 400},
+		{Type: TaskTypeSharePool, StartTime: 200, EndTime: 300},
+		{Type: TaskTypeSharePool, StartTime: 100, EndTime: 200},
+		{Type: TaskTypeSharePool, StartTime: 300, EndTime: 400},
+	})
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		t      int64
+		period int
+		ok     bool
+	}{{150, 0, true}, {200, 0, false}, {250, 1, true}, {399, 2, true}} {
+		period, ok := periods.of(tt.t)
+		assert.Equal(t, tt.ok, ok, tt.t)
+		if tt.ok {
+			assert.Equal(t, tt.period, period, tt.t)
+		}
+	}
+	assert.Equal(t, -1, periods.current(50))
+	assert.Equal(t, 1, periods.current(200))
+	assert.Equal(t, 2, periods.current(500))
+}
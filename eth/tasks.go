@@ -0,0 +1,263 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/spf13/viper"
+)
+
+const (
+	TaskTypeOnboarding = "onboarding"
+	TaskTypeSharePool  = "share_pool"
+)
+
+var (
+	ErrUnknownTaskType = errors.New("unknown task type")
+	ErrInvalidConfig   = errors.New("invalid task config")
+)
+
+// TaskType is a reward mechanic, registered under the tasks.type of its
+// tasks. Its config is stored in tasks.config and decoded by the type.
+type TaskType interface {
+	// ValidateConfig checks the config of a new task.
+	ValidateConfig(config json.RawMessage) error
+	// Accrue credits the volume of a swap made at t to the user's progress.
+	// It is called for every task of a campaign whose window covers the
+	// swap, so tasks shorter than the campaign check their own window.
+	Accrue(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error
+	// Rollback takes back a swap credited by Accrue.
+	Rollback(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error
+	// Settle closes the task once its end time has passed.
+	Settle(chain *Chain, campaign database.Campaign, task database.Task) error
+	// Status renders the progress of a user on the task.
+	Status(task database.Task, userTask database.UserTask) (TaskStatus, error)
+}
+
+// TaskStatus is the progress of a user on a task. Detail holds what is
+// specific to the task type, such as the volume left to a threshold.
+type TaskStatus struct {
+	Completed bool
+	Amount    decimal.Decimal
+	Points    decimal.Decimal
+	Detail    interface{}
+}
+
+var taskTypes = map[string]TaskType{
+	TaskTypeOnboarding: onboardingTask{},
+	TaskTypeSharePool:  sharePoolTask{},
+	TaskTypeMilestone:  milestoneTask{},
+	TaskTypeStreak:     streakTask{},
+}
+
+// RegisterTaskType adds a task type. It must be called before the listener
+// and the server start.
+func RegisterTaskType(name string, taskType TaskType) {
+	if _, ok := taskTypes[name]; ok {
+		log.Fatalf("Task type %s is already registered", name)
+	}
+	taskTypes[name] = taskType
+}
+
+func GetTaskType(name string) (TaskType, error) {
+	taskType, ok := taskTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTaskType, name)
+	}
+	return taskType, nil
+}
+
+// decodeTaskConfig decodes a task config, rejecting unknown fields.
+func decodeTaskConfig(config json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// OnboardingConfig pays Reward once a user's volume over the campaign
+// reaches Threshold.
+type OnboardingConfig struct {
+	Reward    decimal.Decimal `json:"reward"`
+	Threshold decimal.Decimal `json:"threshold"`
+}
+
+type onboardingTask struct{}
+
+func (onboardingTask) config(task database.Task) (OnboardingConfig, error) {
+	var config OnboardingConfig
+	err := decodeTaskConfig(task.Config, &config)
+	return config, err
+}
+
+func (onboardingTask) ValidateConfig(data json.RawMessage) error {
+	var config OnboardingConfig
+	if err := decodeTaskConfig(data, &config); err != nil {
+		return err
+	}
+	if config.Reward.Sign() < 0 || config.Threshold.Sign() < 0 {
+		return fmt.Errorf("%w: onboarding reward and threshold cannot be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+func (o onboardingTask) Accrue(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if userTask.Completed {
+		return nil
+	}
+	config, err := o.config(task)
+	if err != nil {
+		return err
+	}
+
+	totalAmount := userTask.Amount.Add(usd)
+	if totalAmount.Cmp(config.Threshold) < 0 {
+		return database.UpdateUserTask(userTask.UserTaskID, false, totalAmount, decimal.Zero())
+	}
+	err = database.UpdateUserTask(userTask.UserTaskID, true, totalAmount, config.Reward)
+	if err != nil {
+		return fmt.Errorf("failed to update user task: %w", err)
+	}
+	err = database.CreateUserPointsHistory(userTask.UserID, task.TaskID, task.CampaignID, config.Reward)
+	if err != nil {
+		return fmt.Errorf("failed to create user points history: %w", err)
+	}
+	return nil
+}
+
+func (o onboardingTask) Rollback(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	config, err := o.config(task)
+	if err != nil {
+		return err
+	}
+
+	totalAmount := decimal.Max(userTask.Amount.Sub(usd), decimal.Zero())
+	if userTask.Completed && totalAmount.Cmp(config.Threshold) < 0 {
+		err = database.UpdateUserTask(userTask.UserTaskID, false, totalAmount, decimal.Zero())
+		if err != nil {
+			return fmt.Errorf("failed to update user task: %w", err)
+		}
+		err = database.DeleteUserPointsHistory(userTask.UserID, task.TaskID)
+		if err != nil {
+			return fmt.Errorf("failed to delete user points history: %w", err)
+		}
+		return nil
+	}
+	return database.UpdateUserTask(userTask.UserTaskID, userTask.Completed, totalAmount, userTask.Points)
+}
+
+// Settle does nothing: the onboarding reward is paid as soon as a user
+// reaches the threshold.
+func (onboardingTask) Settle(chain *Chain, campaign database.Campaign, task database.Task) error {
+	return nil
+}
+
+func (o onboardingTask) Status(task database.Task, userTask database.UserTask) (TaskStatus, error) {
+	config, err := o.config(task)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	return TaskStatus{
+		Completed: userTask.Completed,
+		Amount:    userTask.Amount,
+		Points:    userTask.Points,
+		Detail: map[string]decimal.Decimal{
+			"threshold": config.Threshold,
+			"reward":    config.Reward,
+			"remaining": decimal.Max(config.Threshold.Sub(userTask.Amount), decimal.Zero()),
+		},
+	}, nil
+}
+
+// SharePoolConfig splits PointsPool among the traders of a round pro rata
+// to their volume.
+type SharePoolConfig struct {
+	PointsPool decimal.Decimal `json:"pointsPool"`
+}
+
+type sharePoolTask struct{}
+
+func (sharePoolTask) config(task database.Task) (SharePoolConfig, error) {
+	var config SharePoolConfig
+	err := decodeTaskConfig(task.Config, &config)
+	return config, err
+}
+
+func (sharePoolTask) ValidateConfig(data json.RawMessage) error {
+	var config SharePoolConfig
+	if err := decodeTaskConfig(data, &config); err != nil {
+		return err
+	}
+	if config.PointsPool.Sign() < 0 {
+		return fmt.Errorf("%w: points pool cannot be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Accrue adds the swap to the round volume shown to the user. Rewards are
+// computed from the stored swaps when the round is settled.
+func (sharePoolTask) Accrue(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if t <= task.StartTime || t >= task.EndTime {
+		return nil
+	}
+	return database.IncreaseUserTaskAmount(task.TaskID, userTask.UserID, usd)
+}
+
+func (sharePoolTask) Rollback(task database.Task, userTask database.UserTask, usd decimal.Decimal, t int64) error {
+	if t <= task.StartTime || t >= task.EndTime {
+		return nil
+	}
+	return database.DecreaseUserTaskAmount(task.TaskID, userTask.UserID, usd)
+}
+
+// Settle distributes the points pool of a round among the traders of the
+// swaps stored for it. The swaps can also be reconciled with the chain. It
+// fails with ErrNotIngested until every swap of the round is stored, and is
+// then called again.
+func (sharePoolTask) Settle(chain *Chain, campaign database.Campaign, task database.Task) error {
+	err := checkIngested(chain, campaign.PoolAddress, task.EndTime)
+	if err != nil {
+		return err
+	}
+	onboardingTask, err := database.GetTaskByCampaignIDAndType(campaign.CampaignID, TaskTypeOnboarding)
+	if err != nil {
+		return fmt.Errorf("failed to get onboarding task: %w", err)
+	}
+	swaps, err := StoredSwaps(chain.ID, campaign.PoolAddress, task.StartTime, task.EndTime)
+	if err != nil {
+		return fmt.Errorf("failed to get stored swap events: %w", err)
+	}
+	if viper.GetBool("settlement.reconcile") {
+		reconcileTask(chain, campaign, task, swaps)
+	}
+	// MEV detection matches swaps by their transaction origin.
+	err = resolveOrigins(chain.Client, swaps)
+	if err != nil {
+		return fmt.Errorf("failed to resolve swap origins: %w", err)
+	}
+	err = AttributeSwaps(chain.Client, swaps, campaign.Attribution)
+	if err != nil {
+		return fmt.Errorf("failed to attribute swap events: %w", err)
+	}
+	return ProcessSwapInfos(task, swaps, *onboardingTask)
+}
+
+func (s sharePoolTask) Status(task database.Task, userTask database.UserTask) (TaskStatus, error) {
+	config, err := s.config(task)
+	if err != nil {
+		return TaskStatus{}, err
+	}
+	return TaskStatus{
+		Completed: userTask.Completed,
+		Amount:    userTask.Amount,
+		Points:    userTask.Points,
+		Detail:    map[string]decimal.Decimal{"pointsPool": config.PointsPool},
+	}, nil
+}
@@ -0,0 +1,114 @@
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTaskType(t *testing.T) {
+	taskType, err := GetTaskType(TaskTypeOnboarding)
+	assert.NoError(t, err)
+	assert.Equal(t, onboardingTask{}, taskType)
+
+	_, err = GetTaskType("lottery")
+	assert.ErrorIs(t, err, ErrUnknownTaskType)
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		taskType string
+		config   string
+		wantErr  bool
+	}{
+		{name: "Onboarding", taskType: TaskTypeOnboarding, config: `{"reward":"100","threshold":"1000"}`},
+		{name: "Onboarding negative reward", taskType: TaskTypeOnboarding, config: `{"reward":"-1","threshold":"1000"}`, wantErr: true},
+		{name: "Onboarding unknown field", taskType: TaskTypeOnboarding, config: `{"reward":"1","points":"1"}`, wantErr: true},
+		{name: "Share pool", taskType: TaskTypeSharePool, config: `{"pointsPool":"10000"}`},
+		{name: "Share pool negative pool", taskType: TaskTypeSharePool, config: `{"pointsPool":"-1"}`, wantErr: true},
+		{name: "Share pool empty", taskType: TaskTypeSharePool, config: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskType, err := GetTaskType(tt.taskType)
+			assert.NoError(t, err)
+			err = taskType.ValidateConfig(json.RawMessage(tt.config))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestOnboardingTaskAccrue(t *testing.T) {
+	type update struct {
+		completed bool
+		amount    string
+		points    string
+	}
+	var updates []update
+	var history []string
+	patches := gomonkey.ApplyFunc(database.UpdateUserTask, func(userTaskID int, completed bool, amount, points decimal.Decimal) error {
+		updates = append(updates, update{completed, amount.String(), points.String()})
+		return nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(database.CreateUserPointsHistory, func(userID, taskID, campaignID int, points decimal.Decimal) error {
+		history = append(history, points.String())
+		return nil
+	})
+	patches.ApplyFunc(database.DeleteUserPointsHistory, func(userID, taskID int) error {
+		history = nil
+		return nil
+	})
+
+	task := database.Task{TaskID: 1, CampaignID: 1, Type: TaskTypeOnboarding, Config: json.RawMessage(`{"reward":"100","threshold":"1000"}`)}
+	userTask := database.UserTask{UserTaskID: 1, UserID: 1, TaskID: 1, Amount: decimal.Zero(), Points: decimal.Zero()}
+	onboarding := onboardingTask{}
+
+	assert.NoError(t, onboarding.Accrue(task, userTask, decimal.NewFromInt(400), 0))
+	userTask.Amount = decimal.NewFromInt(400)
+	assert.NoError(t, onboarding.Accrue(task, userTask, decimal.NewFromInt(600), 0))
+	assert.Equal(t, []update{{false, "400", "0"}, {true, "1000", "100"}}, updates)
+	assert.Equal(t, []string{"100"}, history)
+
+	// Completed tasks keep their reward until a rollback takes the volume
+	// back below the threshold.
+	userTask = database.UserTask{UserTaskID: 1, UserID: 1, TaskID: 1, Completed: true, Amount: decimal.NewFromInt(1000), Points: decimal.NewFromInt(100)}
+	assert.NoError(t, onboarding.Accrue(task, userTask, decimal.NewFromInt(50), 0))
+	assert.Len(t, updates, 2)
+	assert.NoError(t, onboarding.Rollback(task, userTask, decimal.NewFromInt(1), 0))
+	assert.Equal(t, update{false, "999", "0"}, updates[2])
+	assert.Empty(t, history)
+
+	status, err := onboarding.Status(task, database.UserTask{Amount: decimal.NewFromInt(400), Points: decimal.Zero()})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]decimal.Decimal{
+		"threshold": decimal.NewFromInt(1000),
+		"reward":    decimal.NewFromInt(100),
+		"remaining": decimal.NewFromInt(600),
+	}, status.Detail)
+}
+
+func TestSharePoolTaskAccrue(t *testing.T) {
+	var increased []int64
+	patches := gomonkey.ApplyFunc(database.IncreaseUserTaskAmount, func(taskID int, userID int, amount decimal.Decimal) error {
+		increased = append(increased, amount.Units(0).Int64())
+		return nil
+	})
+	defer patches.Reset()
+
+	task := database.Task{TaskID: 2, Type: TaskTypeSharePool, Config: json.RawMessage(`{"pointsPool":"10000"}`), StartTime: 100, EndTime: 200}
+	userTask := database.UserTask{UserID: 1, TaskID: 2}
+	for i, swapTime := range []int64{100, 150, 199, 200} {
+		assert.NoError(t, sharePoolTask{}.Accrue(task, userTask, decimal.NewFromInt(int64(i+1)), swapTime))
+	}
+	assert.Equal(t, []int64{2, 3}, increased)
+}
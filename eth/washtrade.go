@@ -0,0 +1,184 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/spf13/viper"
+)
+
+// Reasons a swap is flagged as wash trading.
+const (
+	WashRoundTrip  = "round_trip"
+	WashSelfFunded = "self_funded_loop"
+	WashNetZero    = "net_zero"
+)
+
+const defaultWashBlockWindow = 10
+
+var defaultWashTolerance = decimal.RequireFromString("0.02")
+
+// WashConfig tunes wash-trading detection. Swaps are matched when they are at
+// most BlockWindow blocks apart and their token0 amounts differ by at most
+// Tolerance of the larger one. Flagged swaps count for Discount of their
+// volume at settlement, so a zero Discount excludes them.
+type WashConfig struct {
+	BlockWindow uint64
+	Tolerance   decimal.Decimal
+	Discount    decimal.Decimal
+}
+
+// WashFlag is one reason the swap at Index was flagged.
+type WashFlag struct {
+	Index  int
+	Reason string
+	Detail string
+}
+
+func LoadWashConfig() WashConfig {
+	config := WashConfig{
+		BlockWindow: defaultWashBlockWindow,
+		Tolerance:   defaultWashTolerance,
+		Discount:    decimal.Zero(),
+	}
+	if viper.IsSet("wash.block_window") {
+		config.BlockWindow = viper.GetUint64("wash.block_window")
+	}
+	if tolerance, err := decimal.NewFromString(viper.GetString("wash.tolerance")); err == nil {
+		config.Tolerance = tolerance
+	}
+	if discount, err := decimal.NewFromString(viper.GetString("wash.discount")); err == nil {
+		config.Discount = discount
+	}
+	return config
+}
+
+// DetectWashTrades flags swaps whose trader gets back what it traded away:
+//   - round_trip: the trader reverses a swap of about the same size within the
+//     block window.
+//   - self_funded_loop: the output of a swap is sent to another address, which
+//     swaps it back to the trader within the block window.
+//   - net_zero: over all its swaps, the trader's token0 position barely moved
+//     compared to the volume it traded.
+func DetectWashTrades(swaps []SwapInfo, config WashConfig) []WashFlag {
+	order := make([]int, len(swaps))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if swaps[order[a]].BlockNumber != swaps[order[b]].BlockNumber {
+			return swaps[order[a]].BlockNumber < swaps[order[b]].BlockNumber
+		}
+		return swaps[order[a]].LogIndex < swaps[order[b]].LogIndex
+	})
+	deltas := make([]*big.Int, len(swaps))
+	for i, swap := range swaps {
+		deltas[i] = token0Delta(swap)
+	}
+
+	var flags []WashFlag
+	flagged := make(map[int]map[string]bool)
+	flag := func(index int, reason, detail string) {
+		if flagged[index] == nil {
+			flagged[index] = make(map[string]bool)
+		}
+		if flagged[index][reason] {
+			return
+		}
+		flagged[index][reason] = true
+		flags = append(flags, WashFlag{Index: index, Reason: reason, Detail: detail})
+	}
+
+	for a, i := range order {
+		for _, j := range order[a+1:] {
+			if swaps[j].BlockNumber-swaps[i].BlockNumber > config.BlockWindow {
+				break
+			}
+			if !reverses(deltas[i], deltas[j], config.Tolerance) {
+				continue
+			}
+			blocks := swaps[j].BlockNumber - swaps[i].BlockNumber
+			switch {
+			case sameAddress(swaps[i].Trader, swaps[j].Trader):
+				flag(i, WashRoundTrip, fmt.Sprintf("reversed by tx %s %d blocks later", swaps[j].TxHash, blocks))
+				flag(j, WashRoundTrip, fmt.Sprintf("reverses tx %s %d blocks earlier", swaps[i].TxHash, blocks))
+			case loops(swaps[i], swaps[j]):
+				flag(i, WashSelfFunded, fmt.Sprintf("output sent to %s, swapped back to the trader in tx %s", swaps[i].Recipient, swaps[j].TxHash))
+				flag(j, WashSelfFunded, fmt.Sprintf("swaps back to %s the output of tx %s", swaps[j].Recipient, swaps[i].TxHash))
+			}
+		}
+	}
+
+	byTrader := make(map[string][]int)
+	for _, i := range order {
+		if swaps[i].Trader != "" {
+			trader := strings.ToLower(swaps[i].Trader)
+			byTrader[trader] = append(byTrader[trader], i)
+		}
+	}
+	for _, indexes := range byTrader {
+		if len(indexes) < 2 {
+			continue
+		}
+		net, gross := new(big.Int), new(big.Int)
+		for _, i := range indexes {
+			net.Add(net, deltas[i])
+			gross.Add(gross, new(big.Int).Abs(deltas[i]))
+		}
+		if gross.Sign() == 0 || !withinTolerance(new(big.Int).Abs(net), gross, config.Tolerance) {
+			continue
+		}
+		for _, i := range indexes {
+			flag(i, WashNetZero, fmt.Sprintf("net token0 change %s over %d swaps trading %s", net, len(indexes), gross))
+		}
+	}
+
+	sort.SliceStable(flags, func(a, b int) bool { return flags[a].Index < flags[b].Index })
+	return flags
+}
+
+// token0Delta is the change of the trader's token0 balance caused by a swap.
+func token0Delta(swap SwapInfo) *big.Int {
+	delta := new(big.Int)
+	if swap.Amount0Out != nil {
+		delta.Add(delta, swap.Amount0Out)
+	}
+	if swap.Amount0In != nil {
+		delta.Sub(delta, swap.Amount0In)
+	}
+	return delta
+}
+
+// reverses reports whether two token0 changes go in opposite directions and
+// cancel out within the tolerance.
+func reverses(a, b *big.Int, tolerance decimal.Decimal) bool {
+	if a.Sign() == 0 || a.Sign() == b.Sign() {
+		return false
+	}
+	larger := new(big.Int).Abs(a)
+	if absB := new(big.Int).Abs(b); absB.Cmp(larger) > 0 {
+		larger = absB
+	}
+	return withinTolerance(new(big.Int).Abs(new(big.Int).Add(a, b)), larger, tolerance)
+}
+
+func withinTolerance(diff, total *big.Int, tolerance decimal.Decimal) bool {
+	return decimal.NewFromBigInt(diff, 0).Cmp(decimal.NewFromBigInt(total, 0).Mul(tolerance)) <= 0
+}
+
+// loops reports whether b sends back to a's trader what a sent to another
+// address.
+func loops(a, b SwapInfo) bool {
+	if a.Recipient == "" || sameAddress(a.Recipient, a.Trader) {
+		return false
+	}
+	fromRecipient := sameAddress(b.Trader, a.Recipient) || sameAddress(b.Sender, a.Recipient)
+	return fromRecipient && sameAddress(b.Recipient, a.Trader)
+}
+
+func sameAddress(a, b string) bool {
+	return a != "" && strings.EqualFold(a, b)
+}
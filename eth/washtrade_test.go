@@ -0,0 +1,103 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// washSwap builds a swap of trader that sells token0 if amount0 is negative
+// and buys it otherwise.
+func washSwap(tx, trader, recipient string, block uint64, amount0 int64) SwapInfo {
+	swap := SwapInfo{
+		TxHash:      tx,
+		Trader:      trader,
+		Sender:      trader,
+		Recipient:   recipient,
+		BlockNumber: block,
+		Amount0In:   new(big.Int),
+		Amount0Out:  new(big.Int),
+		USD:         decimal.NewFromInt(100),
+	}
+	if amount0 < 0 {
+		swap.Amount0In.SetInt64(-amount0)
+	} else {
+		swap.Amount0Out.SetInt64(amount0)
+	}
+	return swap
+}
+
+func TestDetectWashTrades(t *testing.T) {
+	config := WashConfig{BlockWindow: 5, Tolerance: decimal.RequireFromString("0.02"), Discount: decimal.Zero()}
+	tests := []struct {
+		name    string
+		swaps   []SwapInfo
+		reasons map[int][]string
+	}{
+		{
+			name: "Round trip within the window",
+			swaps: []SwapInfo{
+				washSwap("0x1", "0xbot", "0xbot", 100, 1000),
+				washSwap("0x2", "0xbot", "0xbot", 102, -990),
+				washSwap("0x3", "0xtrader", "0xtrader", 101, 500),
+			},
+			reasons: map[int][]string{0: {WashRoundTrip, WashNetZero}, 1: {WashRoundTrip, WashNetZero}},
+		},
+		{
+			name: "Reversal outside the window",
+			swaps: []SwapInfo{
+				washSwap("0x1", "0xbot", "0xbot", 100, 1000),
+				washSwap("0x2", "0xbot", "0xbot", 110, -500),
+			},
+			reasons: map[int][]string{},
+		},
+		{
+			name: "Reversal of a different size",
+			swaps: []SwapInfo{
+				washSwap("0x1", "0xtrader", "0xtrader", 100, 1000),
+				washSwap("0x2", "0xtrader", "0xtrader", 101, -500),
+			},
+			reasons: map[int][]string{},
+		},
+		{
+			name: "Self-funded loop",
+			swaps: []SwapInfo{
+				washSwap("0x1", "0xa", "0xb", 100, 1000),
+				washSwap("0x2", "0xb", "0xa", 101, -1000),
+			},
+			reasons: map[int][]string{0: {WashSelfFunded}, 1: {WashSelfFunded}},
+		},
+		{
+			name: "Net zero position over the round",
+			swaps: []SwapInfo{
+				washSwap("0x1", "0xbot", "0xbot", 100, 1000),
+				washSwap("0x2", "0xbot", "0xbot", 200, -600),
+				washSwap("0x3", "0xbot", "0xbot", 300, -400),
+			},
+			reasons: map[int][]string{0: {WashNetZero}, 1: {WashNetZero}, 2: {WashNetZero}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[int][]string)
+			for _, flag := range DetectWashTrades(tt.swaps, config) {
+				assert.NotEmpty(t, flag.Detail)
+				got[flag.Index] = append(got[flag.Index], flag.Reason)
+			}
+			assert.Equal(t, tt.reasons, got)
+		})
+	}
+}
+
+func TestLoadWashConfig(t *testing.T) {
+	defer viper.Reset()
+	assert.Equal(t, WashConfig{BlockWindow: 10, Tolerance: decimal.RequireFromString("0.02"), Discount: decimal.Zero()}, LoadWashConfig())
+
+	viper.Set("wash.block_window", 3)
+	viper.Set("wash.tolerance", "0.05")
+	viper.Set("wash.discount", "0.5")
+	assert.Equal(t, WashConfig{BlockWindow: 3, Tolerance: decimal.RequireFromString("0.05"), Discount: decimal.RequireFromString("0.5")}, LoadWashConfig())
+}
@@ -1,16 +1,33 @@
 package server
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/Largeb0525/Trading_Ace/eth"
+)
 
 type CreateCampaignReq struct {
-	Name                string  `json:"name" binding:"required"`
-	PoolAddress         string  `json:"poolAddress" binding:"required"`
-	StartAt             int64   `json:"startAt" binding:"required"`
-	OnboardingReward    float64 `json:"onboardingReward" binding:"required"`
-	OnboardingThreshold float64 `json:"onboardingThreshold" binding:"required"`
-	PointPool           float64 `json:"pointPool" binding:"required"`
-	Schedule            string  `json:"schedule" binding:"required"`
-	Round               int64   `json:"round" binding:"required"`
+	ChainID             uint64           `json:"chainId"`
+	Name                string           `json:"name" binding:"required"`
+	PoolAddress         string           `json:"poolAddress" binding:"required"`
+	Protocol            string           `json:"protocol"`
+	Attribution         string           `json:"attribution"`
+	StartAt             int64            `json:"startAt" binding:"required"`
+	OnboardingReward    *decimal.Decimal `json:"onboardingReward" binding:"required"`
+	OnboardingThreshold *decimal.Decimal `json:"onboardingThreshold" binding:"required"`
+	PointPool           *decimal.Decimal `json:"pointPool" binding:"required"`
+	Schedule            string           `json:"schedule" binding:"required"`
+	Round               int64            `json:"round" binding:"required"`
+	Tasks               []CreateTaskReq  `json:"tasks"`
+}
+
+// CreateTaskReq is an extra task of a campaign, of any registered type.
+type CreateTaskReq struct {
+	Type        string          `json:"type" binding:"required"`
+	Description string          `json:"description"`
+	Config      json.RawMessage `json:"config"`
 }
 
 type GetUserTaskStatusResp struct {
@@ -19,36 +36,85 @@ type GetUserTaskStatusResp struct {
 
 type CampaignResp struct {
 	CampaignID  int              `json:"campaignId"`
+	ChainID     uint64           `json:"chainId"`
 	Name        string           `json:"name"`
 	PoolAddress string           `json:"poolAddress"`
+	Protocol    string           `json:"protocol"`
+	Attribution string           `json:"attribution"`
 	StartTime   int64            `json:"startTime"`
 	EndTime     int64            `json:"endTime"`
 	Tasks       []TaskStatusResp `json:"tasks"`
 }
 
 type TaskStatusResp struct {
-	TaskID      int     `json:"taskId"`
-	Type        string  `json:"type"`
-	Description string  `json:"description"`
-	Completed   bool    `json:"completed"`
-	Amount      float64 `json:"amount"`
-	Points      float64 `json:"points"`
-	StartTime   int64   `json:"startTime"`
-	EndTime     int64   `json:"endTime"`
+	TaskID      int             `json:"taskId"`
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Completed   bool            `json:"completed"`
+	Amount      decimal.Decimal `json:"amount"`
+	Points      decimal.Decimal `json:"points"`
+	Detail      interface{}     `json:"detail,omitempty"`
+	StartTime   int64           `json:"startTime"`
+	EndTime     int64           `json:"endTime"`
 }
 
 type GetUserPointsHistoryResp struct {
 	PointsHistory []PointsHistoryResp `json:"pointsHistory"`
-	Total         float64             `json:"total"`
+	Total         decimal.Decimal     `json:"total"`
 }
 
 type PointsHistoryResp struct {
-	CampaignID   int       `json:"campaignId"`
-	CampaignName string    `json:"campaignName"`
-	PoolAddress  string    `json:"poolAddress"`
-	TaskID       int       `json:"taskId"`
-	TaskType     string    `json:"taskType"`
-	Description  string    `json:"description"`
-	Points       float64   `json:"points"`
-	Timestamp    time.Time `json:"timestamp"`
+	CampaignID   int             `json:"campaignId"`
+	ChainID      uint64          `json:"chainId"`
+	CampaignName string          `json:"campaignName"`
+	PoolAddress  string          `json:"poolAddress"`
+	TaskID       int             `json:"taskId"`
+	TaskType     string          `json:"taskType"`
+	Description  string          `json:"description"`
+	Points       decimal.Decimal `json:"points"`
+	Timestamp    time.Time       `json:"timestamp"`
+}
+
+type ChainStatusResp struct {
+	ChainID   uint64               `json:"chainId"`
+	Name      string               `json:"name"`
+	Endpoints []eth.EndpointStatus `json:"endpoints,omitempty"`
+	Pipeline  *eth.PipelineStats   `json:"pipeline,omitempty"`
+}
+
+type GetSwapFlagsResp struct {
+	Flags []SwapFlagResp `json:"flags"`
+}
+
+type SwapFlagResp struct {
+	CampaignID      int             `json:"campaignId"`
+	TaskID          int             `json:"taskId"`
+	ChainID         uint64          `json:"chainId"`
+	TransactionHash string          `json:"transactionHash"`
+	LogIndex        uint            `json:"logIndex"`
+	UserAddress     string          `json:"userAddress"`
+	Reason          string          `json:"reason"`
+	Detail          string          `json:"detail"`
+	AmountUSD       decimal.Decimal `json:"amountUsd"`
+	Timestamp       time.Time       `json:"timestamp"`
+}
+
+type CreateAddressLabelReq struct {
+	ChainID uint64 `json:"chainId"`
+	Address string `json:"address" binding:"required"`
+	Label   string `json:"label" binding:"required"`
+	Detail  string `json:"detail"`
+}
+
+type GetAddressLabelsResp struct {
+	Labels []AddressLabelResp `json:"labels"`
+}
+
+type AddressLabelResp struct {
+	ChainID   uint64    `json:"chainId"`
+	Address   string    `json:"address"`
+	Label     string    `json:"label"`
+	Source    string    `json:"source"`
+	Detail    string    `json:"detail"`
+	Timestamp time.Time `json:"timestamp"`
 }
@@ -0,0 +1,351 @@
+//go:build e2e
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
+	"github.com/Largeb0525/Trading_Ace/eth"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The simulated backend always uses chain ID 1337.
+const simulatedChainID uint64 = 1337
+
+// artifactsDir holds the UniswapV2Factory, UniswapV2Pair and ERC20 artifacts
+// compiled from contracts.sol, minimal stand-ins for @uniswap/v2-core.
+var artifactsDir = filepath.Join("testdata", "uniswap")
+
+// artifact is a compiled contract.
+type artifact struct {
+	abi      abi.ABI
+	bytecode []byte
+}
+
+func loadArtifact(t *testing.T, name string) artifact {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(artifactsDir, name+".json"))
+	require.NoError(t, err)
+
+	var build struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode string          `json:"bytecode"`
+		EVM      struct {
+			Bytecode struct {
+				Object string `json:"object"`
+			} `json:"bytecode"`
+		} `json:"evm"`
+	}
+	require.NoError(t, json.Unmarshal(data, &build))
+	contractABI, err := abi.JSON(bytes.NewReader(build.ABI))
+	require.NoError(t, err)
+	bytecode := build.Bytecode
+	if bytecode == "" {
+		bytecode = build.EVM.Bytecode.Object
+	}
+	return artifact{abi: contractABI, bytecode: common.FromHex(bytecode)}
+}
+
+// simChain is a simulated chain registered as a campaign chain, with a funded
+// deployer account.
+type simChain struct {
+	t        *testing.T
+	backend  *simulated.Backend
+	deployer *bind.TransactOpts
+	keys     map[common.Address]*ecdsa.PrivateKey
+}
+
+func newSimChain(t *testing.T, traders int) (*simChain, []common.Address) {
+	t.Helper()
+	balance := ether(1000)
+	alloc := types.GenesisAlloc{}
+	keys := make(map[common.Address]*ecdsa.PrivateKey)
+	var accounts []common.Address
+	for i := 0; i <= traders; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		alloc[address] = types.Account{Balance: balance}
+		keys[address] = key
+		accounts = append(accounts, address)
+	}
+
+	backend := simulated.NewBackend(alloc)
+	t.Cleanup(func() { backend.Close() })
+	s := &simChain{t: t, backend: backend, keys: keys}
+	s.deployer = s.transactor(accounts[0])
+	return s, accounts[1:]
+}
+
+func (s *simChain) transactor(account common.Address) *bind.TransactOpts {
+	opts, err := bind.NewKeyedTransactorWithChainID(s.keys[account], new(big.Int).SetUint64(simulatedChainID))
+	require.NoError(s.t, err)
+	return opts
+}
+
+// mine seals the pending transactions into a block and checks they succeeded.
+func (s *simChain) mine(txs ...*types.Transaction) {
+	s.t.Helper()
+	s.backend.Commit()
+	for _, tx := range txs {
+		receipt, err := bind.WaitMined(context.Background(), s.backend.Client(), tx)
+		require.NoError(s.t, err)
+		require.Equal(s.t, types.ReceiptStatusSuccessful, receipt.Status, "tx %s reverted", tx.Hash().Hex())
+	}
+}
+
+func (s *simChain) deploy(contract artifact, params ...interface{}) (common.Address, *bind.BoundContract) {
+	s.t.Helper()
+	address, tx, bound, err := bind.DeployContract(s.deployer, contract.abi, contract.bytecode, s.backend.Client(), params...)
+	require.NoError(s.t, err)
+	s.mine(tx)
+	return address, bound
+}
+
+func (s *simChain) send(opts *bind.TransactOpts, contract *bind.BoundContract, method string, params ...interface{}) *types.Transaction {
+	s.t.Helper()
+	tx, err := contract.Transact(opts, method, params...)
+	require.NoError(s.t, err)
+	return tx
+}
+
+func (s *simChain) transact(opts *bind.TransactOpts, contract *bind.BoundContract, method string, params ...interface{}) {
+	s.t.Helper()
+	s.mine(s.send(opts, contract, method, params...))
+}
+
+func (s *simChain) call(contract *bind.BoundContract, method string, params ...interface{}) []interface{} {
+	s.t.Helper()
+	var out []interface{}
+	require.NoError(s.t, contract.Call(&bind.CallOpts{}, &out, method, params...))
+	return out
+}
+
+// uniswapPool is a Uniswap V2 pair of a USD quote token and another token.
+type uniswapPool struct {
+	sim     *simChain
+	address common.Address
+	pair    *bind.BoundContract
+	usd     *bind.BoundContract
+	usdAddr common.Address
+}
+
+// deployPool deploys two ERC20 tokens, a factory and their pair, and adds
+// liquidity of a million of each token.
+func (s *simChain) deployPool() *uniswapPool {
+	s.t.Helper()
+	erc20 := loadArtifact(s.t, "ERC20")
+	factoryArtifact := loadArtifact(s.t, "UniswapV2Factory")
+	pairArtifact := loadArtifact(s.t, "UniswapV2Pair")
+
+	supply := ether(1_000_000_000)
+	usdAddr, usd := s.deploy(erc20, supply)
+	tokenAddr, token := s.deploy(erc20, supply)
+	_, factory := s.deploy(factoryArtifact, s.deployer.From)
+
+	s.transact(s.deployer, factory, "createPair", usdAddr, tokenAddr)
+	pairAddr := s.call(factory, "getPair", usdAddr, tokenAddr)[0].(common.Address)
+	pair := bind.NewBoundContract(pairAddr, pairArtifact.abi, s.backend.Client(), s.backend.Client(), s.backend.Client())
+
+	s.transact(s.deployer, usd, "transfer", pairAddr, ether(1_000_000))
+	s.transact(s.deployer, token, "transfer", pairAddr, ether(1_000_000))
+	s.transact(s.deployer, pair, "mint", s.deployer.From)
+	return &uniswapPool{sim: s, address: pairAddr, pair: pair, usd: usd, usdAddr: usdAddr}
+}
+
+// fund sends amount of the quote token to every trader in one block.
+func (p *uniswapPool) fund(traders []common.Address, amount *big.Int) {
+	s := p.sim
+	s.t.Helper()
+	var txs []*types.Transaction
+	for _, trader := range traders {
+		txs = append(txs, s.send(s.deployer, p.usd, "transfer", trader, amount))
+	}
+	s.mine(txs...)
+}
+
+// buy swaps amountIn of the quote token for the other token on behalf of
+// trader, the way a router would: the input is sent to the pair and the
+// output, computed from the reserves, is asked for in the same block.
+func (p *uniswapPool) buy(trader common.Address, amountIn *big.Int) {
+	s := p.sim
+	s.t.Helper()
+	reserves := s.call(p.pair, "getReserves")
+	reserve0, reserve1 := reserves[0].(*big.Int), reserves[1].(*big.Int)
+	token0 := s.call(p.pair, "token0")[0].(common.Address)
+	reserveIn, reserveOut := reserve0, reserve1
+	if token0 != p.usdAddr {
+		reserveIn, reserveOut = reserve1, reserve0
+	}
+	amountInWithFee := new(big.Int).Mul(amountIn, big.NewInt(997))
+	amountOut := new(big.Int).Div(
+		new(big.Int).Mul(amountInWithFee, reserveOut),
+		new(big.Int).Add(new(big.Int).Mul(reserveIn, big.NewInt(1000)), amountInWithFee),
+	)
+	amount0Out, amount1Out := big.NewInt(0), amountOut
+	if token0 != p.usdAddr {
+		amount0Out, amount1Out = amountOut, big.NewInt(0)
+	}
+
+	// Gas is not estimated, the swap would revert against the state before
+	// the transfer.
+	opts := s.transactor(trader)
+	opts.GasLimit = 300_000
+	transfer := s.send(opts, p.usd, "transfer", p.address, amountIn)
+	swap := s.send(opts, p.pair, "swap", amount0Out, amount1Out, trader, []byte{})
+	s.mine(transfer, swap)
+}
+
+func ether(amount int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), big.NewInt(1e18))
+}
+
+// setupE2EDatabase connects to the local Postgres used by the database tests
+// and drops every table once the test is done.
+func setupE2EDatabase(t *testing.T) {
+	viper.Set("database.host", "localhost")
+	viper.Set("database.port", 5432)
+	viper.Set("database.user", "admin")
+	viper.Set("database.password", "2222")
+	viper.Set("database.dbname", "e2e_db")
+	db := database.InitPostgreSQL()
+	t.Cleanup(func() {
+		_, err := db.Exec("DROP TABLE IF EXISTS address_labels, swap_flags, pools, block_times, ingestion_checkpoints, user_swaps, user_points_history, user_tasks, tasks, campaigns, users CASCADE")
+		assert.NoError(t, err)
+		db.Close()
+		viper.Reset()
+	})
+}
+
+// pointsOf sums the points history of trader per task.
+func pointsOf(t *testing.T, trader common.Address) map[int]decimal.Decimal {
+	user, err := database.GetUserByAddress(eth.ParseAddress(trader.Hex()))
+	if err == sql.ErrNoRows {
+		return map[int]decimal.Decimal{}
+	}
+	require.NoError(t, err)
+	histories, err := database.GetUserPointsHistoryByUserID(user.UserID)
+	require.NoError(t, err)
+	points := make(map[int]decimal.Decimal)
+	for _, history := range histories {
+		points[history.TaskID] = points[history.TaskID].Add(history.Points)
+	}
+	return points
+}
+
+// TestEndToEnd runs a campaign on a real Uniswap V2 pair: swaps go through
+// the listener to the onboarding task, and the round is settled from the
+// chain once it ends.
+func TestEndToEnd(t *testing.T) {
+	setupE2EDatabase(t)
+	sim, traders := newSimChain(t, 3)
+	pool := sim.deployPool()
+
+	client, ok := sim.backend.Client().(eth.ChainClient)
+	require.True(t, ok)
+	chain := &eth.Chain{
+		ID:           simulatedChainID,
+		Name:         "simulated",
+		Client:       client,
+		QuoteTokens:  []string{pool.usdAddr.Hex()},
+		Ingestion:    eth.IngestionSubscribe,
+		PollInterval: time.Second,
+	}
+	eth.RegisterChain(chain)
+	go eth.ListenToContractEvents(chain)
+
+	// Blocks are sealed at the current time, or a second after their parent
+	// when sealed faster. Let the clock catch up with the chain so the swaps
+	// fall within the round.
+	head, err := client.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	for time.Now().Unix() < int64(head.Time) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	startAt := int64(head.Time)
+	body := fmt.Sprintf(`{"chainId": %d, "name": "e2e", "poolAddress": %q, "startAt": %d, "onboardingReward": "100", "onboardingThreshold": "1000", "pointPool": "10000", "schedule": "15s", "round": 1}`,
+		simulatedChainID, pool.address.Hex(), startAt)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/Campaign", CreateCampaignHandler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/Campaign", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	poolAddress := eth.ParseAddress(pool.address.Hex())
+	campaigns, err := database.GetCampaignsByAddress(simulatedChainID, poolAddress)
+	require.NoError(t, err)
+	require.Len(t, campaigns, 1)
+	onboarding, err := database.GetTaskByCampaignIDAndType(campaigns[0].CampaignID, eth.TaskTypeOnboarding)
+	require.NoError(t, err)
+	tasks, err := database.GetTasksByCampaignID(campaigns[0].CampaignID)
+	require.NoError(t, err)
+	var round database.Task
+	for _, task := range tasks {
+		if task.TaskID != onboarding.TaskID {
+			round = task
+		}
+	}
+
+	// Swaps are only seen once the listener has subscribed and set the pool
+	// checkpoint.
+	require.Eventually(t, func() bool {
+		_, err := database.GetIngestionCheckpoint(simulatedChainID, poolAddress)
+		return err == nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	pool.fund(traders, ether(10_000))
+	pool.buy(traders[0], ether(3000))
+	pool.buy(traders[1], ether(1000))
+	pool.buy(traders[2], ether(500))
+
+	// Traders reaching the threshold complete onboarding as their swaps are
+	// ingested.
+	require.Eventually(t, func() bool {
+		return len(pointsOf(t, traders[0])) == 1 && len(pointsOf(t, traders[1])) == 1
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, "100", pointsOf(t, traders[0])[onboarding.TaskID].String())
+	assert.Equal(t, "100", pointsOf(t, traders[1])[onboarding.TaskID].String())
+	assert.Empty(t, pointsOf(t, traders[2]))
+
+	// Settle the round once it has ended and its swaps are ingested, splitting
+	// the pool by volume among onboarded traders. The round is settled again
+	// on later ticks until the pool checkpoint passes its end.
+	for time.Now().Unix() <= round.EndTime {
+		time.Sleep(200 * time.Millisecond)
+	}
+	sim.backend.Commit()
+	settleExpiredTasks(time.Hour)
+	require.Eventually(t, func() bool {
+		settleExpiredTasks(0)
+		return len(pointsOf(t, traders[0])) == 2
+	}, 30*time.Second, time.Second)
+
+	assert.Equal(t, "7500", pointsOf(t, traders[0])[round.TaskID].String())
+	assert.Equal(t, "2500", pointsOf(t, traders[1])[round.TaskID].String())
+	assert.Empty(t, pointsOf(t, traders[2]))
+}
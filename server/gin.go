@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/subtle"
 	"log"
 	"net/http"
 
@@ -10,12 +11,28 @@ import (
 )
 
 func StartServer() {
-	go eth.ListenToContractEvents()
-	go ProcessSharePoolTicker()
+	chains := eth.NewChains()
+	for _, chain := range chains {
+		go eth.ListenToContractEvents(chain)
+		go eth.IndexBlockTimes(chain)
+	}
+	go ProcessSettlementTicker()
 	r := gin.Default()
 	r.GET("/health", func(c *gin.Context) {
+		var statuses []ChainStatusResp
+		for _, chain := range chains {
+			status := ChainStatusResp{ChainID: chain.ID, Name: chain.Name}
+			if pool, ok := chain.Client.(*eth.Pool); ok {
+				status.Endpoints = pool.Status()
+			}
+			if stats, ok := eth.GetPipelineStats(chain.ID); ok {
+				status.Pipeline = &stats
+			}
+			statuses = append(statuses, status)
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"message": "ok",
+			"chains":  statuses,
 		})
 	})
 
@@ -23,9 +40,29 @@ func StartServer() {
 	r.GET("/user/task/status", GetUserTaskStatusHandler)
 	r.GET("/user/points", GetUserPointsHistoryHandler)
 
+	admin := r.Group("/admin", adminAuth())
+	admin.GET("/swap/flags", GetSwapFlagsHandler)
+	admin.GET("/labels", GetAddressLabelsHandler)
+	admin.POST("/labels", CreateAddressLabelHandler)
+	admin.DELETE("/labels", DeleteAddressLabelHandler)
+
 	port := viper.GetString("server.port")
 	err := r.Run(":" + port)
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// adminAuth only lets requests through that carry the configured
+// server.admin_token in the X-Admin-Token header. Without a token the admin
+// endpoints are disabled.
+func adminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := viper.GetString("server.admin_token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
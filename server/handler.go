@@ -1,14 +1,20 @@
 package server
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/Largeb0525/Trading_Ace/database"
+	"github.com/Largeb0525/Trading_Ace/decimal"
 	"github.com/Largeb0525/Trading_Ace/eth"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 )
@@ -19,6 +25,35 @@ func CreateCampaignHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
+	if req.ChainID == 0 {
+		req.ChainID = eth.MainnetChainID
+	}
+	chain, err := eth.GetChain(req.ChainID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PoolAddress = eth.ParseAddress(req.PoolAddress)
+	protocol, err := eth.NormalizeProtocol(req.Protocol)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attribution, err := eth.NormalizeAttribution(req.Attribution)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pool, err := eth.ResolvePool(chain, common.HexToAddress(req.PoolAddress), protocol)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to resolve pool metadata"})
+		return
+	}
+	if err := eth.CheckPoolPricing(chain, pool); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	startTime := req.StartAt
 	duration, err := time.ParseDuration(req.Schedule)
@@ -28,34 +63,79 @@ func CreateCampaignHandler(c *gin.Context) {
 	}
 	endTime := startTime + int64(duration.Seconds())*req.Round
 
-	campaignID, err := database.CreateCampaign(req.Name, req.PoolAddress, startTime, endTime)
+	tasks, err := campaignTasks(req, startTime, endTime, int64(duration.Seconds()))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	_, err = database.CreateOnboardingTask(campaignID, "", req.OnboardingReward, req.OnboardingThreshold, startTime, endTime)
+	campaignID, err := database.CreateCampaign(chain.ID, req.Name, req.PoolAddress, protocol, attribution, startTime, endTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create onboarding task"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
 		return
 	}
 
-	for i := 0; i < int(req.Round); i++ {
-		endTime = startTime + int64(duration.Seconds())
-		describe := fmt.Sprintf("Round %d", i+1)
-		_, err = database.CreateSharePoolTask(campaignID, describe, req.PointPool, startTime, endTime)
+	for _, task := range tasks {
+		_, err = database.CreateTask(campaignID, task.Type, task.Description, task.Config, task.StartTime, task.EndTime)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share pool task"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create %s task", task.Type)})
 			return
 		}
-		startTime = endTime
 	}
 
-	eth.AddAddresses([]string{req.PoolAddress})
+	eth.AddAddresses(chain.ID, []string{req.PoolAddress})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Campaign and tasks created successfully"})
 }
 
+// campaignTasks returns the tasks of a new campaign: an onboarding task over
+// the whole campaign, a share pool task per round and the extra tasks of the
+// request, which also span the campaign. Every config is validated by its
+// task type.
+func campaignTasks(req CreateCampaignReq, startTime, endTime, roundDuration int64) ([]database.Task, error) {
+	var tasks []database.Task
+	addTask := func(taskType, description string, config interface{}, startTime, endTime int64) error {
+		data, ok := config.(json.RawMessage)
+		if !ok {
+			var err error
+			data, err = json.Marshal(config)
+			if err != nil {
+				return err
+			}
+		}
+		t, err := eth.GetTaskType(taskType)
+		if err != nil {
+			return err
+		}
+		if err := t.ValidateConfig(data); err != nil {
+			return err
+		}
+		tasks = append(tasks, database.Task{Type: taskType, Description: description, Config: data, StartTime: startTime, EndTime: endTime})
+		return nil
+	}
+
+	err := addTask(eth.TaskTypeOnboarding, "", eth.OnboardingConfig{Reward: *req.OnboardingReward, Threshold: *req.OnboardingThreshold}, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	roundStart := startTime
+	for i := 0; i < int(req.Round); i++ {
+		describe := fmt.Sprintf("Round %d", i+1)
+		err = addTask(eth.TaskTypeSharePool, describe, eth.SharePoolConfig{PointsPool: *req.PointPool}, roundStart, roundStart+roundDuration)
+		if err != nil {
+			return nil, err
+		}
+		roundStart += roundDuration
+	}
+	for _, task := range req.Tasks {
+		err = addTask(task.Type, task.Description, task.Config, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
 func GetUserTaskStatusHandler(c *gin.Context) {
 	userID, _ := strconv.Atoi(c.Query("userID"))
 	inputAddress := c.Query("userAddress")
@@ -118,6 +198,123 @@ func GetUserPointsHistoryHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// GetSwapFlagsHandler lists the swaps of a campaign discounted as wash trading
+// and why.
+func GetSwapFlagsHandler(c *gin.Context) {
+	campaignID, _ := strconv.Atoi(c.Query("campaignID"))
+	if campaignID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	taskID, _ := strconv.Atoi(c.Query("taskID"))
+	traderAddress := ""
+	if c.Query("userAddress") != "" {
+		traderAddress = eth.ParseAddress(c.Query("userAddress"))
+	}
+
+	flags, err := database.GetSwapFlags(campaignID, taskID, traderAddress)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get swap flags"})
+		return
+	}
+
+	resp := GetSwapFlagsResp{Flags: []SwapFlagResp{}}
+	for _, flag := range flags {
+		resp.Flags = append(resp.Flags, SwapFlagResp{
+			CampaignID:      flag.CampaignID,
+			TaskID:          flag.TaskID,
+			ChainID:         flag.ChainID,
+			TransactionHash: flag.TransactionHash,
+			LogIndex:        flag.LogIndex,
+			UserAddress:     flag.TraderAddress,
+			Reason:          flag.Reason,
+			Detail:          flag.Detail,
+			AmountUSD:       flag.AmountUSD,
+			Timestamp:       time.Unix(flag.CreatedAt, 0),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAddressLabelsHandler lists the labeled addresses of a chain.
+func GetAddressLabelsHandler(c *gin.Context) {
+	chainID, _ := strconv.ParseUint(c.Query("chainID"), 10, 64)
+	if chainID == 0 {
+		chainID = eth.MainnetChainID
+	}
+	address := ""
+	if c.Query("address") != "" {
+		address = eth.ParseAddress(c.Query("address"))
+	}
+
+	labels, err := database.GetAddressLabels(chainID, address)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get address labels"})
+		return
+	}
+	resp := GetAddressLabelsResp{Labels: []AddressLabelResp{}}
+	for _, label := range labels {
+		resp.Labels = append(resp.Labels, AddressLabelResp{
+			ChainID:   label.ChainID,
+			Address:   label.Address,
+			Label:     label.Label,
+			Source:    label.Source,
+			Detail:    label.Detail,
+			Timestamp: time.Unix(label.CreatedAt, 0),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateAddressLabelHandler labels an address by hand, for instance a known
+// MEV bot to exclude from campaigns.
+func CreateAddressLabelHandler(c *gin.Context) {
+	var req CreateAddressLabelReq
+	if err := c.ShouldBindJSON(&req); err != nil || !common.IsHexAddress(req.Address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if req.ChainID == 0 {
+		req.ChainID = eth.MainnetChainID
+	}
+
+	err := eth.LabelAddress(database.AddressLabel{
+		ChainID: req.ChainID,
+		Address: eth.ParseAddress(req.Address),
+		Label:   req.Label,
+		Source:  "manual",
+		Detail:  req.Detail,
+	}, eth.LoadMEVConfig())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address label"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Address labeled successfully"})
+}
+
+// DeleteAddressLabelHandler removes a label given by mistake. Rewards taken
+// back when the address was labeled are not paid again.
+func DeleteAddressLabelHandler(c *gin.Context) {
+	chainID, _ := strconv.ParseUint(c.Query("chainID"), 10, 64)
+	if chainID == 0 {
+		chainID = eth.MainnetChainID
+	}
+	if !common.IsHexAddress(c.Query("address")) || c.Query("label") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	err := database.DeleteAddressLabel(chainID, eth.ParseAddress(c.Query("address")), c.Query("label"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address label not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address label"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Address label deleted successfully"})
+}
+
 func buildUserTaskStatusResponse(userTasks []database.UserTask) (GetUserTaskStatusResp, error) {
 	userTasksMap := make(map[int]database.UserTask)
 	taskIDs := make([]int, len(userTasks))
@@ -141,8 +338,11 @@ func buildUserTaskStatusResponse(userTasks []database.UserTask) (GetUserTaskStat
 			}
 			campaignResp = CampaignResp{
 				CampaignID:  campaign.CampaignID,
+				ChainID:     campaign.ChainID,
 				Name:        campaign.Name,
 				PoolAddress: campaign.PoolAddress,
+				Protocol:    campaign.Protocol,
+				Attribution: campaign.Attribution,
 				StartTime:   campaign.StartTime,
 				EndTime:     campaign.EndTime,
 				Tasks:       []TaskStatusResp{},
@@ -150,13 +350,21 @@ func buildUserTaskStatusResponse(userTasks []database.UserTask) (GetUserTaskStat
 		}
 
 		userTask := userTasksMap[task.TaskID]
+		status := eth.TaskStatus{Completed: userTask.Completed, Amount: userTask.Amount, Points: userTask.Points}
+		if taskType, err := eth.GetTaskType(task.Type); err == nil {
+			status, err = taskType.Status(task, userTask)
+			if err != nil {
+				return GetUserTaskStatusResp{}, fmt.Errorf("Failed to get status of task %d: %w", task.TaskID, err)
+			}
+		}
 		taskResp := TaskStatusResp{
 			TaskID:      task.TaskID,
 			Type:        task.Type,
 			Description: task.Description,
-			Completed:   userTask.Completed,
-			Amount:      userTask.Amount,
-			Points:      userTask.Points,
+			Completed:   status.Completed,
+			Amount:      status.Amount,
+			Points:      status.Points,
+			Detail:      status.Detail,
 			StartTime:   task.StartTime,
 			EndTime:     task.EndTime,
 		}
@@ -174,7 +382,7 @@ func buildUserTaskStatusResponse(userTasks []database.UserTask) (GetUserTaskStat
 }
 
 func buildUserPointsHistoryResponse(UserPointsHistory []database.UserPointsHistory) (GetUserPointsHistoryResp, error) {
-	total := 0.0
+	total := decimal.Zero()
 	campaignMap := make(map[int]database.Campaign)
 	pointsHistory := []PointsHistoryResp{}
 	for _, history := range UserPointsHistory {
@@ -197,6 +405,7 @@ func buildUserPointsHistoryResponse(UserPointsHistory []database.UserPointsHisto
 
 		pointsHistoryResp := PointsHistoryResp{
 			CampaignID:   history.CampaignID,
+			ChainID:      campaign.ChainID,
 			CampaignName: campaign.Name,
 			PoolAddress:  campaign.PoolAddress,
 			TaskID:       history.TaskID,
@@ -207,13 +416,15 @@ func buildUserPointsHistoryResponse(UserPointsHistory []database.UserPointsHisto
 		}
 		pointsHistory = append(pointsHistory, pointsHistoryResp)
 
-		total += history.Points
+		total = total.Add(history.Points)
 	}
 
 	return GetUserPointsHistoryResp{PointsHistory: pointsHistory, Total: total}, nil
 }
 
-func ProcessSharePoolTicker() {
+// ProcessSettlementTicker settles the tasks that ended since the last tick,
+// and those whose swaps were not all ingested on an earlier tick.
+func ProcessSettlementTicker() {
 	duration, err := time.ParseDuration(viper.GetString("server.ticker"))
 	if err != nil {
 		log.Printf("Failed to parse duration: %v", err)
@@ -223,22 +434,41 @@ func ProcessSharePoolTicker() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		checkAndProcessSharePoolTasks(duration)
+		settleExpiredTasks(duration)
 	}
 }
 
-func checkAndProcessSharePoolTasks(duration time.Duration) {
+// unsettledTasks holds the expired tasks whose swaps were not all ingested
+// yet, settled again on the next ticks.
+var unsettledTasks = make(map[int]database.Task)
+
+func settleExpiredTasks(duration time.Duration) {
 	now := time.Now().Unix()
 	lastChecked := now - int64(duration.Seconds())
-	tasks, err := database.GetExpiredSharePoolTasks(now, lastChecked)
+	expired, err := database.GetExpiredTasks(now, lastChecked)
 	if err != nil {
-		log.Printf("Failed to retrieve expired share pool tasks: %v", err)
+		log.Printf("Failed to retrieve expired tasks: %v", err)
 		return
 	}
-	OnboardingTaskIDMap := make(map[int]database.Task)
+	var tasks []database.Task
+	for _, task := range unsettledTasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskID < tasks[j].TaskID })
+	for _, task := range expired {
+		if _, ok := unsettledTasks[task.TaskID]; !ok {
+			tasks = append(tasks, task)
+		}
+	}
 	campaignMap := make(map[int]database.Campaign)
 
 	for _, task := range tasks {
+		delete(unsettledTasks, task.TaskID)
+		taskType, err := eth.GetTaskType(task.Type)
+		if err != nil {
+			log.Printf("Failed to settle task %d: %v", task.TaskID, err)
+			continue
+		}
 		campaign, ok := campaignMap[task.CampaignID]
 		if !ok {
 			newCampaign, err := database.GetCampaignByID(task.CampaignID)
@@ -249,26 +479,17 @@ func checkAndProcessSharePoolTasks(duration time.Duration) {
 			campaign = *newCampaign
 			campaignMap[campaign.CampaignID] = campaign
 		}
-		_, ok = OnboardingTaskIDMap[campaign.CampaignID]
-		if !ok {
-			onboardingTask, err := database.GetOnboardingTaskByCampaignID(campaign.CampaignID)
-			if err != nil {
-				log.Printf("Failed to retrieve onboarding tasks for campaign %d: %v", campaign.CampaignID, err)
-				continue
-			}
-			OnboardingTaskIDMap[onboardingTask.CampaignID] = *onboardingTask
-		}
-
-		swapEvents, err := eth.FetchSwapEvents(campaign.PoolAddress, task.StartTime, task.EndTime)
+		chain, err := eth.GetChain(campaign.ChainID)
 		if err != nil {
-			log.Printf("Failed to fetch swap events for task %d: %v", task.TaskID, err)
+			log.Printf("Failed to settle task %d: %v", task.TaskID, err)
 			continue
 		}
-		swapInfos, err := eth.ParseSwapEvents(swapEvents)
-		if err != nil {
-			log.Printf("Failed to parse swap events for task %d: %v", task.TaskID, err)
-			continue
+		err = taskType.Settle(chain, campaign, task)
+		if errors.Is(err, eth.ErrNotIngested) {
+			log.Printf("Delayed settlement of task %d: %v", task.TaskID, err)
+			unsettledTasks[task.TaskID] = task
+		} else if err != nil {
+			log.Printf("Failed to settle task %d: %v", task.TaskID, err)
 		}
-		eth.ProcessSwapInfos(task, swapInfos, OnboardingTaskIDMap[campaign.CampaignID])
 	}
 }
@@ -0,0 +1,33 @@
+// Compiles contracts.sol into the artifacts loaded by the e2e test.
+//
+//   node compile.js path/to/soljson-v0.8.21+commit.d9974bed.js
+//
+// soljson is the emscripten build of solc, published at
+// https://binaries.soliditylang.org/bin/.
+const fs = require('fs');
+const path = require('path');
+
+const soljson = require(path.resolve(process.argv[2]));
+const compile = soljson.cwrap('solidity_compile', 'string', ['string', 'number', 'number']);
+
+const input = {
+  language: 'Solidity',
+  sources: { 'contracts.sol': { content: fs.readFileSync(path.join(__dirname, 'contracts.sol'), 'utf8') } },
+  settings: {
+    optimizer: { enabled: true, runs: 200 },
+    // The simulated backend does not support PUSH0, introduced by shanghai.
+    evmVersion: 'paris',
+    outputSelection: { '*': { '*': ['abi', 'evm.bytecode.object'] } },
+  },
+};
+const output = JSON.parse(compile(JSON.stringify(input), 0, 0));
+for (const error of output.errors || []) {
+  console.error(error.formattedMessage);
+}
+if ((output.errors || []).some((error) => error.severity === 'error')) {
+  process.exit(1);
+}
+for (const [name, contract] of Object.entries(output.contracts['contracts.sol'])) {
+  const artifact = { contractName: name, abi: contract.abi, bytecode: '0x' + contract.evm.bytecode.object };
+  fs.writeFileSync(path.join(__dirname, name + '.json'), JSON.stringify(artifact, null, 2) + '\n');
+}